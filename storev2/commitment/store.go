@@ -1,6 +1,7 @@
 package commitment
 
 import (
+	"bytes"
 	"fmt"
 	"io"
 
@@ -37,6 +38,11 @@ func NewStore(tree sctypes.Tree, logger log.Logger) *Store {
 	}
 }
 
+// Tree returns the underlying sc tree of the store.
+func (st *Store) Tree() sctypes.Tree {
+	return st.tree
+}
+
 func (st *Store) Commit(_ bool) types.CommitID {
 	panic("memiavl store is not supposed to be committed alone")
 }
@@ -126,6 +132,20 @@ func (st *Store) SetInitialVersion(_ int64) {
 	panic("memiavl store's SetInitialVersion is not supposed to be called directly")
 }
 
+// GetWorking returns the value of the key including the writes not popped yet, which are not visible to Get.
+func (st *Store) GetWorking(key []byte) []byte {
+	for i := len(st.changeSet.Pairs) - 1; i >= 0; i-- {
+		pair := st.changeSet.Pairs[i]
+		if bytes.Equal(pair.Key, key) {
+			if pair.Delete {
+				return nil
+			}
+			return pair.Value
+		}
+	}
+	return st.tree.Get(key)
+}
+
 // PopChangeSet returns the change set and clear it
 func (st *Store) PopChangeSet() iavl.ChangeSet {
 	cs := st.changeSet
@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	"github.com/cosmos/cosmos-sdk/store/types"
+	"github.com/cosmos/iavl"
 	"github.com/sei-protocol/sei-db/sc/memiavl"
 	"github.com/stretchr/testify/require"
 	"github.com/tendermint/tendermint/libs/log"
@@ -14,3 +15,25 @@ func TestLastCommitID(t *testing.T) {
 	store := NewStore(tree, log.NewNopLogger())
 	require.Equal(t, types.CommitID{Hash: tree.RootHash()}, store.LastCommitID())
 }
+
+func TestGetWorking(t *testing.T) {
+	tree := memiavl.New(100)
+	tree.ApplyChangeSet(iavl.ChangeSet{Pairs: []*iavl.KVPair{
+		{Key: []byte("a"), Value: []byte("1")},
+		{Key: []byte("b"), Value: []byte("1")},
+	}})
+	store := NewStore(tree, log.NewNopLogger())
+	store.Set([]byte("a"), []byte("2"))
+	store.Delete([]byte("b"))
+	store.Set([]byte("c"), []byte("2"))
+	store.Set([]byte("c"), []byte("3"))
+
+	require.Equal(t, []byte("1"), store.Get([]byte("a")))
+	require.Equal(t, []byte("2"), store.GetWorking([]byte("a")))
+	require.Nil(t, store.GetWorking([]byte("b")))
+	require.Equal(t, []byte("3"), store.GetWorking([]byte("c")))
+	require.Nil(t, store.GetWorking([]byte("d")))
+
+	store.PopChangeSet()
+	require.Equal(t, []byte("1"), store.GetWorking([]byte("a")))
+}
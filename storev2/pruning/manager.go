@@ -0,0 +1,314 @@
+package pruning
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	sstypes "github.com/sei-protocol/sei-db/ss/types"
+	"github.com/tendermint/tendermint/libs/log"
+)
+
+const (
+	// DefaultJitter keeps the historical behavior of adding a random delay of up to
+	// one full interval between two prune cycles.
+	DefaultJitter = 1.0
+)
+
+// Manager periodically prunes the state store based on keep-recent and prune-interval configs.
+type Manager struct {
+	logger        log.Logger
+	stateStore    sstypes.StateStore
+	keepRecent    int64
+	pruneInterval int64
+	// jitter is the max fraction of the interval added as a random delay,
+	// so that prune cycles desynchronize across nodes.
+	jitter float64
+	// maxBackoff is the upper bound in seconds of the exponential backoff applied
+	// after consecutive prune failures, 0 disables backoff.
+	maxBackoff int64
+	// versionInterval is the min number of versions committed between two prunes, 0 prunes on each cycle.
+	versionInterval int64
+	// lastPruneLatest is the latest version when the state store was last pruned.
+	lastPruneLatest int64
+	started         bool
+	// stopped is set once the state store is closed, the manager can't be started again.
+	stopped bool
+	// pruneMtx serializes the prunes with Reset and Stop, so the state store isn't closed while it's pruned.
+	// It's acquired before mtx, and isn't held by the pins.
+	pruneMtx sync.Mutex
+	// mtx guards the configs and the pins, it's released while the state store is pruned.
+	mtx sync.Mutex
+	// pinned counts the pins per version, pinned versions are protected against pruning.
+	pinned        map[int64]int
+	prunedVersion int64
+	// pruningVersion is the version the in-progress prune removes the versions up to, 0 if none is in progress.
+	pruningVersion int64
+}
+
+// Option configures optional behaviors of the pruning manager.
+type Option func(*Manager)
+
+// WithJitter sets the max fraction of the prune interval added as a random delay, 0 disables it.
+func WithJitter(fraction float64) Option {
+	return func(m *Manager) {
+		m.jitter = fraction
+	}
+}
+
+// WithMaxBackoff doubles the delay on consecutive prune failures, capped at maxBackoff seconds.
+func WithMaxBackoff(maxBackoff int64) Option {
+	return func(m *Manager) {
+		m.maxBackoff = maxBackoff
+	}
+}
+
+// NewPruningManager creates a new pruning manager for state store
+// Pruning Manager will periodically prune state store based on keep-recent and prune-interval configs.
+func NewPruningManager(
+	logger log.Logger,
+	stateStore sstypes.StateStore,
+	keepRecent int64,
+	pruneInterval int64,
+	opts ...Option,
+) *Manager {
+	m := &Manager{
+		logger:        logger,
+		stateStore:    stateStore,
+		keepRecent:    keepRecent,
+		pruneInterval: pruneInterval,
+		jitter:        DefaultJitter,
+		pinned:        make(map[int64]int),
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+func (m *Manager) Start() {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	if !m.enabled() || m.started || m.stopped {
+		return
+	}
+	m.started = true
+	go func() {
+		failures := 0
+		for m.running() {
+			if err := m.prune(); err != nil {
+				failures++
+				m.logger.Error("failed to prune state store", "err", err, "consecutive-failures", failures)
+			} else {
+				failures = 0
+			}
+			time.Sleep(m.nextDelay(failures, rand.Float64()))
+		}
+	}()
+}
+
+// UpdateConfig changes the keep-recent and prune-interval configs of the running manager, they take effect
+// from the next prune cycle, the manager is started or stopped if pruning gets enabled or disabled.
+// The versions pinned by the in-flight readers are still protected if keep-recent is lowered.
+func (m *Manager) UpdateConfig(keepRecent int64, pruneInterval int64) error {
+	if keepRecent < 0 || pruneInterval < 0 {
+		return fmt.Errorf("invalid pruning config, keep-recent: %d, prune-interval: %d", keepRecent, pruneInterval)
+	}
+	m.mtx.Lock()
+	m.keepRecent = keepRecent
+	m.pruneInterval = pruneInterval
+	m.mtx.Unlock()
+	m.logger.Info("updated state store pruning config", "keep-recent", keepRecent, "prune-interval", pruneInterval)
+	m.Start()
+	return nil
+}
+
+// SetVersionInterval sets the min number of versions committed between two prunes, e.g. the interval in blocks
+// of the pruning options of the app, it takes effect from the next prune cycle. 0 prunes on each cycle.
+func (m *Manager) SetVersionInterval(versionInterval int64) error {
+	if versionInterval < 0 {
+		return fmt.Errorf("invalid pruning version interval: %d", versionInterval)
+	}
+	m.mtx.Lock()
+	m.versionInterval = versionInterval
+	m.mtx.Unlock()
+	return nil
+}
+
+// VersionInterval returns the min number of versions committed between two prunes.
+func (m *Manager) VersionInterval() int64 {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	return m.versionInterval
+}
+
+// Config returns the current keep-recent and prune-interval configs.
+func (m *Manager) Config() (keepRecent int64, pruneInterval int64) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	return m.keepRecent, m.pruneInterval
+}
+
+func (m *Manager) enabled() bool {
+	return m.keepRecent > 0 && m.pruneInterval > 0
+}
+
+// running returns whether the prune loop should continue, it stops the loop once pruning is disabled.
+func (m *Manager) running() bool {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	if !m.enabled() || m.stopped {
+		m.started = false
+	}
+	return m.started
+}
+
+// Stop stops the prune loop before the state store is closed, it waits for an in-progress prune to finish.
+func (m *Manager) Stop() {
+	m.pruneMtx.Lock()
+	defer m.pruneMtx.Unlock()
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	m.stopped = true
+}
+
+// Pin protects the version against pruning until it's unpinned.
+// It doesn't wait for an in-progress prune, and fails if the version is already pruned or being pruned.
+func (m *Manager) Pin(version int64) error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	if version <= m.prunedVersion {
+		return fmt.Errorf("version %d is already pruned, pruned till %d", version, m.prunedVersion)
+	}
+	if version <= m.pruningVersion {
+		return fmt.Errorf("version %d is being pruned, pruning till %d", version, m.pruningVersion)
+	}
+	m.pinned[version]++
+	return nil
+}
+
+// Unpin releases a pin on the version registered by Pin.
+func (m *Manager) Unpin(version int64) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	if m.pinned[version] <= 1 {
+		delete(m.pinned, version)
+		return
+	}
+	m.pinned[version]--
+}
+
+// PruneUpTo removes all the versions up to and including version, it's lowered below the pinned versions,
+// returns the version actually pruned till.
+func (m *Manager) PruneUpTo(version int64) (int64, error) {
+	m.pruneMtx.Lock()
+	defer m.pruneMtx.Unlock()
+	return m.pruneUpTo(version)
+}
+
+// pruneUpTo must be called with pruneMtx held, the lowest pinned version is read under mtx, which is released
+// while the state store is pruned so the pins don't wait for it.
+func (m *Manager) pruneUpTo(version int64) (int64, error) {
+	m.mtx.Lock()
+	if m.stopped {
+		m.mtx.Unlock()
+		return m.prunedVersion, fmt.Errorf("pruning manager is stopped")
+	}
+	for pinned := range m.pinned {
+		if pinned <= version {
+			version = pinned - 1
+		}
+	}
+	if version <= 0 {
+		m.mtx.Unlock()
+		return m.prunedVersion, nil
+	}
+	m.pruningVersion = version
+	m.mtx.Unlock()
+
+	err := m.stateStore.Prune(version)
+
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	m.pruningVersion = 0
+	if err != nil {
+		return m.prunedVersion, fmt.Errorf("failed to prune versions till %d: %w", version, err)
+	}
+	if version > m.prunedVersion {
+		m.prunedVersion = version
+	}
+	return version, nil
+}
+
+// Reset switches the manager to the state store returned by replace, e.g. once the state store is wiped,
+// the pruned version is reset while the pins are kept. replace is called once an in-progress prune finishes
+// and before the next one starts, so the previous state store can be closed in it.
+func (m *Manager) Reset(replace func() (sstypes.StateStore, error)) error {
+	m.pruneMtx.Lock()
+	defer m.pruneMtx.Unlock()
+	stateStore, err := replace()
+	if err != nil {
+		return err
+	}
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	m.stateStore = stateStore
+	m.prunedVersion = 0
+	m.lastPruneLatest = 0
+	return nil
+}
+
+// prune removes all the versions up to and including latest version minus keep-recent, it's skipped until
+// version-interval versions are committed since the last prune. It's serialized with Reset and Stop, so the
+// state store isn't closed while it's pruned.
+func (m *Manager) prune() error {
+	m.pruneMtx.Lock()
+	defer m.pruneMtx.Unlock()
+	m.mtx.Lock()
+	stopped, keepRecent, versionInterval, lastPruneLatest := m.stopped, m.keepRecent, m.versionInterval, m.lastPruneLatest
+	m.mtx.Unlock()
+	if stopped {
+		return nil
+	}
+	pruneStartTime := time.Now()
+	latestVersion, err := m.stateStore.GetLatestVersion()
+	if err != nil {
+		return err
+	}
+	if lastPruneLatest > 0 && latestVersion-lastPruneLatest < versionInterval {
+		return nil
+	}
+	pruneVersion := latestVersion - keepRecent
+	if pruneVersion <= 0 {
+		return nil
+	}
+	prunedVersion, err := m.pruneUpTo(pruneVersion)
+	if err != nil {
+		return err
+	}
+	m.mtx.Lock()
+	m.lastPruneLatest = latestVersion
+	m.mtx.Unlock()
+	m.logger.Info(fmt.Sprintf("Pruned state store till version %d took %s", prunedVersion, time.Since(pruneStartTime)))
+	return nil
+}
+
+// nextDelay computes the delay before the next prune cycle given the number of consecutive
+// failures and a random number in [0, 1) used for the jitter.
+func (m *Manager) nextDelay(failures int, random float64) time.Duration {
+	_, interval := m.Config()
+	if m.maxBackoff > interval && failures > 0 {
+		for i := 0; i < failures && interval < m.maxBackoff; i++ {
+			interval *= 2
+		}
+		if interval > m.maxBackoff {
+			interval = m.maxBackoff
+		}
+	}
+	delay := time.Duration(interval) * time.Second
+	if m.jitter > 0 {
+		delay += time.Duration(float64(delay) * m.jitter * random)
+	}
+	return delay
+}
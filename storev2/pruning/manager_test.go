@@ -0,0 +1,215 @@
+package pruning
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	sstypes "github.com/sei-protocol/sei-db/ss/types"
+	"github.com/stretchr/testify/require"
+	"github.com/tendermint/tendermint/libs/log"
+)
+
+type mockStateStore struct {
+	sstypes.StateStore
+	latestVersion int64
+	prunedVersion int64
+	pruneErr      error
+}
+
+func (s *mockStateStore) GetLatestVersion() (int64, error) {
+	return s.latestVersion, nil
+}
+
+func (s *mockStateStore) Prune(version int64) error {
+	if s.pruneErr != nil {
+		return s.pruneErr
+	}
+	s.prunedVersion = version
+	return nil
+}
+
+func TestNextDelayJitter(t *testing.T) {
+	m := NewPruningManager(log.NewNopLogger(), nil, 10, 60)
+	require.Equal(t, 60*time.Second, m.nextDelay(0, 0))
+	require.Equal(t, 90*time.Second, m.nextDelay(0, 0.5))
+
+	m = NewPruningManager(log.NewNopLogger(), nil, 10, 60, WithJitter(0))
+	require.Equal(t, 60*time.Second, m.nextDelay(0, 0.5))
+
+	m = NewPruningManager(log.NewNopLogger(), nil, 10, 60, WithJitter(0.1))
+	require.Equal(t, 63*time.Second, m.nextDelay(0, 0.5))
+}
+
+func TestNextDelayBackoff(t *testing.T) {
+	// backoff disabled by default
+	m := NewPruningManager(log.NewNopLogger(), nil, 10, 60, WithJitter(0))
+	require.Equal(t, 60*time.Second, m.nextDelay(5, 0))
+
+	m = NewPruningManager(log.NewNopLogger(), nil, 10, 60, WithJitter(0), WithMaxBackoff(300))
+	require.Equal(t, 60*time.Second, m.nextDelay(0, 0))
+	require.Equal(t, 120*time.Second, m.nextDelay(1, 0))
+	require.Equal(t, 240*time.Second, m.nextDelay(2, 0))
+	require.Equal(t, 300*time.Second, m.nextDelay(3, 0))
+	require.Equal(t, 300*time.Second, m.nextDelay(100, 0))
+}
+
+func TestPrune(t *testing.T) {
+	store := &mockStateStore{latestVersion: 5}
+	m := NewPruningManager(log.NewNopLogger(), store, 10, 60)
+	require.NoError(t, m.prune())
+	require.Zero(t, store.prunedVersion)
+
+	store.latestVersion = 25
+	require.NoError(t, m.prune())
+	require.Equal(t, int64(15), store.prunedVersion)
+
+	store.pruneErr = errors.New("disk failure")
+	require.ErrorIs(t, m.prune(), store.pruneErr)
+}
+
+func TestPruneVersionInterval(t *testing.T) {
+	store := &mockStateStore{latestVersion: 25}
+	m := NewPruningManager(log.NewNopLogger(), store, 10, 60)
+	require.Error(t, m.SetVersionInterval(-1))
+	require.NoError(t, m.SetVersionInterval(10))
+	require.Equal(t, int64(10), m.VersionInterval())
+	require.NoError(t, m.prune())
+	require.Equal(t, int64(15), store.prunedVersion)
+
+	// skipped until 10 versions are committed since the last prune
+	store.latestVersion = 34
+	require.NoError(t, m.prune())
+	require.Equal(t, int64(15), store.prunedVersion)
+	store.latestVersion = 35
+	require.NoError(t, m.prune())
+	require.Equal(t, int64(25), store.prunedVersion)
+}
+
+func TestPinVersion(t *testing.T) {
+	store := &mockStateStore{latestVersion: 25}
+	m := NewPruningManager(log.NewNopLogger(), store, 10, 60)
+
+	require.NoError(t, m.Pin(8))
+	require.NoError(t, m.Pin(8))
+	require.NoError(t, m.prune())
+	require.Equal(t, int64(7), store.prunedVersion)
+
+	// still pinned once
+	m.Unpin(8)
+	prunedVersion, err := m.PruneUpTo(12)
+	require.NoError(t, err)
+	require.Equal(t, int64(7), prunedVersion)
+
+	m.Unpin(8)
+	prunedVersion, err = m.PruneUpTo(12)
+	require.NoError(t, err)
+	require.Equal(t, int64(12), prunedVersion)
+	require.Equal(t, int64(12), store.prunedVersion)
+
+	require.Error(t, m.Pin(12))
+	require.NoError(t, m.Pin(13))
+}
+
+func TestUpdateConfig(t *testing.T) {
+	store := &mockStateStore{latestVersion: 25}
+	m := NewPruningManager(log.NewNopLogger(), store, 0, 0)
+	m.Start()
+	require.False(t, m.running())
+
+	require.Error(t, m.UpdateConfig(-1, 60))
+	require.Error(t, m.UpdateConfig(10, -1))
+
+	// pinned versions are protected when keep-recent is lowered
+	require.NoError(t, m.Pin(18))
+	require.NoError(t, m.UpdateConfig(5, 3600))
+	keepRecent, pruneInterval := m.Config()
+	require.Equal(t, int64(5), keepRecent)
+	require.Equal(t, int64(3600), pruneInterval)
+	require.Eventually(t, func() bool {
+		m.mtx.Lock()
+		defer m.mtx.Unlock()
+		return m.prunedVersion == 17
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// the prune loop stops once pruning is disabled
+	require.NoError(t, m.UpdateConfig(0, 3600))
+	require.False(t, m.running())
+}
+
+func TestReset(t *testing.T) {
+	store := &mockStateStore{latestVersion: 25}
+	m := NewPruningManager(log.NewNopLogger(), store, 10, 60)
+	require.NoError(t, m.prune())
+	require.Error(t, m.Pin(15))
+
+	require.Error(t, m.Reset(func() (sstypes.StateStore, error) {
+		return nil, errors.New("disk failure")
+	}))
+	require.Same(t, store, m.stateStore)
+
+	newStore := &mockStateStore{latestVersion: 15}
+	require.NoError(t, m.Reset(func() (sstypes.StateStore, error) {
+		return newStore, nil
+	}))
+	// the versions pruned in the previous state store can be pinned again
+	require.NoError(t, m.Pin(3))
+	require.NoError(t, m.prune())
+	require.Equal(t, int64(2), newStore.prunedVersion)
+	require.Equal(t, int64(15), store.prunedVersion)
+}
+
+func TestStop(t *testing.T) {
+	store := &mockStateStore{latestVersion: 25}
+	m := NewPruningManager(log.NewNopLogger(), store, 10, 60)
+	m.Stop()
+	require.NoError(t, m.prune())
+	require.Zero(t, store.prunedVersion)
+	_, err := m.PruneUpTo(5)
+	require.Error(t, err)
+	require.Zero(t, store.prunedVersion)
+
+	// the stopped manager isn't started again
+	m.Start()
+	require.False(t, m.running())
+}
+
+// blockingStateStore blocks the prunes until released.
+type blockingStateStore struct {
+	*mockStateStore
+	pruning chan int64
+	release chan struct{}
+}
+
+func (s *blockingStateStore) Prune(version int64) error {
+	s.pruning <- version
+	<-s.release
+	return s.mockStateStore.Prune(version)
+}
+
+func TestPinDuringPrune(t *testing.T) {
+	store := &blockingStateStore{
+		mockStateStore: &mockStateStore{latestVersion: 25},
+		pruning:        make(chan int64),
+		release:        make(chan struct{}),
+	}
+	m := NewPruningManager(log.NewNopLogger(), store, 10, 60)
+	done := make(chan error)
+	go func() {
+		done <- m.prune()
+	}()
+	require.Equal(t, int64(15), <-store.pruning)
+
+	// the pins don't wait for the prune, the versions being pruned can't be pinned
+	require.Error(t, m.Pin(15))
+	require.NoError(t, m.Pin(16))
+	m.Unpin(16)
+	_, pruneInterval := m.Config()
+	require.Equal(t, int64(60), pruneInterval)
+
+	close(store.release)
+	require.NoError(t, <-done)
+	require.Equal(t, int64(15), store.prunedVersion)
+	require.Error(t, m.Pin(15))
+	require.NoError(t, m.Pin(16))
+}
@@ -0,0 +1,194 @@
+package rootmulti
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+
+	"github.com/sei-protocol/sei-db/common/utils"
+	"github.com/sei-protocol/sei-db/proto"
+	"github.com/tidwall/wal"
+)
+
+// ErrChangelogUnavailable is returned by ChangeSetAt if the version is not retained in the sc changelog.
+var ErrChangelogUnavailable = fmt.Errorf("changelog of the version is not available")
+
+// ChangeSetAt returns the changesets committed at the version, read from the changelog of the sc store,
+// the version must be within the window retained by the changelog.
+func (rs *Store) ChangeSetAt(version int64) ([]*proto.NamedChangeSet, error) {
+	var changeSets []*proto.NamedChangeSet
+	if err := rs.iterateChangeSets(version, version, func(_ int64, cs []*proto.NamedChangeSet) error {
+		changeSets = cs
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return changeSets, nil
+}
+
+// iterateChangeSets calls fn with the changesets of each version in [fromVersion, toVersion] in order,
+// the versions must be within the window retained by the changelog.
+func (rs *Store) iterateChangeSets(fromVersion, toVersion int64, fn func(version int64, changeSets []*proto.NamedChangeSet) error) error {
+	if fromVersion <= 0 {
+		return fmt.Errorf("invalid version: %d", fromVersion)
+	}
+	log, err := openChangelogReader(utils.GetChangelogPath(rs.scDir))
+	if err != nil {
+		return err
+	}
+
+	firstIndex, firstVersion, lastVersion, err := changelogRange(log)
+	if err != nil {
+		if err == errChangelogEmpty {
+			return changelogUnavailable(fromVersion, err.Error())
+		}
+		return err
+	}
+	if fromVersion < firstVersion {
+		return changelogUnavailable(fromVersion, fmt.Sprintf("retained versions are [%d, %d]", firstVersion, lastVersion))
+	}
+	if toVersion > lastVersion {
+		return changelogUnavailable(toVersion, fmt.Sprintf("retained versions are [%d, %d]", firstVersion, lastVersion))
+	}
+	for version := fromVersion; version <= toVersion; version++ {
+		entry, err := readChangelogEntry(log, firstIndex+uint64(version-firstVersion))
+		if err != nil {
+			return err
+		}
+		if entry.Version != version {
+			return fmt.Errorf("changelog entry version %d doesn't match the version %d", entry.Version, version)
+		}
+		if err := fn(version, entry.Changesets); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var errChangelogEmpty = fmt.Errorf("the changelog is empty")
+
+// changelogRange returns the first index of the changelog and the range of versions it retains.
+func changelogRange(log *changelogReader) (firstIndex uint64, firstVersion, lastVersion int64, err error) {
+	firstIndex, lastIndex := log.firstIndex, log.lastIndex
+	if firstIndex == 0 || lastIndex == 0 {
+		return 0, 0, 0, errChangelogEmpty
+	}
+	first, err := readChangelogEntry(log, firstIndex)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	// the changelog indexes are contiguous with the versions, starting from the initial version
+	return firstIndex, first.Version, first.Version + int64(lastIndex-firstIndex), nil
+}
+
+func changelogUnavailable(version int64, reason string) error {
+	return fmt.Errorf("%w: version %d, %s", ErrChangelogUnavailable, version, reason)
+}
+
+func readChangelogEntry(log *changelogReader, index uint64) (*proto.ChangelogEntry, error) {
+	bz, err := log.Read(index)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read changelog at index %d: %w", index, err)
+	}
+	var entry proto.ChangelogEntry
+	if err := entry.Unmarshal(bz); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal changelog at index %d: %w", index, err)
+	}
+	return &entry, nil
+}
+
+// changelogReader reads the changelog of the sc store without opening it with wal.Open, which takes it as a
+// writer: it repairs and renames the segment files, and fails on the half-written tail of a changelog being
+// appended to by memiavl. The segment files are read as they are, the incomplete entries at the tail of the
+// last segment are ignored.
+type changelogReader struct {
+	dir string
+	// segments are the first indexes of the segment files, in order
+	segments   []uint64
+	firstIndex uint64
+	lastIndex  uint64
+	// the entries of the last segment read
+	cachedSegment int
+	cachedEntries [][]byte
+}
+
+func openChangelogReader(dir string) (*changelogReader, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	log := &changelogReader{dir: dir, cachedSegment: -1}
+	for _, entry := range entries {
+		// the .START and .END files are the temporary segments of an in-progress truncation
+		if name := entry.Name(); !entry.IsDir() && len(name) == 20 {
+			if index, err := strconv.ParseUint(name, 10, 64); err == nil && index > 0 {
+				log.segments = append(log.segments, index)
+			}
+		}
+	}
+	if len(log.segments) == 0 {
+		return log, nil
+	}
+	sort.Slice(log.segments, func(i, j int) bool { return log.segments[i] < log.segments[j] })
+	last := len(log.segments) - 1
+	if err := log.loadSegment(last); err != nil {
+		return nil, err
+	}
+	log.firstIndex = log.segments[0]
+	// the last segment is empty right after the changelog cycled to it
+	log.lastIndex = log.segments[last] + uint64(len(log.cachedEntries)) - 1
+	if log.lastIndex < log.firstIndex {
+		log.firstIndex, log.lastIndex = 0, 0
+	}
+	return log, nil
+}
+
+// Read returns the data of the entry at the index.
+func (log *changelogReader) Read(index uint64) ([]byte, error) {
+	if index < log.firstIndex || index > log.lastIndex {
+		return nil, wal.ErrNotFound
+	}
+	i := sort.Search(len(log.segments), func(i int) bool { return log.segments[i] > index }) - 1
+	if i != log.cachedSegment {
+		if err := log.loadSegment(i); err != nil {
+			return nil, err
+		}
+	}
+	offset := index - log.segments[i]
+	if offset >= uint64(len(log.cachedEntries)) {
+		return nil, wal.ErrCorrupt
+	}
+	return log.cachedEntries[offset], nil
+}
+
+// loadSegment reads the entries of the i-th segment, each is encoded as its uvarint size followed by its data.
+func (log *changelogReader) loadSegment(i int) error {
+	path := filepath.Join(log.dir, fmt.Sprintf("%020d", log.segments[i]))
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		// removed by a truncation since the segments were listed
+		return fmt.Errorf("%w: segment %s is truncated", ErrChangelogUnavailable, path)
+	}
+	if err != nil {
+		return err
+	}
+	var entries [][]byte
+	for len(data) > 0 {
+		size, n := binary.Uvarint(data)
+		if n <= 0 || uint64(len(data)-n) < size {
+			if i == len(log.segments)-1 {
+				// the tail being appended to
+				break
+			}
+			return fmt.Errorf("%w: segment %s", wal.ErrCorrupt, path)
+		}
+		entries = append(entries, data[n:n+int(size)])
+		data = data[n+int(size):]
+	}
+	log.cachedSegment, log.cachedEntries = i, entries
+	return nil
+}
@@ -0,0 +1,84 @@
+package rootmulti
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cosmos/cosmos-sdk/store/types"
+	"github.com/sei-protocol/sei-db/common/utils"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChangeSetAt(t *testing.T) {
+	bank, acc := types.NewKVStoreKey("bank"), types.NewKVStoreKey("acc")
+	for _, initialVersion := range []int64{0, 100} {
+		store := newTestStore(t, false, bank, acc)
+		if initialVersion > 0 {
+			require.NoError(t, store.SetInitialVersion(initialVersion))
+		}
+		store.GetKVStore(bank).Set([]byte("a"), []byte("1"))
+		store.GetKVStore(acc).Set([]byte("a"), []byte("1"))
+		first := store.Commit(true).Version
+		store.GetKVStore(bank).Delete([]byte("a"))
+		second := store.Commit(true).Version
+		store.Commit(true)
+
+		changeSets, err := store.ChangeSetAt(first)
+		require.NoError(t, err)
+		require.Len(t, changeSets, 2)
+		require.Equal(t, "acc", changeSets[0].Name)
+		require.Equal(t, "bank", changeSets[1].Name)
+		require.Equal(t, []byte("1"), changeSets[1].Changeset.Pairs[0].Value)
+
+		changeSets, err = store.ChangeSetAt(second)
+		require.NoError(t, err)
+		require.Len(t, changeSets, 1)
+		require.Equal(t, "bank", changeSets[0].Name)
+		require.True(t, changeSets[0].Changeset.Pairs[0].Delete)
+
+		changeSets, err = store.ChangeSetAt(second + 1)
+		require.NoError(t, err)
+		require.Empty(t, changeSets)
+
+		_, err = store.ChangeSetAt(second + 2)
+		require.ErrorIs(t, err, ErrChangelogUnavailable)
+		if initialVersion > 0 {
+			_, err = store.ChangeSetAt(initialVersion - 1)
+			require.ErrorIs(t, err, ErrChangelogUnavailable)
+		}
+		_, err = store.ChangeSetAt(0)
+		require.Error(t, err)
+	}
+}
+
+func TestChangeSetAtHalfWrittenTail(t *testing.T) {
+	bank := types.NewKVStoreKey("bank")
+	store := newTestStore(t, false, bank)
+	store.GetKVStore(bank).Set([]byte("a"), []byte("1"))
+	version := store.Commit(true).Version
+
+	// an entry being appended to the last segment
+	dir := utils.GetChangelogPath(store.scDir)
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	segment := filepath.Join(dir, entries[len(entries)-1].Name())
+	f, err := os.OpenFile(segment, os.O_APPEND|os.O_WRONLY, 0)
+	require.NoError(t, err)
+	_, err = f.Write([]byte{100, 1, 2})
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	before, err := os.ReadFile(segment)
+	require.NoError(t, err)
+
+	changeSets, err := store.ChangeSetAt(version)
+	require.NoError(t, err)
+	require.Len(t, changeSets, 1)
+	_, err = store.ChangeSetAt(version + 1)
+	require.ErrorIs(t, err, ErrChangelogUnavailable)
+
+	// the changelog is left as it is
+	after, err := os.ReadFile(segment)
+	require.NoError(t, err)
+	require.Equal(t, before, after)
+}
@@ -0,0 +1,94 @@
+package rootmulti
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+
+	"github.com/sei-protocol/sei-db/proto"
+)
+
+// ChangeSetPair is a key changed between two versions of a store, Value is the new value of the key,
+// Delete is set if the key is deleted.
+type ChangeSetPair struct {
+	Key    []byte
+	Value  []byte
+	Delete bool
+}
+
+// StoreDiff returns the keys added, updated and deleted in the store between fromVersion and toVersion,
+// ordered by key. The keys written in (fromVersion, toVersion] are read from the sc changelog, and their values
+// are compared at both versions in the SS store, so both versions must be retained by the changelog and the SS store.
+func (rs *Store) StoreDiff(storeName string, fromVersion, toVersion int64) ([]ChangeSetPair, error) {
+	if rs.ssStore == nil {
+		return nil, ErrStateStoreDisabled
+	}
+	if fromVersion <= 0 || fromVersion > toVersion {
+		return nil, fmt.Errorf("invalid version range [%d, %d]", fromVersion, toVersion)
+	}
+	rs.mtx.RLock()
+	_, ok := rs.storeKeys[storeName]
+	rs.mtx.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("store not found: %s", storeName)
+	}
+	if err := rs.checkSSRetained(fromVersion, toVersion); err != nil {
+		return nil, err
+	}
+	if fromVersion == toVersion {
+		return nil, nil
+	}
+
+	// only the keys written after fromVersion can differ, a key written back to its value is dropped by diffKey
+	written := make(map[string]struct{})
+	if err := rs.iterateChangeSets(fromVersion+1, toVersion, func(_ int64, changeSets []*proto.NamedChangeSet) error {
+		for _, cs := range changeSets {
+			if cs.Name != storeName {
+				continue
+			}
+			for _, pair := range cs.Changeset.Pairs {
+				written[string(pair.Key)] = struct{}{}
+			}
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0, len(written))
+	for key := range written {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var pairs []ChangeSetPair
+	for _, key := range keys {
+		pair, changed, err := rs.diffKey(storeName, []byte(key), fromVersion, toVersion)
+		if err != nil {
+			return nil, err
+		}
+		if changed {
+			pairs = append(pairs, pair)
+		}
+	}
+	return pairs, nil
+}
+
+// diffKey compares the values of the key at the two versions.
+func (rs *Store) diffKey(storeName string, key []byte, fromVersion, toVersion int64) (ChangeSetPair, bool, error) {
+	fromValue, err := rs.ssStore.Get(storeName, fromVersion, key)
+	if err != nil {
+		return ChangeSetPair{}, false, err
+	}
+	toValue, err := rs.ssStore.Get(storeName, toVersion, key)
+	if err != nil {
+		return ChangeSetPair{}, false, err
+	}
+	switch {
+	case bytes.Equal(fromValue, toValue):
+		return ChangeSetPair{}, false, nil
+	case toValue == nil:
+		return ChangeSetPair{Key: key, Delete: true}, true, nil
+	default:
+		return ChangeSetPair{Key: key, Value: toValue}, true, nil
+	}
+}
@@ -0,0 +1,59 @@
+package rootmulti
+
+import (
+	"testing"
+
+	"github.com/cosmos/cosmos-sdk/store/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStoreDiff(t *testing.T) {
+	key := types.NewKVStoreKey("bank")
+	store := newTestStore(t, true, key)
+
+	kvStore := store.GetKVStore(key)
+	kvStore.Set([]byte("a"), []byte("1"))
+	kvStore.Set([]byte("b"), []byte("1"))
+	kvStore.Set([]byte("d"), []byte("1"))
+	store.Commit(true)
+	kvStore = store.GetKVStore(key)
+	kvStore.Set([]byte("a"), []byte("2"))
+	kvStore.Delete([]byte("b"))
+	kvStore.Set([]byte("c"), []byte("2"))
+	store.Commit(true)
+	kvStore = store.GetKVStore(key)
+	// written back to the same value
+	kvStore.Set([]byte("a"), []byte("3"))
+	store.Commit(true)
+	kvStore = store.GetKVStore(key)
+	kvStore.Set([]byte("a"), []byte("2"))
+	store.Commit(true)
+	waitForSS(t, store, 4)
+
+	diff, err := store.StoreDiff("bank", 1, 2)
+	require.NoError(t, err)
+	require.Equal(t, []ChangeSetPair{
+		{Key: []byte("a"), Value: []byte("2")},
+		{Key: []byte("b"), Delete: true},
+		{Key: []byte("c"), Value: []byte("2")},
+	}, diff)
+
+	diff, err = store.StoreDiff("bank", 2, 4)
+	require.NoError(t, err)
+	require.Empty(t, diff)
+
+	diff, err = store.StoreDiff("bank", 1, 1)
+	require.NoError(t, err)
+	require.Empty(t, diff)
+
+	_, err = store.StoreDiff("bank", 2, 1)
+	require.Error(t, err)
+	_, err = store.StoreDiff("bank", 1, 5)
+	require.Error(t, err)
+	_, err = store.StoreDiff("staking", 1, 2)
+	require.Error(t, err)
+
+	store = newTestStore(t, false, key)
+	_, err = store.StoreDiff("bank", 1, 2)
+	require.ErrorIs(t, err, ErrStateStoreDisabled)
+}
@@ -0,0 +1,265 @@
+package rootmulti
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/cosmos/cosmos-sdk/store/rootmulti"
+	"github.com/cosmos/cosmos-sdk/store/types"
+	"github.com/sei-protocol/sei-db/config"
+	"github.com/stretchr/testify/require"
+	abci "github.com/tendermint/tendermint/abci/types"
+	"github.com/tendermint/tendermint/libs/log"
+	dbm "github.com/tendermint/tm-db"
+)
+
+const (
+	differentialSeeds      = 3
+	differentialBlocks     = 60
+	differentialOpsByBlock = 30
+	differentialKeySpace   = 40
+)
+
+// differentialHarness applies the same operations to the storev2 store and to the legacy rootmulti store of
+// cosmos-sdk, which is the reference, and asserts they have identical app hashes and query results.
+type differentialHarness struct {
+	t      *testing.T
+	rng    *rand.Rand
+	keys   []types.StoreKey
+	store  *Store
+	legacy *rootmulti.Store
+	home   string
+	// stableVersion is the highest version never rolled back, the SS store is not rolled back with the sc store,
+	// so the historical queries are only compared up to it.
+	stableVersion int64
+}
+
+func newDifferentialHarness(t *testing.T, seed int64) *differentialHarness {
+	keys := []types.StoreKey{
+		types.NewKVStoreKey("acc"),
+		types.NewKVStoreKey("bank"),
+		types.NewKVStoreKey("staking"),
+	}
+	legacy := rootmulti.NewStore(dbm.NewMemDB(), log.NewNopLogger())
+	for _, key := range keys {
+		legacy.MountStoreWithDB(key, types.StoreTypeIAVL, nil)
+	}
+	require.NoError(t, legacy.LoadLatestVersion())
+	h := &differentialHarness{
+		t:             t,
+		rng:           rand.New(rand.NewSource(seed)),
+		keys:          keys,
+		legacy:        legacy,
+		home:          t.TempDir(),
+		stableVersion: -1,
+	}
+	h.openStore()
+	t.Cleanup(h.closeStore)
+	return h
+}
+
+func (h *differentialHarness) openStore() {
+	ssConfig := config.DefaultStateStoreConfig()
+	ssConfig.Enable = true
+	h.store = NewStore(h.home, log.NewNopLogger(), config.StateCommitConfig{}, ssConfig)
+	for _, key := range h.keys {
+		h.store.MountStoreWithDB(key, types.StoreTypeIAVL, nil)
+	}
+	require.NoError(h.t, h.store.LoadLatestVersion())
+}
+
+func (h *differentialHarness) closeStore() {
+	// SS must not be closed while async commits are still applied
+	waitForSS(h.t, h.store, h.store.LastCommitID().Version)
+	require.NoError(h.t, h.store.Close())
+}
+
+func (h *differentialHarness) randomKey() []byte {
+	return []byte(fmt.Sprintf("key-%03d", h.rng.Intn(differentialKeySpace)))
+}
+
+func (h *differentialHarness) randomStore() types.StoreKey {
+	return h.keys[h.rng.Intn(len(h.keys))]
+}
+
+// block applies random writes through the cache multi stores, like the deliver txs, then commits.
+func (h *differentialHarness) block() {
+	cache, legacyCache := h.store.CacheMultiStore(), h.legacy.CacheMultiStore()
+	for i := 0; i < differentialOpsByBlock; i++ {
+		key, storeKey := h.randomKey(), h.randomStore()
+		kvStore, legacyKVStore := cache.GetKVStore(storeKey), legacyCache.GetKVStore(storeKey)
+		switch h.rng.Intn(4) {
+		case 0:
+			kvStore.Delete(key)
+			legacyKVStore.Delete(key)
+		case 1:
+			// nil values are rejected while empty values are accepted
+			require.Panics(h.t, func() { kvStore.Set(key, nil) })
+			require.Panics(h.t, func() { legacyKVStore.Set(key, nil) })
+			kvStore.Set(key, []byte{})
+			legacyKVStore.Set(key, []byte{})
+		default:
+			value := []byte(fmt.Sprintf("value-%d", h.rng.Int()))
+			kvStore.Set(key, value)
+			legacyKVStore.Set(key, value)
+		}
+		require.Equal(h.t, legacyKVStore.Get(key), kvStore.Get(key))
+	}
+	cache.Write()
+	legacyCache.Write()
+	require.Equal(h.t, h.legacy.Commit(true), h.store.Commit(true))
+}
+
+// compareLatest compares the reads, iterations and queries of the latest version.
+func (h *differentialHarness) compareLatest() {
+	for _, storeKey := range h.keys {
+		kvStore, legacyKVStore := h.store.GetKVStore(storeKey), h.legacy.GetKVStore(storeKey)
+		for i := 0; i < 5; i++ {
+			key := h.randomKey()
+			require.Equal(h.t, legacyKVStore.Get(key), kvStore.Get(key))
+			require.Equal(h.t, legacyKVStore.Has(key), kvStore.Has(key))
+		}
+		start, end := h.randomKey(), h.randomKey()
+		if string(start) > string(end) {
+			start, end = end, start
+		}
+		for _, bounds := range [][2][]byte{{nil, nil}, {start, nil}, {nil, end}, {start, end}} {
+			require.Equal(h.t, collectIterator(legacyKVStore.Iterator(bounds[0], bounds[1])), collectIterator(kvStore.Iterator(bounds[0], bounds[1])))
+			require.Equal(h.t, collectIterator(legacyKVStore.ReverseIterator(bounds[0], bounds[1])), collectIterator(kvStore.ReverseIterator(bounds[0], bounds[1])))
+		}
+		// the legacy store serves height 0 from the version before the latest, so the latest height is explicit
+		h.compareQuery(storeKey, h.store.LastCommitID().Version, true)
+	}
+}
+
+// compareHistorical compares the queries at a random historical version.
+func (h *differentialHarness) compareHistorical() {
+	latest := h.store.LastCommitID().Version
+	stable := h.stableVersion
+	if stable < 0 || stable > latest-1 {
+		stable = latest - 1
+	}
+	if stable < 1 {
+		return
+	}
+	waitForSS(h.t, h.store, latest)
+	version := 1 + h.rng.Int63n(stable)
+	for _, storeKey := range h.keys {
+		h.compareQuery(storeKey, version, false)
+	}
+}
+
+func (h *differentialHarness) compareQuery(storeKey types.StoreKey, height int64, prove bool) {
+	req := abci.RequestQuery{Path: fmt.Sprintf("/%s/key", storeKey.Name()), Data: h.randomKey(), Height: height, Prove: prove}
+	res, legacyRes := h.store.Query(req), h.legacy.Query(req)
+	require.Equal(h.t, legacyRes.Code, res.Code, res.Log)
+	require.Equal(h.t, legacyRes.Value, res.Value, "store %s, height %d", storeKey.Name(), height)
+	require.Equal(h.t, legacyRes.ProofOps == nil, res.ProofOps == nil)
+}
+
+// rollback rolls both stores back to a random recent version.
+func (h *differentialHarness) rollback() {
+	latest := h.store.LastCommitID().Version
+	if latest < 3 {
+		return
+	}
+	target := latest - 1 - h.rng.Int63n(2)
+	require.NoError(h.t, h.legacy.RollbackToVersion(target))
+	// the rollback is a standalone command, the store is reopened afterwards like on restart
+	waitForSS(h.t, h.store, latest)
+	require.NoError(h.t, h.store.RollbackToVersion(target))
+	require.NoError(h.t, h.store.Close())
+	h.openStore()
+	require.Equal(h.t, h.legacy.LastCommitID(), h.store.LastCommitID())
+	if h.stableVersion < 0 || target < h.stableVersion {
+		h.stableVersion = target
+	}
+}
+
+func collectIterator(iter types.Iterator) [][2]string {
+	defer iter.Close()
+	var pairs [][2]string
+	for ; iter.Valid(); iter.Next() {
+		pairs = append(pairs, [2]string{string(iter.Key()), string(iter.Value())})
+	}
+	return pairs
+}
+
+func TestDifferentialLegacyRootMulti(t *testing.T) {
+	for seed := int64(1); seed <= differentialSeeds; seed++ {
+		seed := seed
+		t.Run(fmt.Sprintf("seed-%d", seed), func(t *testing.T) {
+			h := newDifferentialHarness(t, seed)
+			for i := 0; i < differentialBlocks; i++ {
+				h.block()
+				h.compareLatest()
+				switch h.rng.Intn(10) {
+				case 0:
+					h.rollback()
+					h.compareLatest()
+				case 1, 2:
+					h.compareHistorical()
+				}
+			}
+		})
+	}
+}
+
+// TestSSMatchesSC writes random changes to several stores in random order across many versions, and asserts
+// the SS store yields the same contents as the sc store at every version, whatever the order of the writes.
+func TestSSMatchesSC(t *testing.T) {
+	const versions = 50
+	keys := []types.StoreKey{
+		types.NewKVStoreKey("staking"),
+		types.NewKVStoreKey("acc"),
+		types.NewKVStoreKey("wasm"),
+		types.NewKVStoreKey("bank"),
+	}
+	for seed := int64(1); seed <= differentialSeeds; seed++ {
+		seed := seed
+		t.Run(fmt.Sprintf("seed-%d", seed), func(t *testing.T) {
+			rng := rand.New(rand.NewSource(seed))
+			store := newTestStore(t, true, keys...)
+			// the sc contents of each store at each version
+			expected := make(map[int64]map[string]map[string]string, versions)
+			for version := int64(1); version <= versions; version++ {
+				for _, i := range rng.Perm(len(keys)) {
+					kvStore := store.GetKVStore(keys[i])
+					for j := rng.Intn(differentialOpsByBlock); j > 0; j-- {
+						key := []byte(fmt.Sprintf("key-%03d", rng.Intn(differentialKeySpace)))
+						if rng.Intn(4) == 0 {
+							kvStore.Delete(key)
+						} else {
+							kvStore.Set(key, []byte(fmt.Sprintf("value-%d", rng.Int())))
+						}
+					}
+				}
+				require.Equal(t, version, store.Commit(true).Version)
+				expected[version] = make(map[string]map[string]string, len(keys))
+				for _, key := range keys {
+					expected[version][key.Name()] = iterateAll(store.GetKVStore(key))
+				}
+			}
+
+			waitForSS(t, store, versions)
+			compare := func(t *testing.T, version int64) {
+				for _, key := range keys {
+					actual := make(map[string]string)
+					require.NoError(t, store.iterateRangeAt(key.Name(), nil, nil, version, func(key, value []byte) bool {
+						actual[string(key)] = string(value)
+						return false
+					}))
+					require.Equal(t, expected[version][key.Name()], actual, "store %s, version %d", key.Name(), version)
+				}
+			}
+			compare(t, versions)
+			t.Run("historical", func(t *testing.T) {
+				skipHistoricalSSIteration(t)
+				for version := int64(1); version < versions; version++ {
+					compare(t, version)
+				}
+			})
+		})
+	}
+}
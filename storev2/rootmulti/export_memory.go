@@ -0,0 +1,81 @@
+package rootmulti
+
+import (
+	"fmt"
+	"runtime"
+	"time"
+
+	"github.com/cosmos/cosmos-sdk/telemetry"
+)
+
+const (
+	// exportMemorySampleBytes is the number of exported bytes between two samples of the heap in use.
+	exportMemorySampleBytes = 16 << 20
+	// exportMemoryMaxWaits bounds the number of times the export waits for the memory to be reclaimed.
+	exportMemoryMaxWaits = 50
+	exportMemoryWait     = 100 * time.Millisecond
+)
+
+// ErrExportMemoryLimit is returned by Snapshot if the heap in use stays above the export memory limit.
+var ErrExportMemoryLimit = fmt.Errorf("snapshot export exceeds the memory limit")
+
+// exportMemoryGuard samples the heap in use while exporting a snapshot, tracks its peak and
+// backpressures the export when it exceeds the limit.
+type exportMemoryGuard struct {
+	limit       uint64
+	readHeap    func() uint64
+	wait        func(time.Duration)
+	sinceSample uint64
+	peak        uint64
+}
+
+func newExportMemoryGuard(limit uint64) *exportMemoryGuard {
+	return &exportMemoryGuard{
+		limit:    limit,
+		readHeap: readHeapInuse,
+		wait:     time.Sleep,
+	}
+}
+
+// add accounts the size of an exported item, the heap is only sampled every exportMemorySampleBytes,
+// since reading the memory stats stops the world.
+func (g *exportMemoryGuard) add(size int) error {
+	g.sinceSample += uint64(size)
+	if g.sinceSample < exportMemorySampleBytes {
+		return nil
+	}
+	g.sinceSample = 0
+	heap := g.sample()
+	if g.limit == 0 || heap <= g.limit {
+		return nil
+	}
+	// give the garbage collector and the snapshot consumer a chance to release the buffered items
+	for i := 0; i < exportMemoryMaxWaits; i++ {
+		runtime.GC()
+		g.wait(exportMemoryWait)
+		if heap = g.sample(); heap <= g.limit {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: heap in use %d, limit %d", ErrExportMemoryLimit, heap, g.limit)
+}
+
+func (g *exportMemoryGuard) sample() uint64 {
+	heap := g.readHeap()
+	if heap > g.peak {
+		g.peak = heap
+		telemetry.SetGauge(float32(heap), "store", "snapshot", "export_peak_memory")
+	}
+	return heap
+}
+
+// report samples the heap a last time, so the peak is reported even for the exports smaller than a sample.
+func (g *exportMemoryGuard) report() {
+	g.sample()
+}
+
+func readHeapInuse() uint64 {
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	return stats.HeapInuse
+}
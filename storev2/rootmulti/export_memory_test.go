@@ -0,0 +1,44 @@
+package rootmulti
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newTestExportMemoryGuard(limit uint64, heaps ...uint64) *exportMemoryGuard {
+	guard := newExportMemoryGuard(limit)
+	guard.wait = func(time.Duration) {}
+	guard.readHeap = func() uint64 {
+		heap := heaps[0]
+		if len(heaps) > 1 {
+			heaps = heaps[1:]
+		}
+		return heap
+	}
+	return guard
+}
+
+func TestExportMemoryGuard(t *testing.T) {
+	// the heap is only sampled once enough bytes are exported
+	guard := newTestExportMemoryGuard(100, 200)
+	require.NoError(t, guard.add(exportMemorySampleBytes-1))
+	require.Zero(t, guard.peak)
+
+	// the export waits until the memory is reclaimed
+	guard = newTestExportMemoryGuard(100, 50, 300, 200, 80)
+	require.NoError(t, guard.add(exportMemorySampleBytes))
+	require.NoError(t, guard.add(exportMemorySampleBytes))
+	require.Equal(t, uint64(300), guard.peak)
+
+	// the export fails if the memory is never reclaimed
+	guard = newTestExportMemoryGuard(100, 200)
+	require.ErrorIs(t, guard.add(exportMemorySampleBytes), ErrExportMemoryLimit)
+
+	// the peak is tracked without limit
+	guard = newTestExportMemoryGuard(0, 500, 400)
+	require.NoError(t, guard.add(exportMemorySampleBytes))
+	guard.report()
+	require.Equal(t, uint64(500), guard.peak)
+}
@@ -0,0 +1,186 @@
+package rootmulti
+
+import (
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/cosmos/cosmos-sdk/store/cachekv"
+	"github.com/cosmos/cosmos-sdk/store/listenkv"
+	"github.com/cosmos/cosmos-sdk/store/tracekv"
+	"github.com/cosmos/cosmos-sdk/store/types"
+	"github.com/cosmos/cosmos-sdk/telemetry"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/hashicorp/golang-lru/v2/simplelru"
+	sctypes "github.com/sei-protocol/sei-db/sc/types"
+)
+
+// historicalStores is a LRU cache of the historical versions of sc store loaded to serve proof queries,
+// it keeps the most recently used versions open so repeated queries don't pay the loading cost again.
+type historicalStores struct {
+	mtx    sync.Mutex
+	cache  *simplelru.LRU[int64, *historicalStore]
+	hits   uint64
+	misses uint64
+}
+
+// historicalStore is a ref-counted historical sc store, it's closed once evicted and released by all the queries.
+type historicalStore struct {
+	sctypes.Committer
+	refs    int
+	evicted bool
+}
+
+func newHistoricalStores(size int) *historicalStores {
+	cache, err := simplelru.NewLRU[int64, *historicalStore](size, func(_ int64, store *historicalStore) {
+		// called with the mutex held
+		store.evicted = true
+		if store.refs == 0 {
+			_ = store.Close()
+		}
+	})
+	if err != nil {
+		panic(err)
+	}
+	return &historicalStores{cache: cache}
+}
+
+// acquire returns the historical store of the version, the load function is called on cache miss,
+// the returned store must be released after use.
+func (hs *historicalStores) acquire(version int64, load func() (sctypes.Committer, error)) (*historicalStore, error) {
+	if store := hs.get(version); store != nil {
+		telemetry.IncrCounter(1, "store", "historical_sc_cache", "hit")
+		return store, nil
+	}
+	telemetry.IncrCounter(1, "store", "historical_sc_cache", "miss")
+
+	committer, err := load()
+	if err != nil {
+		return nil, err
+	}
+
+	hs.mtx.Lock()
+	defer hs.mtx.Unlock()
+	if store, ok := hs.cache.Get(version); ok {
+		// loaded concurrently by another query
+		_ = committer.Close()
+		store.refs++
+		return store, nil
+	}
+	store := &historicalStore{Committer: committer, refs: 1}
+	hs.cache.Add(version, store)
+	return store, nil
+}
+
+func (hs *historicalStores) get(version int64) *historicalStore {
+	hs.mtx.Lock()
+	defer hs.mtx.Unlock()
+	store, ok := hs.cache.Get(version)
+	if !ok {
+		hs.misses++
+		return nil
+	}
+	hs.hits++
+	store.refs++
+	return store
+}
+
+func (hs *historicalStores) release(store *historicalStore) {
+	hs.mtx.Lock()
+	defer hs.mtx.Unlock()
+	store.refs--
+	if store.evicted && store.refs == 0 {
+		_ = store.Close()
+	}
+}
+
+// purge evicts all the cached versions, the ones still in use are closed when released.
+func (hs *historicalStores) purge() {
+	hs.mtx.Lock()
+	defer hs.mtx.Unlock()
+	hs.cache.Purge()
+}
+
+// historicalSCStore is a read-only store of a historical sc version served by CacheMultiStoreWithVersion if SS is
+// disabled. The cache multistores are discarded without being closed, so the store doesn't hold the version, it's
+// acquired from the historical stores cache for each read and released after it, the iterators hold it until closed.
+type historicalSCStore struct {
+	rs       *Store
+	storeKey types.StoreKey
+	version  int64
+}
+
+var _ types.KVStore = (*historicalSCStore)(nil)
+
+// tree acquires the tree of the store at the version, release must be called once done with it.
+func (st *historicalSCStore) tree() (sctypes.Tree, func()) {
+	scStore, release, err := st.rs.loadHistoricalSC(st.version)
+	if err != nil {
+		panic(fmt.Errorf("failed to load sc store at version %d: %w", st.version, err))
+	}
+	return scStore.GetTreeByName(st.storeKey.Name()), release
+}
+
+func (st *historicalSCStore) GetStoreType() types.StoreType {
+	return types.StoreTypeIAVL
+}
+
+func (st *historicalSCStore) CacheWrap(storeKey types.StoreKey) types.CacheWrap {
+	return cachekv.NewStore(st, storeKey, types.DefaultCacheSizeLimit)
+}
+
+func (st *historicalSCStore) CacheWrapWithTrace(storeKey types.StoreKey, w io.Writer, tc types.TraceContext) types.CacheWrap {
+	return cachekv.NewStore(tracekv.NewStore(st, w, tc), storeKey, types.DefaultCacheSizeLimit)
+}
+
+func (st *historicalSCStore) CacheWrapWithListeners(storeKey types.StoreKey, listeners []types.WriteListener) types.CacheWrap {
+	return cachekv.NewStore(listenkv.NewStore(st, storeKey, listeners), storeKey, types.DefaultCacheSizeLimit)
+}
+
+func (st *historicalSCStore) Get(key []byte) []byte {
+	tree, release := st.tree()
+	defer release()
+	// the value may reference the files of the version, which are closed once it's evicted
+	return sdk.CopyBytes(tree.Get(key))
+}
+
+func (st *historicalSCStore) Has(key []byte) bool {
+	tree, release := st.tree()
+	defer release()
+	return tree.Has(key)
+}
+
+func (st *historicalSCStore) Set(_, _ []byte) {
+	panic("write operation is not supported")
+}
+
+func (st *historicalSCStore) Delete(_ []byte) {
+	panic("write operation is not supported")
+}
+
+func (st *historicalSCStore) Iterator(start, end []byte) types.Iterator {
+	tree, release := st.tree()
+	return &historicalSCIterator{Iterator: tree.Iterator(start, end, true), release: release}
+}
+
+func (st *historicalSCStore) ReverseIterator(start, end []byte) types.Iterator {
+	tree, release := st.tree()
+	return &historicalSCIterator{Iterator: tree.Iterator(start, end, false), release: release}
+}
+
+func (st *historicalSCStore) GetWorkingHash() ([]byte, error) {
+	panic("get working hash operation is not supported")
+}
+
+// historicalSCIterator holds the version until the iterator is closed.
+type historicalSCIterator struct {
+	types.Iterator
+	release func()
+	once    sync.Once
+}
+
+func (it *historicalSCIterator) Close() error {
+	err := it.Iterator.Close()
+	it.once.Do(it.release)
+	return err
+}
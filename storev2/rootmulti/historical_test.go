@@ -0,0 +1,138 @@
+package rootmulti
+
+import (
+	"fmt"
+	"sync/atomic"
+	"testing"
+
+	"github.com/cosmos/cosmos-sdk/store/types"
+	sctypes "github.com/sei-protocol/sei-db/sc/types"
+	"github.com/stretchr/testify/require"
+)
+
+type mockCommitter struct {
+	sctypes.Committer
+	version int64
+	closed  bool
+}
+
+func (c *mockCommitter) Close() error {
+	c.closed = true
+	return nil
+}
+
+func TestHistoricalStoresCache(t *testing.T) {
+	cache := newHistoricalStores(1)
+	loaded := make(map[int64]*mockCommitter)
+	load := func(version int64) func() (sctypes.Committer, error) {
+		return func() (sctypes.Committer, error) {
+			loaded[version] = &mockCommitter{version: version}
+			return loaded[version], nil
+		}
+	}
+
+	store, err := cache.acquire(1, load(1))
+	require.NoError(t, err)
+	cache.release(store)
+	require.Equal(t, uint64(0), cache.hits)
+	require.Equal(t, uint64(1), cache.misses)
+
+	store, err = cache.acquire(1, load(1))
+	require.NoError(t, err)
+	require.Equal(t, loaded[1], store.Committer)
+	cache.release(store)
+	require.Equal(t, uint64(1), cache.hits)
+	require.False(t, loaded[1].closed)
+
+	// loading version 2 evicts and closes version 1
+	store, err = cache.acquire(2, load(2))
+	require.NoError(t, err)
+	require.True(t, loaded[1].closed)
+	require.Equal(t, uint64(2), cache.misses)
+
+	// evicted stores in use are closed once released
+	cache.purge()
+	require.False(t, loaded[2].closed)
+	cache.release(store)
+	require.True(t, loaded[2].closed)
+	require.Equal(t, 0, cache.cache.Len())
+}
+
+// historicalLoader serves the historical versions from other sc stores, since the sc store can't load
+// a historical version while it's open.
+type historicalLoader struct {
+	sctypes.Committer
+	versions map[int64]sctypes.Committer
+	closed   int32
+}
+
+func (l *historicalLoader) LoadVersion(version int64, _ bool) (sctypes.Committer, error) {
+	committer, ok := l.versions[version]
+	if !ok {
+		return nil, fmt.Errorf("version %d does not exist", version)
+	}
+	return &trackedCommitter{Committer: committer, closed: &l.closed}, nil
+}
+
+type trackedCommitter struct {
+	sctypes.Committer
+	closed *int32
+}
+
+func (c *trackedCommitter) Close() error {
+	atomic.AddInt32(c.closed, 1)
+	return nil
+}
+
+func TestCacheMultiStoreWithVersionSCFallback(t *testing.T) {
+	key := types.NewKVStoreKey("bank")
+	store, past := newTestStore(t, false, key), newTestStore(t, false, key)
+	for i := 1; i <= 3; i++ {
+		for _, s := range []*Store{store, past} {
+			if s == past && i == 3 {
+				continue
+			}
+			s.GetKVStore(key).Set([]byte("a"), []byte{byte(i)})
+			s.GetKVStore(key).Set([]byte{byte('a' + i)}, []byte{byte(i)})
+			s.Commit(true)
+		}
+	}
+	loader := &historicalLoader{Committer: store.scStore, versions: map[int64]sctypes.Committer{2: past.scStore}}
+	store.scStore = loader
+
+	cms, err := store.CacheMultiStoreWithVersion(2)
+	require.NoError(t, err)
+	kvStore := cms.GetKVStore(key)
+	require.Equal(t, []byte{2}, kvStore.Get([]byte("a")))
+	require.Nil(t, kvStore.Get([]byte("d")))
+	require.True(t, kvStore.Has([]byte("b")))
+	require.Equal(t, [][2]string{{"a", "\x02"}, {"b", "\x01"}, {"c", "\x02"}}, collectIterator(kvStore.Iterator(nil, nil)))
+	// the historical version is read-only
+	require.Panics(t, func() {
+		cms, err := store.CacheMultiStoreWithVersion(2)
+		require.NoError(t, err)
+		cms.GetKVStore(key).Set([]byte("a"), []byte{4})
+		cms.Write()
+	})
+	// the latest version is not affected
+	require.Equal(t, []byte{3}, store.GetKVStore(key).Get([]byte("a")))
+	require.Equal(t, []byte{3}, store.GetKVStore(key).Get([]byte("d")))
+
+	// the version is loaded once and stays in the cache, an open iterator holds it once evicted
+	iter := kvStore.Iterator(nil, nil)
+	store.historicalStores.purge()
+	require.Zero(t, atomic.LoadInt32(&loader.closed))
+	require.NoError(t, iter.Close())
+	require.Equal(t, int32(1), atomic.LoadInt32(&loader.closed))
+	// the discarded cache multistore doesn't hold the version, the next read loads it again
+	require.Equal(t, []byte{2}, kvStore.Get([]byte("a")))
+	store.historicalStores.purge()
+	require.Equal(t, int32(2), atomic.LoadInt32(&loader.closed))
+
+	_, err = store.CacheMultiStoreWithVersion(1)
+	require.ErrorContains(t, err, "failed to load sc store at version 1")
+
+	store.queries.noHistoryError = true
+	_, err = store.CacheMultiStoreWithVersion(2)
+	require.ErrorContains(t, err, "node does not retain history")
+}
@@ -0,0 +1,80 @@
+package rootmulti
+
+import (
+	"fmt"
+
+	"github.com/cosmos/cosmos-sdk/telemetry"
+	"github.com/cosmos/iavl"
+	"github.com/sei-protocol/sei-db/proto"
+)
+
+// storeCommitHookBufferSize bounds the number of committed changesets queued for the store commit hooks,
+// the changesets are dropped once it's full so slow hooks can't block the commits.
+const storeCommitHookBufferSize = 1024
+
+// StoreCommitHook is notified of the changes of a store once they are committed at the version.
+type StoreCommitHook func(version int64, cs *iavl.ChangeSet)
+
+type storeCommitEvent struct {
+	version int64
+	cs      *proto.NamedChangeSet
+}
+
+// RegisterStoreCommitHook registers a hook called after each commit which changed the store, the hooks run
+// sequentially in a background routine, so they must not modify the changeset and can't block the commits.
+func (rs *Store) RegisterStoreCommitHook(storeName string, fn StoreCommitHook) {
+	rs.hooksMtx.Lock()
+	defer rs.hooksMtx.Unlock()
+	if rs.storeCommitHooks == nil {
+		rs.storeCommitHooks = make(map[string][]StoreCommitHook)
+		rs.storeCommitEvents = make(chan storeCommitEvent, storeCommitHookBufferSize)
+		go rs.runStoreCommitHooks(rs.storeCommitEvents)
+	}
+	rs.storeCommitHooks[storeName] = append(rs.storeCommitHooks[storeName], fn)
+}
+
+func (rs *Store) getStoreCommitHooks(storeName string) []StoreCommitHook {
+	rs.hooksMtx.RLock()
+	defer rs.hooksMtx.RUnlock()
+	return rs.storeCommitHooks[storeName]
+}
+
+// stageStoreCommitHooks keeps the flushed changesets of the stores with hooks until they are committed.
+func (rs *Store) stageStoreCommitHooks(changeSets []*proto.NamedChangeSet) {
+	for _, cs := range changeSets {
+		if len(rs.getStoreCommitHooks(cs.Name)) > 0 {
+			rs.stagedHookChanges = append(rs.stagedHookChanges, cs)
+		}
+	}
+}
+
+// fireStoreCommitHooks queues the staged changesets for the hooks once the version is committed.
+func (rs *Store) fireStoreCommitHooks(version int64) {
+	for _, cs := range rs.stagedHookChanges {
+		select {
+		case rs.storeCommitEvents <- storeCommitEvent{version: version, cs: cs}:
+		default:
+			rs.logger.Error("store commit hooks buffer is full, dropping the changeset", "store", cs.Name, "version", version)
+			telemetry.IncrCounter(1, "store", "commit_hook", "dropped")
+		}
+	}
+	rs.stagedHookChanges = nil
+}
+
+func (rs *Store) runStoreCommitHooks(events <-chan storeCommitEvent) {
+	for event := range events {
+		for _, hook := range rs.getStoreCommitHooks(event.cs.Name) {
+			rs.callStoreCommitHook(hook, event)
+		}
+	}
+}
+
+// callStoreCommitHook isolates the panics of a hook from the other hooks and the node.
+func (rs *Store) callStoreCommitHook(hook StoreCommitHook, event storeCommitEvent) {
+	defer func() {
+		if r := recover(); r != nil {
+			rs.logger.Error("store commit hook panicked", "store", event.cs.Name, "version", event.version, "err", fmt.Sprintf("%v", r))
+		}
+	}()
+	hook(event.version, &event.cs.Changeset)
+}
@@ -0,0 +1,57 @@
+package rootmulti
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cosmos/cosmos-sdk/store/types"
+	"github.com/cosmos/iavl"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStoreCommitHooks(t *testing.T) {
+	bankKey, stakingKey := types.NewKVStoreKey("bank"), types.NewKVStoreKey("staking")
+	store := newTestStore(t, false, bankKey, stakingKey)
+
+	type event struct {
+		store   string
+		version int64
+		pairs   []*iavl.KVPair
+	}
+	events := make(chan event, 10)
+	for _, name := range []string{"bank", "staking"} {
+		name := name
+		store.RegisterStoreCommitHook(name, func(version int64, cs *iavl.ChangeSet) {
+			events <- event{store: name, version: version, pairs: cs.Pairs}
+		})
+	}
+	// a panicking hook doesn't prevent the other hooks from running
+	store.RegisterStoreCommitHook("bank", func(int64, *iavl.ChangeSet) {
+		panic("hook failure")
+	})
+
+	store.GetKVStore(bankKey).Set([]byte("a"), []byte("1"))
+	// the hooks are only fired once the changes are committed
+	store.GetWorkingHash()
+	require.Never(t, func() bool { return len(events) > 0 }, 100*time.Millisecond, 10*time.Millisecond)
+	store.Commit(true)
+
+	select {
+	case ev := <-events:
+		require.Equal(t, event{store: "bank", version: 1, pairs: []*iavl.KVPair{{Key: []byte("a"), Value: []byte("1")}}}, ev)
+	case <-time.After(5 * time.Second):
+		t.Fatal("bank hook not fired")
+	}
+	// the staking store is not changed
+	require.Never(t, func() bool { return len(events) > 0 }, 100*time.Millisecond, 10*time.Millisecond)
+
+	store.GetKVStore(stakingKey).Delete([]byte("b"))
+	store.Commit(true)
+	select {
+	case ev := <-events:
+		require.Equal(t, "staking", ev.store)
+		require.Equal(t, int64(2), ev.version)
+	case <-time.After(5 * time.Second):
+		t.Fatal("staking hook not fired")
+	}
+}
@@ -0,0 +1,112 @@
+package rootmulti
+
+import (
+	"fmt"
+
+	"github.com/cosmos/cosmos-sdk/store/types"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	sctypes "github.com/sei-protocol/sei-db/sc/types"
+)
+
+// DefaultPrefixQueryLimit is the default maximum number of pairs returned by MultiStorePrefixQuery for a store.
+const DefaultPrefixQueryLimit = 10000
+
+// ErrPrefixQueryLimit is returned by MultiStorePrefixQuery if a store has more pairs with the prefix than the limit.
+var ErrPrefixQueryLimit = fmt.Errorf("too many pairs with the prefix")
+
+// WithPrefixQueryLimit sets the maximum number of pairs returned by MultiStorePrefixQuery for a store.
+func WithPrefixQueryLimit(limit int) Option {
+	return func(rs *Store) {
+		rs.queries.prefixLimit = limit
+	}
+}
+
+// KV is a key and its value returned by MultiStorePrefixQuery.
+type KV struct {
+	Key   []byte
+	Value []byte
+}
+
+// MultiStorePrefixQuery returns the keys with the prefix of each store at the version, grouped by store name,
+// a version <= 0 means the latest version. The version is served like the queries without proofs, the historical
+// version of the sc store is loaded once for all the stores if SS is disabled.
+// It fails with ErrPrefixQueryLimit if a store has more pairs with the prefix than the limit, the iteration stops
+// at the first pair over the limit.
+func (rs *Store) MultiStorePrefixQuery(version int64, storeNames []string, prefix []byte) (map[string][]KV, error) {
+	latest, loaded := rs.loadedVersion()
+	if !loaded {
+		return nil, fmt.Errorf("store is not loaded")
+	}
+	if version <= 0 {
+		version = latest
+	}
+	if version > latest {
+		return nil, fmt.Errorf("version %d is not committed yet, latest version is %d", version, latest)
+	}
+	for _, name := range storeNames {
+		if persistent, err := rs.IsPersistent(name); err != nil {
+			return nil, err
+		} else if !persistent {
+			return nil, fmt.Errorf("store %s is not persistent", name)
+		}
+	}
+
+	results := make(map[string][]KV, len(storeNames))
+	switch rs.queryRoute(version, latest, false) {
+	case QueryRouteSS:
+		for _, name := range storeNames {
+			var kvs []KV
+			if err := rs.IteratePrefixAt(name, prefix, version, func(key, value []byte) bool {
+				kvs = append(kvs, KV{Key: key, Value: value})
+				return len(kvs) > rs.queries.prefixLimit
+			}); err != nil {
+				return nil, err
+			}
+			if err := rs.checkPrefixQueryLimit(name, kvs); err != nil {
+				return nil, err
+			}
+			results[name] = kvs
+		}
+	case QueryRouteHistoricalSC:
+		scStore, release, err := rs.loadHistoricalSC(version)
+		if err != nil {
+			return nil, err
+		}
+		defer release()
+		for _, name := range storeNames {
+			if results[name], err = rs.iterateTreePrefix(scStore, name, prefix); err != nil {
+				return nil, err
+			}
+		}
+	default:
+		rs.mtx.RLock()
+		defer rs.mtx.RUnlock()
+		for _, name := range storeNames {
+			var err error
+			if results[name], err = rs.iterateTreePrefix(rs.scStore, name, prefix); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return results, nil
+}
+
+func (rs *Store) iterateTreePrefix(scStore sctypes.Committer, storeName string, prefix []byte) ([]KV, error) {
+	iter := scStore.GetTreeByName(storeName).Iterator(prefix, types.PrefixEndBytes(prefix), true)
+	var kvs []KV
+	for ; iter.Valid() && len(kvs) <= rs.queries.prefixLimit; iter.Next() {
+		// the iterators of the sc trees may reuse the buffers of the keys and values
+		kvs = append(kvs, KV{Key: sdk.CopyBytes(iter.Key()), Value: sdk.CopyBytes(iter.Value())})
+	}
+	if err := iter.Close(); err != nil {
+		return nil, err
+	}
+	return kvs, rs.checkPrefixQueryLimit(storeName, kvs)
+}
+
+func (rs *Store) checkPrefixQueryLimit(storeName string, kvs []KV) error {
+	if len(kvs) > rs.queries.prefixLimit {
+		return fmt.Errorf("%w: store %s, limit %d", ErrPrefixQueryLimit, storeName, rs.queries.prefixLimit)
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package rootmulti
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/cosmos/cosmos-sdk/store/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMultiStorePrefixQuery(t *testing.T) {
+	bank, acc := types.NewKVStoreKey("bank"), types.NewKVStoreKey("acc")
+	for _, ssEnabled := range []bool{true, false} {
+		t.Run(fmt.Sprintf("ss-%v", ssEnabled), func(t *testing.T) {
+			store := newTestStore(t, ssEnabled, bank, acc)
+			for i := 1; i <= 3; i++ {
+				value := []byte(fmt.Sprintf("%d", i))
+				store.GetKVStore(bank).Set([]byte(fmt.Sprintf("p/%d", i)), value)
+				store.GetKVStore(bank).Set([]byte("q"), value)
+				store.GetKVStore(acc).Set([]byte("p/a"), value)
+				store.Commit(true)
+			}
+			if ssEnabled {
+				waitForSS(t, store, 3)
+			}
+
+			results, err := store.MultiStorePrefixQuery(0, []string{"bank", "acc"}, []byte("p/"))
+			require.NoError(t, err)
+			require.Equal(t, map[string][]KV{
+				"bank": {{[]byte("p/1"), []byte("1")}, {[]byte("p/2"), []byte("2")}, {[]byte("p/3"), []byte("3")}},
+				"acc":  {{[]byte("p/a"), []byte("3")}},
+			}, results)
+
+			// the historical sc version can't be loaded while the sc store holds the db lock
+			if ssEnabled {
+				t.Run("historical", func(t *testing.T) {
+					skipHistoricalSSIteration(t)
+					results, err := store.MultiStorePrefixQuery(2, []string{"bank", "acc"}, []byte("p/"))
+					require.NoError(t, err)
+					require.Equal(t, map[string][]KV{
+						"bank": {{[]byte("p/1"), []byte("1")}, {[]byte("p/2"), []byte("2")}},
+						"acc":  {{[]byte("p/a"), []byte("2")}},
+					}, results)
+					store.queries.prefixLimit = 2
+					results, err = store.MultiStorePrefixQuery(2, []string{"bank"}, []byte("p/"))
+					require.NoError(t, err)
+					require.Len(t, results["bank"], 2)
+				})
+			}
+
+			// the stores with more pairs than the limit are rejected
+			store.queries.prefixLimit = 2
+			_, err = store.MultiStorePrefixQuery(0, []string{"bank"}, []byte("p/"))
+			require.ErrorIs(t, err, ErrPrefixQueryLimit)
+			if ssEnabled {
+				_, err = store.MultiStorePrefixQuery(3, []string{"bank"}, []byte("p/"))
+				require.ErrorIs(t, err, ErrPrefixQueryLimit)
+			}
+
+			_, err = store.MultiStorePrefixQuery(4, []string{"bank"}, []byte("p/"))
+			require.Error(t, err)
+			_, err = store.MultiStorePrefixQuery(2, []string{"staking"}, []byte("p/"))
+			require.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package rootmulti
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/sei-protocol/sei-db/common/utils"
+	"github.com/sei-protocol/sei-db/config"
+	"github.com/sei-protocol/sei-db/sc/memiavl"
+)
+
+// ProofAvailable returns whether the proofs can be generated at the version,
+// which must be within the range of versions retained by the sc store.
+func (rs *Store) ProofAvailable(version int64) (bool, error) {
+	if version <= 0 {
+		return false, fmt.Errorf("invalid version: %d", version)
+	}
+	earliest, latest, err := rs.provableRange()
+	if err != nil {
+		return false, err
+	}
+	return version >= earliest && version <= latest, nil
+}
+
+// EarliestProvableVersion returns the earliest version the proofs can be generated at,
+// the older versions are pruned from the sc store, see WithKeepRecentProofs.
+func (rs *Store) EarliestProvableVersion() (int64, error) {
+	earliest, _, err := rs.provableRange()
+	return earliest, err
+}
+
+// snapshotKeepRecentForProofs returns the number of old snapshots the sc store must keep to retain
+// keepRecentProofs versions, a historical version is loaded from the closest older snapshot,
+// so the snapshots must cover the window. It never lowers the snapshot-keep-recent config.
+func snapshotKeepRecentForProofs(keepRecentProofs uint32, scConfig config.StateCommitConfig) uint32 {
+	interval := scConfig.SnapshotInterval
+	if interval == 0 {
+		interval = config.DefaultSnapshotInterval
+	}
+	keepRecent := keepRecentProofs / interval
+	if keepRecentProofs%interval != 0 {
+		keepRecent++
+	}
+	if keepRecent < scConfig.SnapshotKeepRecent {
+		return scConfig.SnapshotKeepRecent
+	}
+	return keepRecent
+}
+
+// provableRange returns the range of versions the sc store can load, a historical version is loaded from
+// the closest snapshot and replaying the changelog, so the earliest one is the oldest snapshot retained.
+func (rs *Store) provableRange() (earliest int64, latest int64, err error) {
+	latest = rs.scStore.Version()
+	earliest, err = earliestSnapshotVersion(rs.scDir)
+	if err != nil {
+		return 0, 0, err
+	}
+	if earliest == 0 {
+		// the initial empty snapshot, the changelog is retained from the initial version
+		earliest, err = rs.changelogFirstVersion()
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+	if earliest == 0 || earliest > latest {
+		earliest = latest
+	}
+	return earliest, latest, nil
+}
+
+func (rs *Store) changelogFirstVersion() (int64, error) {
+	log, err := openChangelogReader(utils.GetChangelogPath(rs.scDir))
+	if err != nil {
+		return 0, err
+	}
+	_, firstVersion, _, err := changelogRange(log)
+	if err == errChangelogEmpty {
+		return 0, nil
+	}
+	return firstVersion, err
+}
+
+// earliestSnapshotVersion returns the lowest version of the snapshots in the sc directory.
+func earliestSnapshotVersion(dir string) (int64, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return 0, err
+	}
+	earliest := int64(-1)
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.IsDir() || !strings.HasPrefix(name, memiavl.SnapshotPrefix) || len(name) != memiavl.SnapshotDirLen {
+			continue
+		}
+		version, err := strconv.ParseInt(name[len(memiavl.SnapshotPrefix):], 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid snapshot name %s: %w", name, err)
+		}
+		if earliest < 0 || version < earliest {
+			earliest = version
+		}
+	}
+	if earliest < 0 {
+		return 0, fmt.Errorf("no snapshot found in %s", dir)
+	}
+	return earliest, nil
+}
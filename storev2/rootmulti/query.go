@@ -0,0 +1,353 @@
+package rootmulti
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"cosmossdk.io/errors"
+	metrics "github.com/armon/go-metrics"
+	"github.com/cosmos/cosmos-sdk/store/rootmulti"
+	"github.com/cosmos/cosmos-sdk/store/types"
+	"github.com/cosmos/cosmos-sdk/storev2/commitment"
+	"github.com/cosmos/cosmos-sdk/storev2/state"
+	"github.com/cosmos/cosmos-sdk/telemetry"
+	sdkerrors "github.com/cosmos/cosmos-sdk/types/errors"
+	sctypes "github.com/sei-protocol/sei-db/sc/types"
+	abci "github.com/tendermint/tendermint/abci/types"
+	"go.opentelemetry.io/otel/attribute"
+	otrace "go.opentelemetry.io/otel/trace"
+)
+
+// Routes of the abci queries, see QueryRouteFor.
+const (
+	// QueryRouteSS serves the historical queries without proofs from the state store.
+	QueryRouteSS = "ss"
+	// QueryRouteHistoricalSC serves the historical queries by loading the historical version of the sc store.
+	QueryRouteHistoricalSC = "historical_sc"
+	// QueryRouteLatestSC serves the queries at latest version from the sc store.
+	QueryRouteLatestSC = "latest_sc"
+)
+
+// DefaultVersionSearchLimit is the default number of versions searched backwards by LastVersionWithKey and
+// GetWithVersion, see WithVersionSearchLimit.
+const DefaultVersionSearchLimit = 1000
+
+// ErrVersionSearchLimit is returned by LastVersionWithKey if the key isn't found within the versions it searches.
+var ErrVersionSearchLimit = fmt.Errorf("version search limit reached")
+
+// queryConfig are the options of the queries served by the store.
+type queryConfig struct {
+	// tracer traces the abci queries, nil if disabled.
+	tracer otrace.Tracer
+	// strictPaths rejects the query paths with empty segments.
+	strictPaths bool
+	// noHistoryError rejects the historical queries without proofs if SS is disabled,
+	// instead of loading the historical sc store to serve them.
+	noHistoryError bool
+	// rangeLimit is the maximum number of pairs returned by a range query.
+	rangeLimit int
+	// prefixLimit is the maximum number of pairs returned by MultiStorePrefixQuery for a store.
+	prefixLimit int
+	// versionSearchLimit is the maximum number of versions searched by LastVersionWithKey and GetWithVersion.
+	versionSearchLimit int64
+}
+
+// WithTracer traces each abci query in a span tagged with the store name, version, proof flag and route.
+func WithTracer(tracer otrace.Tracer) Option {
+	return func(rs *Store) {
+		rs.queries.tracer = tracer
+	}
+}
+
+// WithStrictQueryPaths rejects the query paths with empty segments instead of trimming their trailing slashes.
+func WithStrictQueryPaths() Option {
+	return func(rs *Store) {
+		rs.queries.strictPaths = true
+	}
+}
+
+// WithNoHistoryQueryError rejects the historical queries without proofs if SS is disabled.
+func WithNoHistoryQueryError() Option {
+	return func(rs *Store) {
+		rs.queries.noHistoryError = true
+	}
+}
+
+// WithVersionSearchLimit bounds the number of versions searched by LastVersionWithKey and GetWithVersion.
+func WithVersionSearchLimit(versions int64) Option {
+	return func(rs *Store) {
+		if versions <= 0 {
+			panic(fmt.Sprintf("invalid version search limit: %d", versions))
+		}
+		rs.queries.versionSearchLimit = versions
+	}
+}
+
+// LastVersionWithKey returns the latest version at which the key had a non-deleted value in the SS store,
+// it searches backwards from the latest version and stops at the earliest version retained by the SS store.
+// At most the version search limit versions are searched, it fails with ErrVersionSearchLimit if the key isn't found
+// within them while older versions are retained.
+func (rs *Store) LastVersionWithKey(storeName string, key []byte) (int64, bool, error) {
+	if rs.ssStore == nil {
+		return 0, false, ErrStateStoreDisabled
+	}
+	latest, err := rs.ssStore.GetLatestVersion()
+	if err != nil {
+		return 0, false, err
+	}
+	earliest, floor := rs.versionSearchWindow(latest)
+	for version := latest; version >= floor; version-- {
+		value, err := rs.ssStore.Get(storeName, version, key)
+		if err != nil {
+			return 0, false, err
+		}
+		if value != nil {
+			return version, true, nil
+		}
+	}
+	if floor > earliest {
+		return 0, false, fmt.Errorf("%w: key not found in versions [%d, %d]", ErrVersionSearchLimit, floor, latest)
+	}
+	return 0, false, nil
+}
+
+// versionSearchWindow returns the earliest version retained by the SS store, and the earliest version searched
+// backwards from the latest one within the version search limit.
+func (rs *Store) versionSearchWindow(latest int64) (earliest, floor int64) {
+	earliest = rs.ssEarliestVersion()
+	floor = earliest
+	if latest-rs.queries.versionSearchLimit+1 > floor {
+		floor = latest - rs.queries.versionSearchLimit + 1
+	}
+	return earliest, floor
+}
+
+// GetWithVersion returns the latest value of the key from the sc store, together with the version it was last written at,
+// found by searching the SS store backwards from its latest version within the version search limit. The version is -1
+// if it can't be determined within the versions searched, or if the key doesn't exist.
+func (rs *Store) GetWithVersion(storeName string, key []byte) ([]byte, int64, error) {
+	if rs.ssStore == nil {
+		return nil, -1, ErrStateStoreDisabled
+	}
+	rs.mtx.RLock()
+	storeKey, ok := rs.storeKeys[storeName]
+	if !ok || rs.storesParams[storeKey].typ != types.StoreTypeIAVL {
+		rs.mtx.RUnlock()
+		return nil, -1, fmt.Errorf("store not found: %s", storeName)
+	}
+	value := rs.scStore.GetTreeByName(storeName).Get(key)
+	rs.mtx.RUnlock()
+	if value == nil {
+		return nil, -1, nil
+	}
+	latest, err := rs.ssStore.GetLatestVersion()
+	if err != nil {
+		return nil, -1, err
+	}
+	_, floor := rs.versionSearchWindow(latest)
+	for version := latest; version >= floor; version-- {
+		ssValue, err := rs.ssStore.Get(storeName, version, key)
+		if err != nil {
+			return nil, -1, err
+		}
+		if bytes.Equal(ssValue, value) {
+			continue
+		}
+		if version == latest {
+			// the value is written after the latest version applied to SS
+			return value, -1, nil
+		}
+		return value, version + 1, nil
+	}
+	if floor > 1 {
+		// the value may be written before the versions searched, pruned or beyond the limit
+		return value, -1, nil
+	}
+	return value, floor, nil
+}
+
+// QueryWorking returns the value of the key in the working state of the store, i.e. including the writes of the
+// current block not committed yet, it reflects the in-flight block state so the result is not provable.
+func (rs *Store) QueryWorking(storeName string, key []byte) ([]byte, error) {
+	rs.mtx.RLock()
+	defer rs.mtx.RUnlock()
+	storeKey, ok := rs.storeKeys[storeName]
+	if !ok || rs.storesParams[storeKey].typ != types.StoreTypeIAVL {
+		return nil, fmt.Errorf("store not found: %s", storeName)
+	}
+	store, ok := rs.ckvStores[storeKey].(*commitment.Store)
+	if !ok {
+		return nil, fmt.Errorf("store %s is not a commitment store", storeName)
+	}
+	return store.GetWorking(key), nil
+}
+
+// Implements interface Queryable
+func (rs *Store) Query(req abci.RequestQuery) abci.ResponseQuery {
+	// the query is routed against the version committed when it's received
+	latest, loaded := rs.loadedVersion()
+	if !loaded {
+		return sdkerrors.QueryResult(errors.Wrap(sdkerrors.ErrInvalidRequest, "store is not loaded"))
+	}
+	// height 0 means the latest version per ABCI convention, any explicit height is served as is,
+	// including the initial version of a chain started at a non-1 height.
+	version := req.Height
+	if version <= 0 {
+		version = latest
+	} else if rs.initialVersion > 1 && version < rs.initialVersion {
+		return sdkerrors.QueryResult(errors.Wrapf(sdkerrors.ErrInvalidHeight, "height %d is lower than the initial version %d", version, rs.initialVersion))
+	} else if version > latest {
+		return sdkerrors.QueryResult(errors.Wrapf(sdkerrors.ErrInvalidHeight, "height %d is above the latest version %d", version, latest))
+	}
+	path := req.Path
+	storeName, subPath, err := parsePath(path, rs.queries.strictPaths)
+	if err != nil {
+		return sdkerrors.QueryResult(err)
+	}
+	if subPath == RangeQueryPath {
+		return rs.queryRange(req, storeName, version)
+	}
+	var store types.Queryable
+
+	route := rs.queryRoute(version, latest, req.Prove)
+	defer rs.traceQuery(storeName, version, req.Prove, route)()
+	switch route {
+	case QueryRouteSS:
+		// Serve abci query from ss store if no proofs needed,
+		// the version is pinned so a pruning config update can't prune it mid-flight
+		if rs.pruningManager != nil && rs.pruningManager.Pin(version) == nil {
+			defer rs.pruningManager.Unpin(version)
+		}
+		store = types.Queryable(state.NewStore(rs.ssStore, types.NewKVStoreKey(storeName), version))
+	case QueryRouteHistoricalSC:
+		// Serve abci query from historical sc store if proofs needed
+		if !req.Prove && rs.queries.noHistoryError {
+			return sdkerrors.QueryResult(errors.Wrap(sdkerrors.ErrInvalidRequest, "node does not retain history; enable SS or run an archive node"))
+		}
+		if req.Prove {
+			if earliest, err := rs.EarliestProvableVersion(); err == nil && version < earliest {
+				return sdkerrors.QueryResult(errors.Wrapf(sdkerrors.ErrInvalidRequest, "proofs unavailable at height %d, available from %d", version, earliest))
+			}
+		}
+		scStore, release, err := rs.loadHistoricalSC(version)
+		if err != nil {
+			return sdkerrors.QueryResult(err)
+		}
+		defer release()
+		store = types.Queryable(commitment.NewStore(scStore.GetTreeByName(storeName), rs.logger))
+	default:
+		// Serve directly from latest sc store
+		store = types.Queryable(commitment.NewStore(rs.scStore.GetTreeByName(storeName), rs.logger))
+	}
+
+	// trim the path and execute the query
+	req.Path = subPath
+	res := store.Query(req)
+
+	if !req.Prove || !rootmulti.RequireProof(subPath) {
+		return res
+	}
+	if res.ProofOps == nil || len(res.ProofOps.Ops) == 0 {
+		return sdkerrors.QueryResult(errors.Wrap(sdkerrors.ErrInvalidRequest, "proof is unexpectedly empty; ensure height has not been pruned"))
+	}
+	commitInfo := convertCommitInfo(rs.scStore.LastCommitInfo())
+	commitInfo = amendCommitInfo(commitInfo, rs.extraStoreInfos)
+	// Restore origin path and append proof op.
+	res.ProofOps.Ops = append(res.ProofOps.Ops, commitInfo.ProofOp(storeName))
+	return res
+}
+
+// traceQuery starts the tracing span of a query if a tracer is configured,
+// the returned function ends the span and records the query latency.
+func (rs *Store) traceQuery(storeName string, version int64, prove bool, route string) func() {
+	start := time.Now()
+	var span otrace.Span
+	if rs.queries.tracer != nil {
+		_, span = rs.queries.tracer.Start(context.Background(), "Query", otrace.WithAttributes(
+			attribute.String("store", storeName),
+			attribute.Int64("version", version),
+			attribute.Bool("prove", prove),
+			attribute.String("route", route),
+		))
+	}
+	return func() {
+		if span != nil {
+			span.End()
+		}
+		telemetry.MeasureSinceWithLabels([]string{"store", "query"}, start, []metrics.Label{telemetry.NewLabel("route", route)})
+	}
+}
+
+// loadHistoricalSC loads the historical version of the sc store, served from the cache if enabled,
+// the returned release function must be called once the store is not used anymore.
+func (rs *Store) loadHistoricalSC(version int64) (sctypes.Committer, func(), error) {
+	load := func() (sctypes.Committer, error) {
+		return rs.scStore.LoadVersion(version, true)
+	}
+	if rs.historicalStores == nil {
+		scStore, err := load()
+		if err != nil {
+			return nil, nil, err
+		}
+		return scStore, func() { _ = scStore.Close() }, nil
+	}
+	store, err := rs.historicalStores.acquire(version, load)
+	if err != nil {
+		return nil, nil, err
+	}
+	return store, func() { rs.historicalStores.release(store) }, nil
+}
+
+// QueryRouteFor returns the route a query at the version would be served from without executing it,
+// a version <= 0 means the latest version.
+func (rs *Store) QueryRouteFor(version int64, prove bool) string {
+	latest := rs.LatestVersion()
+	if version <= 0 {
+		version = latest
+	}
+	return rs.queryRoute(version, latest, prove)
+}
+
+func (rs *Store) queryRoute(version, latest int64, prove bool) string {
+	switch {
+	case !prove && version < latest && rs.ssStore != nil:
+		return QueryRouteSS
+	case version < latest:
+		return QueryRouteHistoricalSC
+	default:
+		return QueryRouteLatestSC
+	}
+}
+
+// parsePath expects a format like /<storeName>[/<subpath>]
+// Must start with /, subpath may be empty
+// Returns error if it doesn't start with / or the store name is empty
+// The trailing slashes are trimmed, so /<storeName>/ is the same as /<storeName>,
+// in strict mode the paths with empty segments (e.g. trailing or double slashes) are rejected instead.
+func parsePath(path string, strict bool) (storeName string, subpath string, err error) {
+	if !strings.HasPrefix(path, "/") {
+		return storeName, subpath, errors.Wrapf(sdkerrors.ErrUnknownRequest, "invalid path: %s", path)
+	}
+	if strict {
+		for _, segment := range strings.Split(path[1:], "/") {
+			if segment == "" {
+				return storeName, subpath, errors.Wrapf(sdkerrors.ErrUnknownRequest, "invalid path: %s, empty segment", path)
+			}
+		}
+	}
+
+	paths := strings.SplitN(strings.TrimRight(path[1:], "/"), "/", 2)
+	storeName = paths[0]
+	if storeName == "" {
+		return storeName, subpath, errors.Wrapf(sdkerrors.ErrUnknownRequest, "invalid path: %s, empty store name", path)
+	}
+
+	if len(paths) == 2 {
+		subpath = "/" + paths[1]
+	}
+
+	return storeName, subpath, nil
+}
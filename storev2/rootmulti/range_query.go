@@ -0,0 +1,78 @@
+package rootmulti
+
+import (
+	"bytes"
+
+	"cosmossdk.io/errors"
+	sdkerrors "github.com/cosmos/cosmos-sdk/types/errors"
+	"github.com/cosmos/cosmos-sdk/types/kv"
+	abci "github.com/tendermint/tendermint/abci/types"
+)
+
+// RangeQueryPath is the subpath of the range queries, e.g. /<storeName>/range.
+const RangeQueryPath = "/range"
+
+// DefaultRangeQueryLimit is the default maximum number of pairs returned by a range query.
+const DefaultRangeQueryLimit = 1000
+
+// WithRangeQueryLimit sets the maximum number of pairs returned by a range query.
+func WithRangeQueryLimit(limit int) Option {
+	return func(rs *Store) {
+		rs.queries.rangeLimit = limit
+	}
+}
+
+// EncodeRangeQuery encodes the [start, end) bounds of a range query as the request data,
+// nil bounds are unbounded.
+func EncodeRangeQuery(start, end []byte) ([]byte, error) {
+	return (&kv.Pair{Key: start, Value: end}).Marshal()
+}
+
+// queryRange serves the range queries from the SS store at any version it retains, including the latest one.
+// The response value holds the pairs in [start, end) encoded as kv.Pairs, if there are more pairs than the limit,
+// the response key holds the key to resume the range from.
+func (rs *Store) queryRange(req abci.RequestQuery, storeName string, version int64) abci.ResponseQuery {
+	defer rs.traceQuery(storeName, version, req.Prove, QueryRouteSS)()
+	if req.Prove {
+		return sdkerrors.QueryResult(errors.Wrap(sdkerrors.ErrInvalidRequest, "proofs are not supported by range queries"))
+	}
+	if rs.ssStore == nil {
+		return sdkerrors.QueryResult(errors.Wrap(sdkerrors.ErrInvalidRequest, ErrStateStoreDisabled.Error()))
+	}
+	if _, err := rs.IsPersistent(storeName); err != nil {
+		return sdkerrors.QueryResult(errors.Wrap(sdkerrors.ErrUnknownRequest, err.Error()))
+	}
+	var bounds kv.Pair
+	if err := bounds.Unmarshal(req.Data); err != nil {
+		return sdkerrors.QueryResult(errors.Wrapf(sdkerrors.ErrInvalidRequest, "invalid range: %s", err))
+	}
+	if bounds.Key != nil && bounds.Value != nil && bytes.Compare(bounds.Key, bounds.Value) > 0 {
+		return sdkerrors.QueryResult(errors.Wrapf(sdkerrors.ErrInvalidRequest, "invalid range [%X, %X)", bounds.Key, bounds.Value))
+	}
+
+	// the latest versions may not be applied to SS yet, and the earliest ones may be pruned
+	if rs.pruningManager != nil && rs.pruningManager.Pin(version) == nil {
+		defer rs.pruningManager.Unpin(version)
+	}
+	if err := rs.checkSSRetained(version, version); err != nil {
+		return sdkerrors.QueryResult(errors.Wrap(sdkerrors.ErrInvalidHeight, err.Error()))
+	}
+
+	res := abci.ResponseQuery{Height: version}
+	pairs := kv.Pairs{Pairs: make([]kv.Pair, 0)}
+	if err := rs.iterateRangeAt(storeName, bounds.Key, bounds.Value, version, func(key, value []byte) bool {
+		if len(pairs.Pairs) == rs.queries.rangeLimit {
+			res.Key = key
+			return true
+		}
+		pairs.Pairs = append(pairs.Pairs, kv.Pair{Key: key, Value: value})
+		return false
+	}); err != nil {
+		return sdkerrors.QueryResult(err)
+	}
+	var err error
+	if res.Value, err = pairs.Marshal(); err != nil {
+		return sdkerrors.QueryResult(err)
+	}
+	return res
+}
@@ -0,0 +1,92 @@
+package rootmulti
+
+import (
+	"testing"
+
+	"github.com/cosmos/cosmos-sdk/store/types"
+	sdkerrors "github.com/cosmos/cosmos-sdk/types/errors"
+	"github.com/cosmos/cosmos-sdk/types/kv"
+	"github.com/stretchr/testify/require"
+	abci "github.com/tendermint/tendermint/abci/types"
+)
+
+func TestRangeQuery(t *testing.T) {
+	key := types.NewKVStoreKey("bank")
+	store := newTestStore(t, true, key)
+	kvStore := store.GetKVStore(key)
+	kvStore.Set([]byte("a"), []byte("1"))
+	kvStore.Set([]byte("b"), []byte("1"))
+	kvStore.Set([]byte("c"), []byte("1"))
+	store.Commit(true)
+	kvStore.Delete([]byte("b"))
+	kvStore.Set([]byte("c"), []byte("2"))
+	kvStore.Set([]byte("d"), []byte("2"))
+	store.Commit(true)
+	waitForSS(t, store, 2)
+
+	query := func(start, end []byte, height int64) abci.ResponseQuery {
+		data, err := EncodeRangeQuery(start, end)
+		require.NoError(t, err)
+		return store.Query(abci.RequestQuery{Path: "/bank" + RangeQueryPath, Data: data, Height: height})
+	}
+	pairsOf := func(res abci.ResponseQuery) [][2]string {
+		require.Zero(t, res.Code, res.Log)
+		var pairs kv.Pairs
+		require.NoError(t, pairs.Unmarshal(res.Value))
+		result := [][2]string{}
+		for _, pair := range pairs.Pairs {
+			result = append(result, [2]string{string(pair.Key), string(pair.Value)})
+		}
+		return result
+	}
+
+	t.Run("historical", func(t *testing.T) {
+		skipHistoricalSSIteration(t)
+		require.Equal(t, [][2]string{{"a", "1"}, {"b", "1"}, {"c", "1"}}, pairsOf(query(nil, nil, 1)))
+		require.Equal(t, [][2]string{{"b", "1"}}, pairsOf(query([]byte("b"), []byte("c"), 1)))
+		require.Empty(t, pairsOf(query([]byte("d"), nil, 1)))
+	})
+	// the latest version is served from SS too
+	require.Equal(t, [][2]string{{"a", "1"}, {"c", "2"}, {"d", "2"}}, pairsOf(query(nil, nil, 0)))
+	require.Equal(t, [][2]string{{"c", "2"}}, pairsOf(query([]byte("b"), []byte("d"), 2)))
+	// empty ranges
+	require.Empty(t, pairsOf(query([]byte("b"), []byte("c"), 2)))
+	require.Empty(t, pairsOf(query([]byte("e"), nil, 2)))
+	require.Empty(t, pairsOf(query([]byte("b"), []byte("b"), 1)))
+
+	// the pairs are capped by the limit, the key holds where to resume from
+	store.queries.rangeLimit = 2
+	res := query(nil, nil, 2)
+	require.Equal(t, [][2]string{{"a", "1"}, {"c", "2"}}, pairsOf(res))
+	require.Equal(t, []byte("d"), res.Key)
+	res = query(res.Key, nil, 2)
+	require.Equal(t, [][2]string{{"d", "2"}}, pairsOf(res))
+	require.Nil(t, res.Key)
+
+	// the versions not applied to SS yet
+	store.PauseSS()
+	for i := 3; i <= 4; i++ {
+		kvStore.Set([]byte("e"), []byte{byte('0' + i)})
+		store.Commit(true)
+	}
+	res = query(nil, nil, 4)
+	require.Equal(t, sdkerrors.ErrInvalidHeight.ABCICode(), res.Code)
+	store.ResumeSS()
+	waitForSS(t, store, 4)
+	require.Equal(t, [][2]string{{"e", "4"}}, pairsOf(query([]byte("e"), nil, 4)))
+
+	res = query([]byte("c"), []byte("b"), 2)
+	require.Equal(t, sdkerrors.ErrInvalidRequest.ABCICode(), res.Code)
+	data, err := EncodeRangeQuery(nil, nil)
+	require.NoError(t, err)
+	res = store.Query(abci.RequestQuery{Path: "/bank" + RangeQueryPath, Data: data, Prove: true})
+	require.Equal(t, sdkerrors.ErrInvalidRequest.ABCICode(), res.Code)
+	require.Contains(t, res.Log, "proofs are not supported")
+	res = store.Query(abci.RequestQuery{Path: "/acc" + RangeQueryPath, Data: data})
+	require.Equal(t, sdkerrors.ErrUnknownRequest.ABCICode(), res.Code)
+
+	store = newTestStore(t, false, key)
+	store.Commit(true)
+	res = store.Query(abci.RequestQuery{Path: "/bank" + RangeQueryPath, Data: data})
+	require.Equal(t, sdkerrors.ErrInvalidRequest.ABCICode(), res.Code)
+}
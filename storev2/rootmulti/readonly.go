@@ -0,0 +1,136 @@
+package rootmulti
+
+import (
+	"fmt"
+
+	"github.com/sei-protocol/sei-db/common/logger"
+	"github.com/sei-protocol/sei-db/config"
+	"github.com/sei-protocol/sei-db/proto"
+	"github.com/sei-protocol/sei-db/sc/memiavl"
+	sctypes "github.com/sei-protocol/sei-db/sc/types"
+)
+
+// ErrReadOnly is returned by the writes to a store opened with WithReadOnly.
+var ErrReadOnly = fmt.Errorf("store is read-only")
+
+// WithReadOnly opens the store in read-only mode, the writes fail with ErrReadOnly.
+func WithReadOnly() Option {
+	return func(rs *Store) {
+		rs.readOnly = true
+	}
+}
+
+var _ sctypes.Committer = (*readOnlyCommitter)(nil)
+
+// readOnlyCommitter is a sc store opening memiavl in read-only mode, which the sc package doesn't expose.
+type readOnlyCommitter struct {
+	logger logger.Logger
+	opts   memiavl.Options
+	db     *memiavl.DB
+}
+
+func newReadOnlyCommitter(scDir string, logger logger.Logger, scConfig config.StateCommitConfig) *readOnlyCommitter {
+	return &readOnlyCommitter{
+		logger: logger,
+		opts: memiavl.Options{
+			Dir:       scDir,
+			ReadOnly:  true,
+			ZeroCopy:  scConfig.ZeroCopy,
+			CacheSize: scConfig.CacheSize,
+		},
+	}
+}
+
+// Initialize opens the latest version, the initial stores are not created.
+func (c *readOnlyCommitter) Initialize(_ []string) error {
+	db, err := memiavl.OpenDB(c.logger, 0, c.opts)
+	if err != nil {
+		return err
+	}
+	return c.setDB(db)
+}
+
+func (c *readOnlyCommitter) LoadVersion(targetVersion int64, createNew bool) (sctypes.Committer, error) {
+	db, err := memiavl.OpenDB(c.logger, targetVersion, c.opts)
+	if err != nil {
+		return nil, err
+	}
+	if createNew {
+		return &readOnlyCommitter{logger: c.logger, opts: c.opts, db: db}, nil
+	}
+	if err := c.setDB(db); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// setDB replaces the opened db, the previous one is closed so a reload doesn't leak its mmaps and file handles.
+func (c *readOnlyCommitter) setDB(db *memiavl.DB) error {
+	if c.db != nil {
+		if err := c.db.Close(); err != nil {
+			_ = db.Close()
+			return fmt.Errorf("failed to close the previous sc db: %w", err)
+		}
+	}
+	c.db = db
+	return nil
+}
+
+func (c *readOnlyCommitter) Commit() (int64, error) {
+	return 0, ErrReadOnly
+}
+
+func (c *readOnlyCommitter) ApplyChangeSets(_ []*proto.NamedChangeSet) error {
+	return ErrReadOnly
+}
+
+func (c *readOnlyCommitter) ApplyUpgrades(_ []*proto.TreeNameUpgrade) error {
+	return ErrReadOnly
+}
+
+func (c *readOnlyCommitter) Rollback(_ int64) error {
+	return ErrReadOnly
+}
+
+func (c *readOnlyCommitter) SetInitialVersion(_ int64) error {
+	return ErrReadOnly
+}
+
+func (c *readOnlyCommitter) Importer(_ int64) (sctypes.Importer, error) {
+	return nil, ErrReadOnly
+}
+
+func (c *readOnlyCommitter) Exporter(version int64) (sctypes.Exporter, error) {
+	return memiavl.NewMultiTreeExporter(c.opts.Dir, uint32(version), true)
+}
+
+func (c *readOnlyCommitter) Version() int64 {
+	return c.db.Version()
+}
+
+func (c *readOnlyCommitter) GetLatestVersion() (int64, error) {
+	return memiavl.GetLatestVersion(c.opts.Dir)
+}
+
+func (c *readOnlyCommitter) WorkingCommitInfo() *proto.CommitInfo {
+	return c.db.WorkingCommitInfo()
+}
+
+func (c *readOnlyCommitter) LastCommitInfo() *proto.CommitInfo {
+	return c.db.LastCommitInfo()
+}
+
+func (c *readOnlyCommitter) GetTreeByName(name string) sctypes.Tree {
+	// avoid a non-nil interface holding a nil tree
+	if tree := c.db.TreeByName(name); tree != nil {
+		return tree
+	}
+	return nil
+}
+
+func (c *readOnlyCommitter) Close() error {
+	if c.db == nil {
+		return nil
+	}
+	return c.db.Close()
+}
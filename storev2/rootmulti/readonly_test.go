@@ -0,0 +1,114 @@
+package rootmulti
+
+import (
+	"os"
+	"testing"
+
+	"github.com/cosmos/cosmos-sdk/store/types"
+	"github.com/sei-protocol/sei-db/config"
+	"github.com/sei-protocol/sei-db/proto"
+	"github.com/stretchr/testify/require"
+	abci "github.com/tendermint/tendermint/abci/types"
+	"github.com/tendermint/tendermint/libs/log"
+)
+
+func TestReadOnly(t *testing.T) {
+	key := types.NewKVStoreKey("bank")
+	home := t.TempDir()
+	ssConfig := config.DefaultStateStoreConfig()
+	ssConfig.Enable = true
+	ssConfig.KeepRecent = 0
+	writer := NewStore(home, log.NewNopLogger(), config.StateCommitConfig{}, ssConfig)
+	writer.MountStoreWithDB(key, types.StoreTypeIAVL, nil)
+	require.NoError(t, writer.LoadLatestVersion())
+	for i := byte(1); i <= 3; i++ {
+		writer.GetKVStore(key).Set([]byte("a"), []byte{i})
+		writer.Commit(true)
+	}
+	lastCommitID := writer.LastCommitID()
+	require.NoError(t, writer.Close())
+
+	store := NewStore(home, log.NewNopLogger(), config.StateCommitConfig{}, ssConfig, WithReadOnly())
+	store.MountStoreWithDB(key, types.StoreTypeIAVL, nil)
+	require.NoError(t, store.LoadLatestVersion())
+	defer store.Close()
+	require.Nil(t, store.ssCommitDone)
+	require.Nil(t, store.pruningManager)
+	require.Equal(t, lastCommitID, store.LastCommitID())
+
+	res := store.Query(abci.RequestQuery{Path: "/bank/key", Data: []byte("a"), Height: 3, Prove: true})
+	require.Zero(t, res.Code, res.Log)
+	require.Equal(t, []byte{3}, res.Value)
+	res = store.Query(abci.RequestQuery{Path: "/bank/key", Data: []byte("a"), Height: 2})
+	require.Zero(t, res.Code, res.Log)
+	require.Equal(t, []byte{2}, res.Value)
+	cms, err := store.CacheMultiStoreWithVersion(1)
+	require.NoError(t, err)
+	require.Equal(t, []byte{1}, cms.GetKVStore(key).Get([]byte("a")))
+
+	store.GetKVStore(key).Set([]byte("a"), []byte{4})
+	_, err = store.TryCommit(true)
+	require.ErrorIs(t, err, ErrReadOnly)
+	_, err = store.GetWorkingHash()
+	require.ErrorIs(t, err, ErrReadOnly)
+	require.ErrorIs(t, store.scStore.ApplyChangeSets([]*proto.NamedChangeSet{{Name: "bank"}}), ErrReadOnly)
+	require.ErrorIs(t, store.UpdatePruningConfig(10, 60), ErrReadOnly)
+	require.ErrorIs(t, store.PruneStateStore(1), ErrReadOnly)
+	require.ErrorIs(t, store.Reset(), ErrReadOnly)
+	require.Equal(t, lastCommitID, store.LastCommitID())
+}
+
+func TestReadOnlyNoLock(t *testing.T) {
+	key := types.NewKVStoreKey("bank")
+	home := t.TempDir()
+	writer := NewStore(home, log.NewNopLogger(), config.StateCommitConfig{}, config.StateStoreConfig{})
+	writer.MountStoreWithDB(key, types.StoreTypeIAVL, nil)
+	require.NoError(t, writer.LoadLatestVersion())
+	defer writer.Close()
+	writer.GetKVStore(key).Set([]byte("a"), []byte("1"))
+	writer.Commit(true)
+
+	// the sc store of a running node can be opened, it's not locked
+	store := NewStore(home, log.NewNopLogger(), config.StateCommitConfig{}, config.StateStoreConfig{}, WithReadOnly())
+	store.MountStoreWithDB(key, types.StoreTypeIAVL, nil)
+	require.NoError(t, store.LoadLatestVersion())
+	defer store.Close()
+	require.Equal(t, writer.LastCommitID(), store.LastCommitID())
+	require.Equal(t, []byte("1"), store.GetKVStore(key).Get([]byte("a")))
+
+	// a missing sc store is not created
+	empty := t.TempDir()
+	store = NewStore(empty, log.NewNopLogger(), config.StateCommitConfig{}, config.StateStoreConfig{}, WithReadOnly())
+	store.MountStoreWithDB(key, types.StoreTypeIAVL, nil)
+	require.Error(t, store.LoadLatestVersion())
+	entries, err := os.ReadDir(empty)
+	require.NoError(t, err)
+	require.Empty(t, entries)
+}
+
+func TestReadOnlyReloadClosesDB(t *testing.T) {
+	key := types.NewKVStoreKey("bank")
+	home := t.TempDir()
+	writer := NewStore(home, log.NewNopLogger(), config.StateCommitConfig{}, config.StateStoreConfig{})
+	writer.MountStoreWithDB(key, types.StoreTypeIAVL, nil)
+	require.NoError(t, writer.LoadLatestVersion())
+	writer.GetKVStore(key).Set([]byte("a"), []byte("1"))
+	writer.Commit(true)
+	require.NoError(t, writer.Close())
+
+	store := NewStore(home, log.NewNopLogger(), config.StateCommitConfig{}, config.StateStoreConfig{}, WithReadOnly())
+	store.MountStoreWithDB(key, types.StoreTypeIAVL, nil)
+	require.NoError(t, store.LoadLatestVersion())
+	defer store.Close()
+	committer := store.scStore.(*readOnlyCommitter)
+
+	// the db opened by the previous load is closed by the next one, a closed db has no tree
+	previous := committer.db
+	require.NoError(t, store.LoadLatestVersion())
+	require.Nil(t, previous.TreeByName("bank"))
+	previous = committer.db
+	_, err := committer.LoadVersion(1, false)
+	require.NoError(t, err)
+	require.Nil(t, previous.TreeByName("bank"))
+	require.Equal(t, []byte("1"), committer.GetTreeByName("bank").Get([]byte("a")))
+}
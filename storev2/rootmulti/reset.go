@@ -0,0 +1,107 @@
+package rootmulti
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/sei-protocol/sei-db/common/utils"
+	"github.com/sei-protocol/sei-db/sc"
+	"github.com/sei-protocol/sei-db/ss"
+	sstypes "github.com/sei-protocol/sei-db/ss/types"
+)
+
+// ErrCommitInProgress is returned by Reset if a commit is in progress.
+var ErrCommitInProgress = fmt.Errorf("commit in progress")
+
+// Reset wipes the sc and SS stores and loads the mounted stores again at the initial version, like a new store
+// created in an empty directory with the same mounts and configs, so the test harnesses and the simulations can
+// reuse a store between runs. The changes not yet applied to SS are discarded. It fails if a commit is in progress,
+// and must not be called concurrently with queries or snapshots.
+func (rs *Store) Reset() error {
+	if rs.readOnly {
+		return ErrReadOnly
+	}
+	if !rs.commitMtx.TryLock() {
+		return ErrCommitInProgress
+	}
+	defer rs.commitMtx.Unlock()
+
+	if rs.historicalStores != nil {
+		rs.historicalStores.purge()
+	}
+	if rs.loaded {
+		if err := rs.scStore.Close(); err != nil {
+			return fmt.Errorf("failed to close sc store: %w", err)
+		}
+	}
+	if err := os.RemoveAll(rs.scDir); err != nil {
+		return fmt.Errorf("failed to remove sc store: %w", err)
+	}
+	rs.scStore = sc.NewCommitStore(rs.homeDir, rs.logger, rs.scConfig)
+	if rs.ssStore != nil {
+		if err := rs.resetStateStore(); err != nil {
+			return err
+		}
+	}
+
+	rs.hooksMtx.Lock()
+	rs.stagedHookChanges = nil
+	rs.hooksMtx.Unlock()
+	rs.flushStatsMtx.Lock()
+	rs.lastFlushStats = nil
+	rs.flushStatsMtx.Unlock()
+	rs.mtx.Lock()
+	rs.lastCommitInfo = nil
+	rs.workingHash = nil
+	rs.mtx.Unlock()
+	if err := rs.LoadLatestVersion(); err != nil {
+		return err
+	}
+	if rs.initialVersion > 0 {
+		return rs.scStore.SetInitialVersion(rs.initialVersion)
+	}
+	return nil
+}
+
+// resetStateStore discards the pending changes and replaces the SS store with an empty one.
+func (rs *Store) resetStateStore() error {
+	rs.ssMtx.Lock()
+	defer rs.ssMtx.Unlock()
+	// the version received by the SS commit routine, if any, is discarded once it acquires ssMtx
+	rs.ssGeneration++
+	for drained := false; !drained; {
+		select {
+		case <-rs.pendingChanges:
+		default:
+			drained = true
+		}
+	}
+	rs.pendingMtx.Lock()
+	rs.pendingByStore = make(map[string]int)
+	rs.pendingMtx.Unlock()
+	rs.ssAppliedVersion = 0
+
+	return rs.pruningManager.Reset(func() (sstypes.StateStore, error) {
+		if err := rs.ssStore.Close(); err != nil {
+			return nil, fmt.Errorf("failed to close state store: %w", err)
+		}
+		if err := os.RemoveAll(rs.ssDir()); err != nil {
+			return nil, fmt.Errorf("failed to remove state store: %w", err)
+		}
+		ssStore, err := ss.NewStateStore(rs.homeDir, rs.ssConfig)
+		if err != nil {
+			return nil, err
+		}
+		rs.ssStore = ssStore
+		return ssStore, nil
+	})
+}
+
+// ssDir returns the directory of the SS store.
+func (rs *Store) ssDir() string {
+	dbHome := rs.homeDir
+	if rs.ssConfig.DBDirectory != "" {
+		dbHome = rs.ssConfig.DBDirectory
+	}
+	return utils.GetStateStorePath(dbHome, rs.ssConfig.Backend)
+}
@@ -0,0 +1,82 @@
+package rootmulti
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/cosmos/cosmos-sdk/store/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReset(t *testing.T) {
+	bank, acc := types.NewKVStoreKey("bank"), types.NewKVStoreKey("acc")
+	commit := func(store *Store, run int) []types.CommitID {
+		var commitIDs []types.CommitID
+		for i := 1; i <= 3; i++ {
+			store.GetKVStore(bank).Set([]byte(fmt.Sprintf("key-%d-%d", run, i)), []byte{byte(i)})
+			store.GetKVStore(acc).Set([]byte("a"), []byte{byte(run), byte(i)})
+			commitIDs = append(commitIDs, store.Commit(true))
+		}
+		return commitIDs
+	}
+	reference := newTestStore(t, true, bank, acc)
+	expected := commit(reference, 1)
+
+	store := newTestStore(t, true, bank, acc)
+	commit(store, 0)
+	waitForSS(t, store, 3)
+	// the changes not yet applied to SS are discarded
+	store.PauseSS()
+	store.GetKVStore(bank).Set([]byte("pending"), []byte("1"))
+	store.Commit(true)
+	require.NotEmpty(t, store.PendingChangesByStore())
+
+	require.NoError(t, store.Reset())
+	store.ResumeSS()
+	require.Equal(t, types.CommitID{}, store.LastCommitID())
+	require.Empty(t, store.PendingChangesByStore())
+	require.Empty(t, iterateAll(store.GetKVStore(bank)))
+	latest, err := store.ssStore.GetLatestVersion()
+	require.NoError(t, err)
+	require.Zero(t, latest)
+
+	require.Equal(t, expected, commit(store, 1))
+	waitForSS(t, store, 3)
+	for version := int64(1); version <= 3; version++ {
+		for _, key := range []string{"key-0-1", "pending"} {
+			value, err := store.ssStore.Get("bank", version, []byte(key))
+			require.NoError(t, err)
+			require.Nil(t, value)
+		}
+		value, err := store.ssStore.Get("acc", version, []byte("a"))
+		require.NoError(t, err)
+		require.Equal(t, []byte{1, byte(version)}, value)
+	}
+}
+
+func TestResetInitialVersion(t *testing.T) {
+	key := types.NewKVStoreKey("bank")
+	store := newTestStore(t, false, key)
+	require.NoError(t, store.SetInitialVersion(100))
+	store.GetKVStore(key).Set([]byte("a"), []byte("1"))
+	store.Commit(true)
+	store.Commit(true)
+	require.Equal(t, int64(101), store.LastCommitID().Version)
+
+	require.NoError(t, store.Reset())
+	store.GetKVStore(key).Set([]byte("a"), []byte("1"))
+	require.Equal(t, int64(100), store.Commit(true).Version)
+}
+
+func TestResetCommitInProgress(t *testing.T) {
+	key := types.NewKVStoreKey("bank")
+	store := newTestStore(t, true, key)
+	store.GetKVStore(key).Set([]byte("a"), []byte("1"))
+	store.Commit(true)
+
+	store.commitMtx.Lock()
+	require.ErrorIs(t, store.Reset(), ErrCommitInProgress)
+	store.commitMtx.Unlock()
+	require.Equal(t, int64(1), store.LastCommitID().Version)
+	require.Equal(t, []byte("1"), store.GetKVStore(key).Get([]byte("a")))
+}
@@ -0,0 +1,223 @@
+package rootmulti
+
+import (
+	"fmt"
+	"io"
+	"math"
+
+	"cosmossdk.io/errors"
+	snapshottypes "github.com/cosmos/cosmos-sdk/snapshots/types"
+	"github.com/cosmos/iavl"
+	protoio "github.com/gogo/protobuf/io"
+	"github.com/sei-protocol/sei-db/proto"
+	sctypes "github.com/sei-protocol/sei-db/sc/types"
+	sstypes "github.com/sei-protocol/sei-db/ss/types"
+)
+
+// Errors returned by Restore, callers can retry on ErrRestoreTruncated but not on malformed snapshots.
+var (
+	ErrRestoreHeightOverflow = fmt.Errorf("restore height overflows uint32")
+	ErrRestoreTruncated      = fmt.Errorf("snapshot stream is truncated")
+	ErrRestoreMalformed      = fmt.Errorf("invalid protobuf message")
+	ErrRestoreNodeHeight     = fmt.Errorf("snapshot node height exceeds the limit")
+	ErrRestoreImporter       = fmt.Errorf("sc importer failure")
+	ErrRestoreSSImport       = fmt.Errorf("ss import failure")
+	ErrRestoreNotEmpty       = fmt.Errorf("store is not empty")
+)
+
+// restoreConfig are the options of the snapshot restores.
+type restoreConfig struct {
+	// ssImportFilters are the filters of the leaves imported into SS while restoring, by store name.
+	ssImportFilters map[string]SSImportFilter
+	// pipelineDepth is the number of snapshot items decoded ahead of the import while restoring, 0 if disabled.
+	pipelineDepth int
+	// expectedStoreRoots are the roots of the stores verified by restore, nil if not verified.
+	expectedStoreRoots map[string][]byte
+}
+
+// Restore Implements interface Snapshotter
+func (rs *Store) Restore(
+	height uint64, format uint32, protoReader protoio.Reader,
+) (snapshottypes.SnapshotItem, error) {
+	if rs.readOnly {
+		return snapshottypes.SnapshotItem{}, ErrReadOnly
+	}
+	if height > math.MaxUint32 {
+		return snapshottypes.SnapshotItem{}, errors.Wrapf(ErrRestoreHeightOverflow, "height %d", height)
+	}
+	if !supportedSnapshotFormats[format] {
+		return snapshottypes.SnapshotItem{}, errors.Wrapf(snapshottypes.ErrUnknownFormat, "format %v", format)
+	}
+	// the snapshot replaces the trees of the sc store, but not the versions of the SS store,
+	// so it can only be restored on an empty store
+	if version := rs.scStore.Version(); version != 0 {
+		return snapshottypes.SnapshotItem{}, errors.Wrapf(ErrRestoreNotEmpty, "cannot restore snapshot at height %d, the store is at version %d", height, version)
+	}
+	if rs.historicalStores != nil {
+		rs.historicalStores.purge()
+	}
+	if rs.scStore != nil {
+		if err := rs.scStore.Close(); err != nil {
+			return snapshottypes.SnapshotItem{}, fmt.Errorf("failed to close db: %w", err)
+		}
+	}
+	item, err := rs.restore(int64(height), protoReader)
+	if err != nil {
+		return snapshottypes.SnapshotItem{}, err
+	}
+
+	return item, rs.LoadLatestVersion()
+}
+
+func (rs *Store) restore(height int64, protoReader protoio.Reader) (snapshottypes.SnapshotItem, error) {
+	var (
+		ssImporter   *ssImporter
+		snapshotItem snapshottypes.SnapshotItem
+		storeKey     string
+		restoreErr   error
+	)
+	scImporter, err := rs.scStore.Importer(height)
+	if err != nil {
+		return snapshottypes.SnapshotItem{}, errors.Wrap(ErrRestoreImporter, err.Error())
+	}
+	if rs.ssStore != nil {
+		ssImporter = rs.newSSImporter(height)
+	}
+	verifier := newStoreRootVerifier(rs.restores.expectedStoreRoots)
+	if rs.restores.pipelineDepth > 0 {
+		pipelined := newPipelinedReader(protoReader, rs.restores.pipelineDepth)
+		defer pipelined.stop()
+		protoReader = pipelined
+	}
+loop:
+	for {
+		snapshotItem = snapshottypes.SnapshotItem{}
+		err = protoReader.ReadMsg(&snapshotItem)
+		if err == io.EOF {
+			break
+		} else if err == io.ErrUnexpectedEOF {
+			restoreErr = ErrRestoreTruncated
+			break loop
+		} else if err != nil {
+			restoreErr = errors.Wrap(ErrRestoreMalformed, err.Error())
+			break loop
+		}
+
+		switch item := snapshotItem.Item.(type) {
+		case *snapshottypes.SnapshotItem_Store:
+			// the previous store is completely imported
+			if err = verifier.start(item.Store.Name); err != nil {
+				restoreErr = err
+				break loop
+			}
+			storeKey = item.Store.Name
+			if err = scImporter.AddTree(storeKey); err != nil {
+				restoreErr = errors.Wrap(ErrRestoreImporter, err.Error())
+				break loop
+			}
+		case *snapshottypes.SnapshotItem_IAVL:
+			if item.IAVL.Height > math.MaxInt8 {
+				restoreErr = errors.Wrapf(ErrRestoreNodeHeight, "node height %v cannot exceed %v",
+					item.IAVL.Height, math.MaxInt8)
+				break loop
+			}
+			node := &sctypes.SnapshotNode{
+				Key:     item.IAVL.Key,
+				Value:   item.IAVL.Value,
+				Height:  int8(item.IAVL.Height),
+				Version: item.IAVL.Version,
+			}
+			// Protobuf does not differentiate between []byte{} as nil, but fortunately IAVL does
+			// not allow nil keys nor nil values for leaf nodes, so we can always set them to empty.
+			if node.Key == nil {
+				node.Key = []byte{}
+			}
+			if node.Height == 0 && node.Value == nil {
+				node.Value = []byte{}
+			}
+			scImporter.AddNode(node)
+			if err = verifier.add(node); err != nil {
+				restoreErr = err
+				break loop
+			}
+
+			// Check if we should also import to SS store
+			if rs.ssStore != nil && node.Height == 0 && ssImporter != nil {
+				value := node.Value
+				if filter, ok := rs.restores.ssImportFilters[storeKey]; ok {
+					include, newValue := filter(node.Key, node.Value)
+					if !include {
+						continue
+					}
+					if newValue != nil {
+						value = newValue
+					}
+				}
+				if err = ssImporter.add(sstypes.SnapshotNode{
+					StoreKey: storeKey,
+					Key:      node.Key,
+					Value:    value,
+				}); err != nil {
+					restoreErr = errors.Wrap(ErrRestoreSSImport, err.Error())
+					break loop
+				}
+			}
+		default:
+			// unknown element, could be an extension
+			break loop
+		}
+	}
+
+	if restoreErr == nil {
+		restoreErr = verifier.finish()
+	}
+	if err = scImporter.Close(); err != nil {
+		if restoreErr == nil {
+			restoreErr = errors.Wrap(ErrRestoreImporter, err.Error())
+		}
+	}
+	if ssImporter != nil {
+		if err = ssImporter.close(); err != nil && restoreErr == nil {
+			restoreErr = errors.Wrap(ErrRestoreSSImport, err.Error())
+		}
+	}
+
+	return snapshotItem, restoreErr
+}
+
+// importSS writes the snapshot nodes into the SS store at the height, in changesets of up to ssImportBatchSize
+// pairs of a store applied with ApplyChangeset, so the write failures are returned instead of crashing the process
+// like the import goroutines of the backends. The remaining nodes are drained on failure so the restore can proceed
+// and report it.
+func (rs *Store) importSS(height int64, nodes chan sstypes.SnapshotNode) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+		if err != nil {
+			for range nodes {
+			}
+		}
+	}()
+	var cs *proto.NamedChangeSet
+	flush := func() error {
+		if cs == nil {
+			return nil
+		}
+		err := rs.ssStore.ApplyChangeset(height, cs)
+		cs = nil
+		return err
+	}
+	for node := range nodes {
+		if cs != nil && (cs.Name != node.StoreKey || len(cs.Changeset.Pairs) >= ssImportBatchSize) {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+		if cs == nil {
+			cs = &proto.NamedChangeSet{Name: node.StoreKey}
+		}
+		cs.Changeset.Pairs = append(cs.Changeset.Pairs, &iavl.KVPair{Key: node.Key, Value: node.Value})
+	}
+	return flush()
+}
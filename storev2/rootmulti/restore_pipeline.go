@@ -0,0 +1,77 @@
+package rootmulti
+
+import (
+	"fmt"
+
+	snapshottypes "github.com/cosmos/cosmos-sdk/snapshots/types"
+	protoio "github.com/gogo/protobuf/io"
+	"github.com/gogo/protobuf/proto"
+)
+
+// WithRestorePipeline decodes up to depth snapshot items ahead of the import while restoring, 0 disables it.
+func WithRestorePipeline(depth int) Option {
+	return func(rs *Store) {
+		rs.restores.pipelineDepth = depth
+	}
+}
+
+type decodedItem struct {
+	item snapshottypes.SnapshotItem
+	err  error
+}
+
+// pipelinedReader reads the snapshot items ahead of the restore loop, it stops reading after the first item
+// which is not a store or a node, e.g. an extension, or the first error, so the caller can keep reading the
+// rest of the stream from the underlying reader once the restore completes.
+type pipelinedReader struct {
+	items chan decodedItem
+	quit  chan struct{}
+}
+
+func newPipelinedReader(reader protoio.Reader, depth int) *pipelinedReader {
+	r := &pipelinedReader{
+		items: make(chan decodedItem, depth),
+		quit:  make(chan struct{}),
+	}
+	go func() {
+		defer close(r.items)
+		for {
+			var decoded decodedItem
+			decoded.err = reader.ReadMsg(&decoded.item)
+			select {
+			case r.items <- decoded:
+			case <-r.quit:
+				return
+			}
+			if decoded.err != nil {
+				return
+			}
+			switch decoded.item.Item.(type) {
+			case *snapshottypes.SnapshotItem_Store, *snapshottypes.SnapshotItem_IAVL:
+			default:
+				return
+			}
+		}
+	}()
+	return r
+}
+
+// ReadMsg implements protoio.Reader, msg must be a snapshot item.
+func (r *pipelinedReader) ReadMsg(msg proto.Message) error {
+	item, ok := msg.(*snapshottypes.SnapshotItem)
+	if !ok {
+		return fmt.Errorf("unexpected message type %T, expect snapshot item", msg)
+	}
+	decoded, ok := <-r.items
+	if !ok {
+		return fmt.Errorf("snapshot items read after the end of the restore")
+	}
+	*item = decoded.item
+	return decoded.err
+}
+
+// stop stops reading ahead if the restore is aborted, the read in flight is discarded once it completes.
+// Once the last item is received, the underlying reader is not used anymore.
+func (r *pipelinedReader) stop() {
+	close(r.quit)
+}
@@ -0,0 +1,123 @@
+package rootmulti
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"cosmossdk.io/errors"
+	"github.com/sei-protocol/sei-db/sc/memiavl"
+	sctypes "github.com/sei-protocol/sei-db/sc/types"
+)
+
+// ErrRestoreStoreRoot is returned by Restore if the root of a restored store doesn't match the expected one.
+var ErrRestoreStoreRoot = fmt.Errorf("store root mismatch")
+
+// emptyTreeHash is the root hash of an empty tree.
+var emptyTreeHash = sha256.New().Sum(nil)
+
+// SetExpectedStoreRoots sets the root hashes of the stores expected by the next restores, keyed by store name.
+// Each store is verified as soon as its import completes, so a corrupted store fails the restore early naming it,
+// instead of only mismatching the app hash at the end. The snapshot format doesn't carry the roots of the stores,
+// they must come from a trusted source, e.g. the commit info of the snapshot height. nil disables the verification.
+func (rs *Store) SetExpectedStoreRoots(roots map[string][]byte) {
+	rs.restores.expectedStoreRoots = roots
+}
+
+// storeRootVerifier computes the root hash of each store from the snapshot nodes as they're restored,
+// the nodes are exported in post-order, so the hash of a branch is computed from its two pending children.
+type storeRootVerifier struct {
+	expected map[string][]byte
+	store    string
+	// pending are the hashes and sizes of the subtrees whose parent is not restored yet.
+	pending []subtreeHash
+}
+
+type subtreeHash struct {
+	hash []byte
+	size int64
+}
+
+// newStoreRootVerifier returns nil if no root is expected, all the methods are no-ops on nil.
+func newStoreRootVerifier(expected map[string][]byte) *storeRootVerifier {
+	if len(expected) == 0 {
+		return nil
+	}
+	return &storeRootVerifier{expected: expected}
+}
+
+// start verifies the store being restored and starts computing the root of the next one.
+func (v *storeRootVerifier) start(store string) error {
+	if v == nil {
+		return nil
+	}
+	if err := v.finish(); err != nil {
+		return err
+	}
+	v.store = store
+	return nil
+}
+
+func (v *storeRootVerifier) add(node *sctypes.SnapshotNode) error {
+	if v == nil {
+		return nil
+	}
+	if node.Height == 0 {
+		valueHash := sha256.Sum256(node.Value)
+		v.pending = append(v.pending, subtreeHash{hash: hashSnapshotNode(node, 1, node.Key, valueHash[:]), size: 1})
+		return nil
+	}
+	if len(v.pending) < 2 {
+		return errors.Wrapf(ErrRestoreStoreRoot, "store %s: branch node without children", v.store)
+	}
+	left, right := v.pending[len(v.pending)-2], v.pending[len(v.pending)-1]
+	size := left.size + right.size
+	v.pending = append(v.pending[:len(v.pending)-2], subtreeHash{hash: hashSnapshotNode(node, size, left.hash, right.hash), size: size})
+	return nil
+}
+
+// finish verifies the root of the store being restored if it's expected.
+func (v *storeRootVerifier) finish() error {
+	if v == nil || v.store == "" {
+		return nil
+	}
+	store, pending := v.store, v.pending
+	v.store, v.pending = "", nil
+	expected, ok := v.expected[store]
+	if !ok {
+		return nil
+	}
+	var root []byte
+	switch len(pending) {
+	case 0:
+		root = emptyTreeHash
+	case 1:
+		root = pending[0].hash
+	default:
+		return errors.Wrapf(ErrRestoreStoreRoot, "store %s: %d subtrees without parent", store, len(pending))
+	}
+	if !bytes.Equal(root, expected) {
+		return errors.Wrapf(ErrRestoreStoreRoot, "store %s: expected root %X, got %X", store, expected, root)
+	}
+	return nil
+}
+
+// hashSnapshotNode hashes the node like the iavl trees, the leaves hash their key and the hash of their value,
+// the branches the hashes of their children.
+func hashSnapshotNode(node *sctypes.SnapshotNode, size int64, first, second []byte) []byte {
+	h := sha256.New()
+	writeVarint(h, int64(node.Height))
+	writeVarint(h, size)
+	writeVarint(h, node.Version)
+	// the writes to a hash never fail
+	_ = memiavl.EncodeBytes(h, first)
+	_ = memiavl.EncodeBytes(h, second)
+	return h.Sum(nil)
+}
+
+func writeVarint(w io.Writer, n int64) {
+	var buf [binary.MaxVarintLen64]byte
+	_, _ = w.Write(buf[:binary.PutVarint(buf[:], n)])
+}
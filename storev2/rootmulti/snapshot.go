@@ -0,0 +1,138 @@
+package rootmulti
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	snapshottypes "github.com/cosmos/cosmos-sdk/snapshots/types"
+	protoio "github.com/gogo/protobuf/io"
+	commonerrors "github.com/sei-protocol/sei-db/common/errors"
+	sctypes "github.com/sei-protocol/sei-db/sc/types"
+)
+
+// SnapshotFormat is the format of the snapshots taken by Snapshot. The snapshot items are the ones of the IAVL
+// multistore, the stream has no room for the format without breaking the restore on the other nodes, so it's
+// carried by the snapshot metadata and the header of the snapshot files.
+const SnapshotFormat = snapshottypes.CurrentFormat
+
+// supportedSnapshotFormats are the formats Restore can restore, it rejects the others with ErrUnknownFormat.
+var supportedSnapshotFormats = map[uint32]bool{
+	SnapshotFormat: true,
+}
+
+// snapshotConfig are the options of the snapshots taken by the store.
+type snapshotConfig struct {
+	// exportMemoryLimit is the ceiling of the heap in use while exporting snapshots, 0 if unbounded.
+	exportMemoryLimit uint64
+	// commitPause bounds the pause of the commits while opening a snapshot of the latest version, 0 if disabled.
+	commitPause time.Duration
+}
+
+// WithExportMemoryLimit bounds the heap in use while exporting snapshots, 0 disables the limit.
+func WithExportMemoryLimit(limit uint64) Option {
+	return func(rs *Store) {
+		rs.snapshots.exportMemoryLimit = limit
+	}
+}
+
+// SnapshotProgress reports the progress of SnapshotContext, OnProgress is called every Interval nodes exported
+// with the number of nodes exported so far per store.
+type SnapshotProgress struct {
+	Interval   int64
+	OnProgress func(nodes map[string]int64)
+}
+
+// Snapshot Implements the interface from Snapshotter
+func (rs *Store) Snapshot(height uint64, protoWriter protoio.Writer) error {
+	return rs.SnapshotContext(context.Background(), height, protoWriter, nil)
+}
+
+// SnapshotContext exports the snapshot like Snapshot, the export is aborted with the error of the context
+// once it's done, the progress is reported if not nil.
+func (rs *Store) SnapshotContext(ctx context.Context, height uint64, protoWriter protoio.Writer, progress *SnapshotProgress) error {
+	if height > math.MaxUint32 {
+		return fmt.Errorf("height overflows uint32: %d", height)
+	}
+	// protect the height against SS pruning until the snapshot completes, the export only reads the sc store,
+	// so a height already pruned from SS doesn't fail it
+	if rs.pruningManager != nil {
+		if err := rs.pruningManager.Pin(int64(height)); err != nil {
+			rs.logger.Info("snapshot height is not pinned against the state store pruning", "height", height, "err", err)
+		} else {
+			defer rs.pruningManager.Unpin(int64(height))
+		}
+	}
+
+	resumeCommits := rs.pauseCommitsForSnapshot(int64(height))
+	exporter, err := rs.scStore.Exporter(int64(height))
+	// the exporter holds the version once loaded, the commits can resume
+	resumeCommits()
+	if err != nil {
+		return err
+	}
+	defer exporter.Close()
+	guard := newExportMemoryGuard(rs.snapshots.exportMemoryLimit)
+	defer guard.report()
+	var (
+		storeName string
+		exported  int64
+		nodes     = make(map[string]int64)
+	)
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		item, err := exporter.Next()
+		if err != nil {
+			if err == commonerrors.ErrorExportDone {
+				break
+			}
+			return err
+		}
+
+		switch item := item.(type) {
+		case *sctypes.SnapshotNode:
+			if err := guard.add(len(item.Key) + len(item.Value)); err != nil {
+				return err
+			}
+			if err := protoWriter.WriteMsg(&snapshottypes.SnapshotItem{
+				Item: &snapshottypes.SnapshotItem_IAVL{
+					IAVL: &snapshottypes.SnapshotIAVLItem{
+						Key:     item.Key,
+						Value:   item.Value,
+						Height:  int32(item.Height),
+						Version: item.Version,
+					},
+				},
+			}); err != nil {
+				return err
+			}
+			nodes[storeName]++
+			exported++
+			if progress != nil && progress.Interval > 0 && exported%progress.Interval == 0 {
+				counts := make(map[string]int64, len(nodes))
+				for name, count := range nodes {
+					counts[name] = count
+				}
+				progress.OnProgress(counts)
+			}
+		case string:
+			storeName = item
+			if err := protoWriter.WriteMsg(&snapshottypes.SnapshotItem{
+				Item: &snapshottypes.SnapshotItem_Store{
+					Store: &snapshottypes.SnapshotStoreItem{
+						Name: item,
+					},
+				},
+			}); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("unknown item type %T", item)
+		}
+	}
+
+	return nil
+}
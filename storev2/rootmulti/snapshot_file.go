@@ -0,0 +1,267 @@
+package rootmulti
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	protoio "github.com/gogo/protobuf/io"
+	"github.com/gogo/protobuf/proto"
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	// snapshotFileMaxItemSize is the max size of a single snapshot item read from a snapshot file,
+	// keep it in sync with the limit used by the snapshot manager.
+	snapshotFileMaxItemSize = int(64e6)
+	// snapshotFileMaxHeaderSize is the max size of the json encoded snapshot file header.
+	snapshotFileMaxHeaderSize = 4096
+	// restoreProgressInterval is the number of snapshot items between two progress logs.
+	restoreProgressInterval = 1000000
+)
+
+const (
+	CompressionNone = "none"
+	CompressionGzip = "gzip"
+	CompressionZstd = "zstd"
+)
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+	// snapshotFileMagic starts the self-describing header written by SnapshotToFile.
+	snapshotFileMagic = []byte("SEISNAP\x01")
+)
+
+// snapshotFileHeader describes the content of a snapshot file, it's written uncompressed
+// right after snapshotFileMagic, prefixed with its length as uvarint.
+type snapshotFileHeader struct {
+	Height      uint64 `json:"height"`
+	Format      uint32 `json:"format"`
+	Compression string `json:"compression"`
+}
+
+// SnapshotToFile exports the snapshot at height into a file, the snapshot items are compressed with
+// the compression codec (none, gzip or zstd), the file can be restored with RestoreFromFile.
+func (rs *Store) SnapshotToFile(height uint64, path string, compression string) (err error) {
+	if compression == "" {
+		compression = CompressionNone
+	}
+	if compression != CompressionNone && compression != CompressionGzip && compression != CompressionZstd {
+		return fmt.Errorf("unsupported snapshot compression: %s", compression)
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			_ = os.Remove(path)
+		}
+	}()
+
+	bufWriter := bufio.NewWriter(file)
+	header := snapshotFileHeader{
+		Height:      height,
+		Format:      SnapshotFormat,
+		Compression: compression,
+	}
+	if err := writeSnapshotFileHeader(bufWriter, header); err != nil {
+		return err
+	}
+	writer, err := newCompressWriter(bufWriter, compression)
+	if err != nil {
+		return err
+	}
+	if err := rs.Snapshot(height, protoio.NewDelimitedWriter(writer)); err != nil {
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+	return bufWriter.Flush()
+}
+
+// RestoreFromFile restores the store from a snapshot file, either written by SnapshotToFile or a raw
+// stream of snapshot items. For a raw stream the compression (none, gzip or zstd) is detected from the file content.
+func (rs *Store) RestoreFromFile(path string, height uint64, format uint32) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+
+	counter := &countingReader{reader: file}
+	bufReader := bufio.NewReader(counter)
+	header, err := readSnapshotFileHeader(bufReader)
+	if err != nil {
+		return err
+	}
+	var compression string
+	if header != nil {
+		if header.Height != height {
+			return fmt.Errorf("snapshot file height %d doesn't match the restore height %d", header.Height, height)
+		}
+		if header.Format != format {
+			return fmt.Errorf("snapshot file format %d doesn't match the restore format %d", header.Format, format)
+		}
+		compression = header.Compression
+	} else if compression, err = detectCompression(bufReader); err != nil {
+		return err
+	}
+	reader, err := newDecompressReader(bufReader, compression)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	protoReader := &progressReader{
+		Reader: protoio.NewDelimitedReader(reader, snapshotFileMaxItemSize),
+		onProgress: func(items int64) {
+			rs.logger.Info(fmt.Sprintf("Restoring snapshot from %s, read %d items, %d/%d bytes", path, items, counter.count, info.Size()))
+		},
+	}
+	_, err = rs.Restore(height, format, protoReader)
+	return err
+}
+
+func writeSnapshotFileHeader(writer io.Writer, header snapshotFileHeader) error {
+	bz, err := json.Marshal(header)
+	if err != nil {
+		return err
+	}
+	size := make([]byte, binary.MaxVarintLen64)
+	n := binary.PutUvarint(size, uint64(len(bz)))
+	buf := make([]byte, 0, len(snapshotFileMagic)+n+len(bz))
+	buf = append(buf, snapshotFileMagic...)
+	buf = append(buf, size[:n]...)
+	buf = append(buf, bz...)
+	_, err = writer.Write(buf)
+	return err
+}
+
+// readSnapshotFileHeader reads the header if the stream starts with snapshotFileMagic, returns nil otherwise.
+func readSnapshotFileHeader(reader *bufio.Reader) (*snapshotFileHeader, error) {
+	magic, err := reader.Peek(len(snapshotFileMagic))
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	if !bytes.Equal(magic, snapshotFileMagic) {
+		return nil, nil
+	}
+	if _, err := reader.Discard(len(snapshotFileMagic)); err != nil {
+		return nil, err
+	}
+	size, err := binary.ReadUvarint(reader)
+	if err != nil {
+		return nil, fmt.Errorf("invalid snapshot file header: %w", err)
+	}
+	if size > snapshotFileMaxHeaderSize {
+		return nil, fmt.Errorf("snapshot file header size %d exceeds the limit %d", size, snapshotFileMaxHeaderSize)
+	}
+	bz := make([]byte, size)
+	if _, err := io.ReadFull(reader, bz); err != nil {
+		return nil, fmt.Errorf("invalid snapshot file header: %w", err)
+	}
+	var header snapshotFileHeader
+	if err := json.Unmarshal(bz, &header); err != nil {
+		return nil, fmt.Errorf("invalid snapshot file header: %w", err)
+	}
+	return &header, nil
+}
+
+// detectCompression detects the compression of the stream from its magic bytes.
+func detectCompression(reader *bufio.Reader) (string, error) {
+	magic, err := reader.Peek(len(zstdMagic))
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	switch {
+	case bytes.HasPrefix(magic, gzipMagic):
+		return CompressionGzip, nil
+	case bytes.HasPrefix(magic, zstdMagic):
+		return CompressionZstd, nil
+	default:
+		return CompressionNone, nil
+	}
+}
+
+func newDecompressReader(reader io.Reader, compression string) (io.ReadCloser, error) {
+	switch compression {
+	case CompressionGzip:
+		return gzip.NewReader(reader)
+	case CompressionZstd:
+		decoder, err := zstd.NewReader(reader)
+		if err != nil {
+			return nil, err
+		}
+		return decoder.IOReadCloser(), nil
+	case CompressionNone:
+		return io.NopCloser(reader), nil
+	default:
+		return nil, fmt.Errorf("unsupported snapshot compression: %s", compression)
+	}
+}
+
+func newCompressWriter(writer io.Writer, compression string) (io.WriteCloser, error) {
+	switch compression {
+	case CompressionGzip:
+		return gzip.NewWriter(writer), nil
+	case CompressionZstd:
+		return zstd.NewWriter(writer)
+	case CompressionNone:
+		return nopWriteCloser{writer}, nil
+	default:
+		return nil, fmt.Errorf("unsupported snapshot compression: %s", compression)
+	}
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
+
+// countingReader counts the bytes read from the underlying reader.
+type countingReader struct {
+	reader io.Reader
+	count  int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.count += int64(n)
+	return n, err
+}
+
+// progressReader reports the progress every restoreProgressInterval snapshot items.
+type progressReader struct {
+	protoio.Reader
+	items      int64
+	onProgress func(items int64)
+}
+
+func (r *progressReader) ReadMsg(msg proto.Message) error {
+	if err := r.Reader.ReadMsg(msg); err != nil {
+		return err
+	}
+	r.items++
+	if r.items%restoreProgressInterval == 0 {
+		r.onProgress(r.items)
+	}
+	return nil
+}
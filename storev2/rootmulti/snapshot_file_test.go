@@ -0,0 +1,521 @@
+package rootmulti
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	snapshottypes "github.com/cosmos/cosmos-sdk/snapshots/types"
+	"github.com/cosmos/cosmos-sdk/store/types"
+	protoio "github.com/gogo/protobuf/io"
+	"github.com/gogo/protobuf/proto"
+	"github.com/klauspost/compress/zstd"
+	"github.com/sei-protocol/sei-db/config"
+	seiproto "github.com/sei-protocol/sei-db/proto"
+	sctypes "github.com/sei-protocol/sei-db/sc/types"
+	sstypes "github.com/sei-protocol/sei-db/ss/types"
+	"github.com/stretchr/testify/require"
+	"github.com/tendermint/tendermint/libs/log"
+)
+
+// newSnapshotSourceStore creates a store with a few committed versions to snapshot.
+func newSnapshotSourceStore(t *testing.T, key types.StoreKey) *Store {
+	store := newTestStore(t, false, key)
+	for i := 0; i < 3; i++ {
+		kvStore := store.GetKVStore(key)
+		for j := 0; j < 10; j++ {
+			kvStore.Set([]byte(fmt.Sprintf("key-%d-%d", i, j)), []byte(fmt.Sprintf("value-%d", i)))
+		}
+		store.Commit(true)
+	}
+	return store
+}
+
+func TestRestoreFromFile(t *testing.T) {
+	key := types.NewKVStoreKey("bank")
+	source := newSnapshotSourceStore(t, key)
+	height := uint64(source.LastCommitID().Version)
+
+	compressors := map[string]func(io.Writer) io.WriteCloser{
+		"none": func(w io.Writer) io.WriteCloser {
+			return nopWriteCloser{w}
+		},
+		"gzip": func(w io.Writer) io.WriteCloser {
+			return gzip.NewWriter(w)
+		},
+		"zstd": func(w io.Writer) io.WriteCloser {
+			encoder, err := zstd.NewWriter(w)
+			require.NoError(t, err)
+			return encoder
+		},
+	}
+	for name, compressor := range compressors {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "snapshot")
+			file, err := os.Create(path)
+			require.NoError(t, err)
+			writer := compressor(file)
+			require.NoError(t, source.Snapshot(height, protoio.NewDelimitedWriter(writer)))
+			require.NoError(t, writer.Close())
+			require.NoError(t, file.Close())
+
+			target := newTestStore(t, false, key)
+			require.NoError(t, target.RestoreFromFile(path, height, snapshottypes.CurrentFormat))
+			require.Equal(t, source.LastCommitID(), target.LastCommitID())
+			require.Equal(t, []byte("value-2"), target.GetKVStore(key).Get([]byte("key-2-9")))
+		})
+	}
+}
+
+func TestRestoreFromFileNotFound(t *testing.T) {
+	store := newTestStore(t, false, types.NewKVStoreKey("bank"))
+	require.Error(t, store.RestoreFromFile(filepath.Join(t.TempDir(), "missing"), 1, 0))
+}
+
+func TestSnapshotToFile(t *testing.T) {
+	key := types.NewKVStoreKey("bank")
+	source := newSnapshotSourceStore(t, key)
+	height := uint64(source.LastCommitID().Version)
+
+	for _, compression := range []string{CompressionNone, CompressionGzip, CompressionZstd} {
+		t.Run(compression, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "snapshot")
+			require.NoError(t, source.SnapshotToFile(height, path, compression))
+			file, err := os.Open(path)
+			require.NoError(t, err)
+			header, err := readSnapshotFileHeader(bufio.NewReader(file))
+			require.NoError(t, err)
+			require.NoError(t, file.Close())
+			require.Equal(t, SnapshotFormat, header.Format)
+
+			target := newTestStore(t, false, key)
+			require.NoError(t, target.RestoreFromFile(path, height, snapshottypes.CurrentFormat))
+			require.Equal(t, source.LastCommitID(), target.LastCommitID())
+			require.Equal(t, iterateAll(source.GetKVStore(key)), iterateAll(target.GetKVStore(key)))
+		})
+	}
+}
+
+func TestSnapshotToFileInvalid(t *testing.T) {
+	key := types.NewKVStoreKey("bank")
+	source := newSnapshotSourceStore(t, key)
+	height := uint64(source.LastCommitID().Version)
+	path := filepath.Join(t.TempDir(), "snapshot")
+
+	require.Error(t, source.SnapshotToFile(height, path, "lz4"))
+	_, err := os.Stat(path)
+	require.True(t, os.IsNotExist(err))
+
+	require.NoError(t, source.SnapshotToFile(height, path, CompressionGzip))
+	target := newTestStore(t, false, key)
+	require.Error(t, target.RestoreFromFile(path, height-1, snapshottypes.CurrentFormat))
+	require.Error(t, target.RestoreFromFile(path, height, snapshottypes.CurrentFormat+1))
+}
+
+func iterateAll(store types.KVStore) map[string]string {
+	result := make(map[string]string)
+	iter := store.Iterator(nil, nil)
+	defer iter.Close()
+	for ; iter.Valid(); iter.Next() {
+		result[string(iter.Key())] = string(iter.Value())
+	}
+	return result
+}
+
+// pruneOnWriteWriter triggers a prune on the first snapshot item written.
+type pruneOnWriteWriter struct {
+	protoio.Writer
+	prune func()
+}
+
+func (w *pruneOnWriteWriter) WriteMsg(msg proto.Message) error {
+	if w.prune != nil {
+		w.prune()
+		w.prune = nil
+	}
+	return w.Writer.WriteMsg(msg)
+}
+
+func TestSnapshotPinsHeight(t *testing.T) {
+	key := types.NewKVStoreKey("bank")
+	store := newTestStore(t, true, key)
+	for i := 1; i <= 3; i++ {
+		store.GetKVStore(key).Set([]byte("a"), []byte{byte(i)})
+		store.Commit(true)
+	}
+	waitForSS(t, store, 3)
+
+	var buf bytes.Buffer
+	writer := &pruneOnWriteWriter{
+		Writer: protoio.NewDelimitedWriter(&buf),
+		prune: func() {
+			prunedVersion, err := store.pruningManager.PruneUpTo(2)
+			require.NoError(t, err)
+			require.Equal(t, int64(1), prunedVersion)
+			value, err := store.ssStore.Get("bank", 2, []byte("a"))
+			require.NoError(t, err)
+			require.Equal(t, []byte{2}, value)
+		},
+	}
+	require.NoError(t, store.Snapshot(2, writer))
+	require.Nil(t, writer.prune)
+
+	target := newTestStore(t, false, key)
+	_, err := target.Restore(2, snapshottypes.CurrentFormat, protoio.NewDelimitedReader(&buf, snapshotFileMaxItemSize))
+	require.NoError(t, err)
+	require.Equal(t, []byte{2}, target.GetKVStore(key).Get([]byte("a")))
+
+	// the height is released once the snapshot completes
+	prunedVersion, err := store.pruningManager.PruneUpTo(2)
+	require.NoError(t, err)
+	require.Equal(t, int64(2), prunedVersion)
+
+	// the export only reads the sc store, a height pruned from SS can still be snapshotted
+	buf.Reset()
+	require.NoError(t, store.Snapshot(2, protoio.NewDelimitedWriter(&buf)))
+	require.NotZero(t, buf.Len())
+}
+
+func TestRestoreErrors(t *testing.T) {
+	key := types.NewKVStoreKey("bank")
+	source := newSnapshotSourceStore(t, key)
+	height := uint64(source.LastCommitID().Version)
+	var snapshot bytes.Buffer
+	require.NoError(t, source.Snapshot(height, protoio.NewDelimitedWriter(&snapshot)))
+
+	writeItems := func(items ...snapshottypes.SnapshotItem) []byte {
+		var buf bytes.Buffer
+		writer := protoio.NewDelimitedWriter(&buf)
+		for i := range items {
+			require.NoError(t, writer.WriteMsg(&items[i]))
+		}
+		return buf.Bytes()
+	}
+	storeItem := snapshottypes.SnapshotItem{Item: &snapshottypes.SnapshotItem_Store{Store: &snapshottypes.SnapshotStoreItem{Name: "bank"}}}
+	leafItem := snapshottypes.SnapshotItem{Item: &snapshottypes.SnapshotItem_IAVL{IAVL: &snapshottypes.SnapshotIAVLItem{Key: []byte("a"), Value: []byte("1"), Version: 1}}}
+
+	testCases := []struct {
+		name   string
+		height uint64
+		stream []byte
+		expErr error
+	}{
+		{"height overflow", math.MaxUint32 + 1, snapshot.Bytes(), ErrRestoreHeightOverflow},
+		{"truncated", height, snapshot.Bytes()[:snapshot.Len()-1], ErrRestoreTruncated},
+		{"malformed", height, []byte{2, 0x0f, 0x00}, ErrRestoreMalformed},
+		{"node height", height, writeItems(storeItem, snapshottypes.SnapshotItem{
+			Item: &snapshottypes.SnapshotItem_IAVL{IAVL: &snapshottypes.SnapshotIAVLItem{Key: []byte("a"), Height: math.MaxInt8 + 1}},
+		}), ErrRestoreNodeHeight},
+		// two leaves without their parent node is an invalid tree
+		{"importer", height, writeItems(storeItem, leafItem, leafItem), ErrRestoreImporter},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// a failed restore leaves the sc store closed, so the target is not closed again
+			target := NewStore(t.TempDir(), log.NewNopLogger(), config.StateCommitConfig{}, config.StateStoreConfig{})
+			target.MountStoreWithDB(key, types.StoreTypeIAVL, nil)
+			require.NoError(t, target.LoadLatestVersion())
+			_, err := target.Restore(tc.height, snapshottypes.CurrentFormat, protoio.NewDelimitedReader(bytes.NewReader(tc.stream), snapshotFileMaxItemSize))
+			require.ErrorIs(t, err, tc.expErr)
+		})
+	}
+}
+
+// failingImportStateStore fails the writes of the import.
+type failingImportStateStore struct {
+	sstypes.StateStore
+	panics bool
+}
+
+func (s *failingImportStateStore) ApplyChangeset(_ int64, _ *seiproto.NamedChangeSet) error {
+	if s.panics {
+		panic("disk failure")
+	}
+	return errors.New("disk failure")
+}
+
+func TestRestoreSSImportError(t *testing.T) {
+	key := types.NewKVStoreKey("bank")
+	source := newSnapshotSourceStore(t, key)
+	height := uint64(source.LastCommitID().Version)
+	var snapshot bytes.Buffer
+	require.NoError(t, source.Snapshot(height, protoio.NewDelimitedWriter(&snapshot)))
+
+	for _, panics := range []bool{false, true} {
+		target := NewStore(t.TempDir(), log.NewNopLogger(), config.StateCommitConfig{}, config.StateStoreConfig{})
+		target.MountStoreWithDB(key, types.StoreTypeIAVL, nil)
+		require.NoError(t, target.LoadLatestVersion())
+		target.ssStore = &failingImportStateStore{panics: panics}
+		_, err := target.Restore(height, snapshottypes.CurrentFormat, protoio.NewDelimitedReader(bytes.NewReader(snapshot.Bytes()), snapshotFileMaxItemSize))
+		require.ErrorIs(t, err, ErrRestoreSSImport)
+		require.Contains(t, err.Error(), "disk failure")
+	}
+}
+
+// newMultiStoreSnapshot returns a snapshot of a store with the stores of the keys, each one holding size keys,
+// together with the roots of the stores.
+func newMultiStoreSnapshot(tb testing.TB, keys []types.StoreKey, size int) (uint64, []byte, map[string][]byte) {
+	source := NewStore(tb.TempDir(), log.NewNopLogger(), config.StateCommitConfig{}, config.StateStoreConfig{})
+	for _, key := range keys {
+		source.MountStoreWithDB(key, types.StoreTypeIAVL, nil)
+	}
+	require.NoError(tb, source.LoadLatestVersion())
+	defer source.Close()
+	for _, key := range keys {
+		kvStore := source.GetKVStore(key)
+		for i := 0; i < size; i++ {
+			kvStore.Set([]byte(fmt.Sprintf("key-%06d", i)), []byte(fmt.Sprintf("%s-%d", key.Name(), i)))
+		}
+	}
+	height := uint64(source.Commit(true).Version)
+	var snapshot bytes.Buffer
+	require.NoError(tb, source.Snapshot(height, protoio.NewDelimitedWriter(&snapshot)))
+	return height, snapshot.Bytes(), source.LatestStoreRoots()
+}
+
+func TestRestoreSSImport(t *testing.T) {
+	keys := []types.StoreKey{types.NewKVStoreKey("acc"), types.NewKVStoreKey("bank"), types.NewKVStoreKey("staking")}
+	height, snapshot, _ := newMultiStoreSnapshot(t, keys, 100)
+
+	target := newTestStore(t, true, keys...)
+	_, err := target.Restore(height, snapshottypes.CurrentFormat, protoio.NewDelimitedReader(bytes.NewReader(snapshot), snapshotFileMaxItemSize))
+	require.NoError(t, err)
+	for _, key := range keys {
+		for _, i := range []int{0, 50, 99} {
+			value, err := target.ssStore.Get(key.Name(), int64(height), []byte(fmt.Sprintf("key-%06d", i)))
+			require.NoError(t, err)
+			require.Equal(t, []byte(fmt.Sprintf("%s-%d", key.Name(), i)), value)
+		}
+	}
+}
+
+func TestRestoreSSImportFilter(t *testing.T) {
+	bank, acc := types.NewKVStoreKey("bank"), types.NewKVStoreKey("acc")
+	height, snapshot, _ := newMultiStoreSnapshot(t, []types.StoreKey{acc, bank}, 100)
+
+	target := newTestStore(t, true, acc, bank)
+	WithSSImportFilter("bank", func(key, value []byte) (bool, []byte) {
+		if bytes.HasPrefix(key, []byte("key-00001")) {
+			return false, nil
+		}
+		if bytes.HasPrefix(key, []byte("key-00002")) {
+			return true, append([]byte("rewritten-"), value...)
+		}
+		return true, nil
+	})(target)
+	_, err := target.Restore(height, snapshottypes.CurrentFormat, protoio.NewDelimitedReader(bytes.NewReader(snapshot), snapshotFileMaxItemSize))
+	require.NoError(t, err)
+
+	ssGet := func(storeName string, key string) []byte {
+		value, err := target.ssStore.Get(storeName, int64(height), []byte(key))
+		require.NoError(t, err)
+		return value
+	}
+	require.Nil(t, ssGet("bank", "key-000015"))
+	require.Equal(t, []byte("rewritten-bank-25"), ssGet("bank", "key-000025"))
+	require.Equal(t, []byte("bank-35"), ssGet("bank", "key-000035"))
+	require.Equal(t, []byte("acc-15"), ssGet("acc", "key-000015"))
+	// the sc store imports the leaves unchanged
+	require.Equal(t, []byte("bank-15"), target.GetKVStore(bank).Get([]byte("key-000015")))
+	require.Equal(t, []byte("bank-25"), target.GetKVStore(bank).Get([]byte("key-000025")))
+}
+
+func TestRestoreStoreRoots(t *testing.T) {
+	keys := []types.StoreKey{types.NewKVStoreKey("acc"), types.NewKVStoreKey("bank")}
+	height, snapshot, roots := newMultiStoreSnapshot(t, keys, 100)
+	restore := func(roots map[string][]byte) error {
+		target := NewStore(t.TempDir(), log.NewNopLogger(), config.StateCommitConfig{}, config.StateStoreConfig{})
+		for _, key := range keys {
+			target.MountStoreWithDB(key, types.StoreTypeIAVL, nil)
+		}
+		require.NoError(t, target.LoadLatestVersion())
+		target.SetExpectedStoreRoots(roots)
+		_, err := target.Restore(height, snapshottypes.CurrentFormat, protoio.NewDelimitedReader(bytes.NewReader(snapshot), snapshotFileMaxItemSize))
+		if err == nil {
+			require.NoError(t, target.Close())
+		}
+		return err
+	}
+	require.NoError(t, restore(roots))
+	require.NoError(t, restore(nil))
+
+	// the first corrupted store fails the restore
+	for _, name := range []string{"acc", "bank"} {
+		corrupted := map[string][]byte{"acc": roots["acc"], "bank": roots["bank"]}
+		corrupted[name] = []byte("corrupted")
+		err := restore(corrupted)
+		require.ErrorIs(t, err, ErrRestoreStoreRoot)
+		require.Contains(t, err.Error(), fmt.Sprintf("store %s:", name))
+	}
+}
+
+func TestStoreRootVerifierEmptyStore(t *testing.T) {
+	verifier := newStoreRootVerifier(map[string][]byte{"empty": emptyTreeHash})
+	require.NoError(t, verifier.start("empty"))
+	require.NoError(t, verifier.finish())
+	require.Nil(t, newStoreRootVerifier(nil))
+}
+
+func TestRestorePipeline(t *testing.T) {
+	keys := []types.StoreKey{types.NewKVStoreKey("acc"), types.NewKVStoreKey("bank")}
+	height, snapshot, roots := newMultiStoreSnapshot(t, keys, 100)
+	// an extension follows the stores, it's read by the caller after the restore
+	var stream bytes.Buffer
+	stream.Write(snapshot)
+	writer := protoio.NewDelimitedWriter(&stream)
+	require.NoError(t, writer.WriteMsg(&snapshottypes.SnapshotItem{
+		Item: &snapshottypes.SnapshotItem_Extension{Extension: &snapshottypes.SnapshotExtensionMeta{Name: "wasm", Format: 1}},
+	}))
+	require.NoError(t, writer.WriteMsg(&snapshottypes.SnapshotItem{
+		Item: &snapshottypes.SnapshotItem_ExtensionPayload{ExtensionPayload: &snapshottypes.SnapshotExtensionPayload{Payload: []byte("payload")}},
+	}))
+
+	target := newTestStore(t, false, keys...)
+	target.restores.pipelineDepth = 2
+	reader := protoio.NewDelimitedReader(&stream, snapshotFileMaxItemSize)
+	item, err := target.Restore(height, snapshottypes.CurrentFormat, reader)
+	require.NoError(t, err)
+	require.Equal(t, "wasm", item.GetExtension().Name)
+	require.Equal(t, roots, target.LatestStoreRoots())
+
+	var payload snapshottypes.SnapshotItem
+	require.NoError(t, reader.ReadMsg(&payload))
+	require.Equal(t, []byte("payload"), payload.GetExtensionPayload().Payload)
+
+	// the errors of the decoding are returned by the restore, the failed store is not closed
+	target = NewStore(t.TempDir(), log.NewNopLogger(), config.StateCommitConfig{}, config.StateStoreConfig{}, WithRestorePipeline(2))
+	for _, key := range keys {
+		target.MountStoreWithDB(key, types.StoreTypeIAVL, nil)
+	}
+	require.NoError(t, target.LoadLatestVersion())
+	_, err = target.Restore(height, snapshottypes.CurrentFormat, protoio.NewDelimitedReader(bytes.NewReader(snapshot[:len(snapshot)/2]), snapshotFileMaxItemSize))
+	require.ErrorIs(t, err, ErrRestoreTruncated)
+}
+
+func BenchmarkRestorePipeline(b *testing.B) {
+	var keys []types.StoreKey
+	for i := 0; i < 8; i++ {
+		keys = append(keys, types.NewKVStoreKey(fmt.Sprintf("store%d", i)))
+	}
+	height, snapshot, _ := newMultiStoreSnapshot(b, keys, 5000)
+	for _, depth := range []int{0, 1000} {
+		b.Run(fmt.Sprintf("depth-%d", depth), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				target := NewStore(b.TempDir(), log.NewNopLogger(), config.StateCommitConfig{}, config.StateStoreConfig{}, WithRestorePipeline(depth))
+				for _, key := range keys {
+					target.MountStoreWithDB(key, types.StoreTypeIAVL, nil)
+				}
+				require.NoError(b, target.LoadLatestVersion())
+				b.StartTimer()
+				_, err := target.Restore(height, snapshottypes.CurrentFormat, protoio.NewDelimitedReader(bytes.NewReader(snapshot), snapshotFileMaxItemSize))
+				require.NoError(b, err)
+				b.StopTimer()
+				require.NoError(b, target.Close())
+			}
+		})
+	}
+}
+
+func TestRestorePreconditions(t *testing.T) {
+	key := types.NewKVStoreKey("bank")
+	source := newSnapshotSourceStore(t, key)
+	height := uint64(source.LastCommitID().Version)
+	var snapshot bytes.Buffer
+	require.NoError(t, source.Snapshot(height, protoio.NewDelimitedWriter(&snapshot)))
+
+	target := newTestStore(t, false, key)
+	for _, format := range []uint32{0, SnapshotFormat + 1} {
+		_, err := target.Restore(height, format, protoio.NewDelimitedReader(bytes.NewReader(snapshot.Bytes()), snapshotFileMaxItemSize))
+		require.ErrorIs(t, err, snapshottypes.ErrUnknownFormat)
+	}
+
+	// the store is left untouched if it's not empty
+	target.GetKVStore(key).Set([]byte("a"), []byte("1"))
+	target.Commit(true)
+	_, err := target.Restore(height, SnapshotFormat, protoio.NewDelimitedReader(bytes.NewReader(snapshot.Bytes()), snapshotFileMaxItemSize))
+	require.ErrorIs(t, err, ErrRestoreNotEmpty)
+	require.Equal(t, int64(1), target.LastCommitID().Version)
+	require.Equal(t, []byte("1"), target.GetKVStore(key).Get([]byte("a")))
+	target.GetKVStore(key).Set([]byte("a"), []byte("2"))
+	target.Commit(true)
+}
+
+type closeTrackingCommitter struct {
+	sctypes.Committer
+	exporter *closeTrackingExporter
+}
+
+func (c *closeTrackingCommitter) Exporter(version int64) (sctypes.Exporter, error) {
+	exporter, err := c.Committer.Exporter(version)
+	if err != nil {
+		return nil, err
+	}
+	c.exporter = &closeTrackingExporter{Exporter: exporter}
+	return c.exporter, nil
+}
+
+type closeTrackingExporter struct {
+	sctypes.Exporter
+	closed bool
+}
+
+func (e *closeTrackingExporter) Close() error {
+	e.closed = true
+	return e.Exporter.Close()
+}
+
+func TestSnapshotContext(t *testing.T) {
+	keys := []types.StoreKey{types.NewKVStoreKey("acc"), types.NewKVStoreKey("bank")}
+	source := newTestStore(t, false, keys...)
+	for _, key := range keys {
+		for i := 0; i < 10; i++ {
+			source.GetKVStore(key).Set([]byte(fmt.Sprintf("key-%d", i)), []byte("value"))
+		}
+	}
+	height := uint64(source.Commit(true).Version)
+
+	// each store of 10 leaves has 19 nodes
+	var reports []map[string]int64
+	progress := &SnapshotProgress{Interval: 10, OnProgress: func(nodes map[string]int64) {
+		reports = append(reports, nodes)
+	}}
+	var snapshot bytes.Buffer
+	require.NoError(t, source.SnapshotContext(context.Background(), height, protoio.NewDelimitedWriter(&snapshot), progress))
+	require.Equal(t, []map[string]int64{
+		{"acc": 10},
+		{"acc": 19, "bank": 1},
+		{"acc": 19, "bank": 11},
+	}, reports)
+
+	// the export is aborted once the context is canceled
+	committer := &closeTrackingCommitter{Committer: source.scStore}
+	source.scStore = committer
+	ctx, cancel := context.WithCancel(context.Background())
+	progress.OnProgress = func(map[string]int64) {
+		cancel()
+	}
+	var items int
+	err := source.SnapshotContext(ctx, height, protoWriterFunc(func(proto.Message) error {
+		items++
+		return nil
+	}), progress)
+	require.ErrorIs(t, err, context.Canceled)
+	// the store item and the nodes until the first progress report
+	require.Equal(t, 11, items)
+	require.True(t, committer.exporter.closed)
+}
+
+type protoWriterFunc func(proto.Message) error
+
+func (f protoWriterFunc) WriteMsg(msg proto.Message) error {
+	return f(msg)
+}
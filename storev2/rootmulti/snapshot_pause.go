@@ -0,0 +1,69 @@
+package rootmulti
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// WithSnapshotCommitPause pauses the commits and the readers for up to maxPause while a latest version snapshot opens.
+func WithSnapshotCommitPause(maxPause time.Duration) Option {
+	return func(rs *Store) {
+		rs.snapshots.commitPause = maxPause
+	}
+}
+
+// snapshotPauseRetry is the interval of the attempts to pause the commits.
+const snapshotPauseRetry = time.Millisecond
+
+// ErrCommitPauseTimeout is returned by pauseCommits if a commit holds the store for longer than the pause budget.
+var ErrCommitPauseTimeout = fmt.Errorf("timeout pausing the commits")
+
+// pauseCommits blocks the commits until the returned release is called, the pause is bounded by maxPause,
+// both to acquire it and to hold it, it's released automatically once maxPause elapses so no block is missed.
+func (rs *Store) pauseCommits(maxPause time.Duration) (func(), error) {
+	deadline := time.Now().Add(maxPause)
+	for !rs.mtx.TryLock() {
+		if time.Now().After(deadline) {
+			return nil, ErrCommitPauseTimeout
+		}
+		time.Sleep(snapshotPauseRetry)
+	}
+	start := time.Now()
+	var once sync.Once
+	resume := func() {
+		once.Do(func() {
+			rs.mtx.Unlock()
+			rs.logger.Debug("commits resumed", "paused", time.Since(start))
+		})
+	}
+	timer := time.AfterFunc(time.Until(deadline), func() {
+		rs.logger.Info("commit pause aborted, the pause budget is exceeded", "max-pause", maxPause)
+		resume()
+	})
+	return func() {
+		timer.Stop()
+		resume()
+	}, nil
+}
+
+// pauseCommitsForSnapshot pauses the commits if the snapshot commit pause is enabled and the height is the latest
+// version, the returned release is a no-op otherwise. The latest version is read once the pause holds rs.mtx, so
+// it can't race with a commit. If the commits can't be paused within the budget, the snapshot is taken without
+// pausing them like when it's disabled.
+func (rs *Store) pauseCommitsForSnapshot(height int64) func() {
+	if rs.snapshots.commitPause <= 0 {
+		return func() {}
+	}
+	release, err := rs.pauseCommits(rs.snapshots.commitPause)
+	if err != nil {
+		rs.logger.Info("snapshot taken without pausing the commits", "height", height, "err", err)
+		return func() {}
+	}
+	if rs.lastCommitInfo == nil || rs.lastCommitInfo.Version != height {
+		// a historical height, the exporter reads a fixed version so the commits don't need to be paused
+		release()
+		return func() {}
+	}
+	return release
+}
@@ -0,0 +1,76 @@
+package rootmulti
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	snapshottypes "github.com/cosmos/cosmos-sdk/snapshots/types"
+	"github.com/cosmos/cosmos-sdk/store/types"
+	protoio "github.com/gogo/protobuf/io"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPauseCommits(t *testing.T) {
+	key := types.NewKVStoreKey("bank")
+	store := newTestStore(t, false, key)
+
+	release, err := store.pauseCommits(time.Minute)
+	require.NoError(t, err)
+	committed := make(chan struct{})
+	go func() {
+		store.GetKVStore(key).Set([]byte("a"), []byte("1"))
+		store.Commit(true)
+		close(committed)
+	}()
+	select {
+	case <-committed:
+		t.Fatal("commit is not paused")
+	case <-time.After(50 * time.Millisecond):
+	}
+	release()
+	<-committed
+	// releasing again is a no-op
+	release()
+
+	// the pause is aborted once the budget elapses
+	_, err = store.pauseCommits(50 * time.Millisecond)
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		if !store.mtx.TryLock() {
+			return false
+		}
+		store.mtx.Unlock()
+		return true
+	}, time.Second, 10*time.Millisecond)
+
+	// the pause can't be acquired while a commit holds the store
+	store.mtx.RLock()
+	_, err = store.pauseCommits(20 * time.Millisecond)
+	store.mtx.RUnlock()
+	require.ErrorIs(t, err, ErrCommitPauseTimeout)
+}
+
+func TestSnapshotCommitPause(t *testing.T) {
+	key := types.NewKVStoreKey("bank")
+	source := newSnapshotSourceStore(t, key)
+	source.snapshots.commitPause = time.Second
+	height := uint64(source.LastCommitID().Version)
+
+	var snapshot bytes.Buffer
+	require.NoError(t, source.Snapshot(height, protoio.NewDelimitedWriter(&snapshot)))
+	// the commits are resumed once the snapshot is taken
+	require.True(t, source.mtx.TryLock())
+	source.mtx.Unlock()
+	// the commits are not kept paused for a historical height
+	release := source.pauseCommitsForSnapshot(int64(height) - 1)
+	require.True(t, source.mtx.TryLock())
+	source.mtx.Unlock()
+	release()
+
+	target := newTestStore(t, false, key)
+	_, err := target.Restore(height, snapshottypes.CurrentFormat, protoio.NewDelimitedReader(&snapshot, snapshotFileMaxItemSize))
+	require.NoError(t, err)
+	require.Equal(t, source.LastCommitID(), target.LastCommitID())
+	require.Equal(t, iterateAll(source.GetKVStore(key)), iterateAll(target.GetKVStore(key)))
+}
@@ -0,0 +1,53 @@
+package rootmulti
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ssFormatVersion is the version of the layout of the SS data written by the store, bumped when the SS data written
+// before can't be served anymore. Format 1 writes the changes at the version sc commits them at, the SS data written
+// before is one version behind sc.
+const ssFormatVersion = 1
+
+// ErrSSFormat is returned when the SS data is not in the format written by the store.
+var ErrSSFormat = fmt.Errorf("unsupported state store format")
+
+// ssFormatPath returns the file recording the format of the SS data, it's next to the SS directory owned by the backend.
+func (rs *Store) ssFormatPath() string {
+	return rs.ssDir() + ".format"
+}
+
+// checkSSFormat checks the SS data is in the format written by the store, the format is recorded if the SS store is
+// empty. The SS data written before the format is recorded is one version behind sc, it must be removed and re-synced
+// from a state sync snapshot.
+func (rs *Store) checkSSFormat() error {
+	bz, err := os.ReadFile(rs.ssFormatPath())
+	if err == nil {
+		format, err := strconv.Atoi(strings.TrimSpace(string(bz)))
+		if err != nil {
+			return fmt.Errorf("%w: invalid format file %s: %v", ErrSSFormat, rs.ssFormatPath(), err)
+		}
+		if format != ssFormatVersion {
+			return fmt.Errorf("%w: state store at %s has format %d, expected %d", ErrSSFormat, rs.ssDir(), format, ssFormatVersion)
+		}
+		return nil
+	}
+	if !os.IsNotExist(err) {
+		return err
+	}
+	latest, err := rs.ssStore.GetLatestVersion()
+	if err != nil {
+		return err
+	}
+	if latest > 0 {
+		return fmt.Errorf("%w: state store at %s was written before format %d, its versions are one behind the sc store, "+
+			"remove it and re-sync it from a state sync snapshot", ErrSSFormat, rs.ssDir(), ssFormatVersion)
+	}
+	if rs.readOnly {
+		return nil
+	}
+	return os.WriteFile(rs.ssFormatPath(), []byte(strconv.Itoa(ssFormatVersion)), 0o600)
+}
@@ -0,0 +1,55 @@
+package rootmulti
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/cosmos/cosmos-sdk/store/types"
+	"github.com/sei-protocol/sei-db/config"
+	"github.com/stretchr/testify/require"
+	"github.com/tendermint/tendermint/libs/log"
+)
+
+func TestSSFormat(t *testing.T) {
+	key := types.NewKVStoreKey("bank")
+	home := t.TempDir()
+	ssConfig := config.DefaultStateStoreConfig()
+	ssConfig.Enable = true
+	open := func(opts ...Option) (store *Store, err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = r.(error)
+			}
+		}()
+		store = NewStore(home, log.NewNopLogger(), config.StateCommitConfig{}, ssConfig, opts...)
+		store.MountStoreWithDB(key, types.StoreTypeIAVL, nil)
+		require.NoError(t, store.LoadLatestVersion())
+		return store, nil
+	}
+
+	// the format of an empty SS store is recorded
+	store, err := open()
+	require.NoError(t, err)
+	store.GetKVStore(key).Set([]byte("a"), []byte("1"))
+	store.Commit(true)
+	waitForSS(t, store, 1)
+	formatPath := store.ssFormatPath()
+	require.NoError(t, store.Close())
+	store, err = open()
+	require.NoError(t, err)
+	require.NoError(t, store.Close())
+
+	// the SS data written before the format is recorded is rejected
+	require.NoError(t, os.Remove(formatPath))
+	for _, opts := range [][]Option{nil, {WithReadOnly()}} {
+		_, err = open(opts...)
+		require.ErrorIs(t, err, ErrSSFormat)
+	}
+	_, err = os.Stat(formatPath)
+	require.True(t, errors.Is(err, os.ErrNotExist))
+
+	require.NoError(t, os.WriteFile(formatPath, []byte("2"), 0o600))
+	_, err = open()
+	require.ErrorIs(t, err, ErrSSFormat)
+}
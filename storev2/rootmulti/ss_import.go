@@ -0,0 +1,75 @@
+package rootmulti
+
+import (
+	sstypes "github.com/sei-protocol/sei-db/ss/types"
+)
+
+const (
+	// ssImportBufferSize is the buffer of snapshot nodes of the SS import.
+	ssImportBufferSize = 10000
+	// ssImportBatchSize is the max number of pairs of the changesets written by the SS import.
+	ssImportBatchSize = 10000
+)
+
+// SSImportFilter is consulted by restore for each leaf of its store before importing it into SS, it returns
+// whether to import the leaf and the value to import, nil to keep the original value. The sc store always
+// imports the leaves unchanged, since the app hash depends on them.
+type SSImportFilter func(key, value []byte) (include bool, newValue []byte)
+
+// WithSSImportFilter registers the filter of the leaves of the store imported into SS while restoring.
+func WithSSImportFilter(storeName string, filter SSImportFilter) Option {
+	return func(rs *Store) {
+		if rs.restores.ssImportFilters == nil {
+			rs.restores.ssImportFilters = make(map[string]SSImportFilter)
+		}
+		rs.restores.ssImportFilters[storeName] = filter
+	}
+}
+
+// ssImporter imports the snapshot nodes into the SS store on its own goroutine while the snapshot is read.
+type ssImporter struct {
+	nodes chan sstypes.SnapshotNode
+	done  chan error
+	// failed is closed on the import failure, err holds its error.
+	failed chan struct{}
+	err    error
+}
+
+func (rs *Store) newSSImporter(height int64) *ssImporter {
+	importer := &ssImporter{
+		nodes:  make(chan sstypes.SnapshotNode, ssImportBufferSize),
+		done:   make(chan error, 1),
+		failed: make(chan struct{}),
+	}
+	go func() {
+		err := rs.importSS(height, importer.nodes)
+		if err != nil {
+			importer.err = err
+			close(importer.failed)
+		}
+		importer.done <- err
+	}()
+	return importer
+}
+
+// add sends the node to the import, it returns the error of the import if it failed,
+// so the restore is aborted without reading the rest of the snapshot.
+func (i *ssImporter) add(node sstypes.SnapshotNode) error {
+	select {
+	case <-i.failed:
+		return i.err
+	default:
+	}
+	select {
+	case i.nodes <- node:
+		return nil
+	case <-i.failed:
+		return i.err
+	}
+}
+
+// close waits for the import to finish and returns its error.
+func (i *ssImporter) close() error {
+	close(i.nodes)
+	return <-i.done
+}
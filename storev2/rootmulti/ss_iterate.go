@@ -0,0 +1,89 @@
+package rootmulti
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/cosmos/cosmos-sdk/store/types"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+// IteratePrefixAt iterates the keys with the prefix in the store at a historical version from the SS store,
+// in ascending order like the prefix iterators at latest height, until fn returns true.
+// The version must be retained by the SS store, the prefix bounds the versioned iterators of the SS backend.
+func (rs *Store) IteratePrefixAt(storeName string, prefix []byte, version int64, fn func(key, value []byte) bool) error {
+	if err := rs.checkSSReadable(storeName, version); err != nil {
+		return err
+	}
+	return rs.iterateRangeAt(storeName, prefix, types.PrefixEndBytes(prefix), version, fn)
+}
+
+// IterateVersioned iterates all the keys of the store at a historical version from the SS store in ascending order,
+// while fn returns true.
+// It fails if SS is disabled or the version is not retained by the SS store.
+func (rs *Store) IterateVersioned(storeName string, version int64, fn func(key, value []byte) bool) error {
+	if err := rs.checkSSReadable(storeName, version); err != nil {
+		return err
+	}
+	return rs.iterateRangeAt(storeName, nil, nil, version, func(key, value []byte) bool {
+		return !fn(key, value)
+	})
+}
+
+// checkSSReadable checks the store is mounted and the version is retained by the SS store.
+func (rs *Store) checkSSReadable(storeName string, version int64) error {
+	if rs.ssStore == nil {
+		return ErrStateStoreDisabled
+	}
+	rs.mtx.RLock()
+	_, ok := rs.storeKeys[storeName]
+	rs.mtx.RUnlock()
+	if !ok {
+		return fmt.Errorf("store not found: %s", storeName)
+	}
+	return rs.checkSSRetained(version, version)
+}
+
+// iterateRangeAt iterates the keys in [start, end) in the store at a retained version in ascending order,
+// until fn returns true, nil bounds are unbounded. The range is read with the versioned iterator of the SS backend,
+// seeked to its bounds, so only the keys up to the one fn stops at are read.
+// It fails instead of looping if the iterator doesn't move forward, which the pebbledb iterator of sei-db v0.0.25
+// does on the keys created after the version.
+func (rs *Store) iterateRangeAt(storeName string, start, end []byte, version int64, fn func(key, value []byte) bool) error {
+	// the backends reject the empty bounds
+	if len(start) == 0 {
+		start = nil
+	}
+	if len(end) == 0 {
+		end = nil
+	}
+	itr, err := rs.ssStore.Iterator(storeName, version, start, end)
+	if err != nil {
+		return err
+	}
+	defer itr.Close()
+	var prev []byte
+	for ; itr.Valid(); itr.Next() {
+		key := sdk.CopyBytes(itr.Key())
+		if prev != nil && bytes.Compare(key, prev) <= 0 {
+			return fmt.Errorf("state store iterator of %s at version %d doesn't move forward at key %X", storeName, version, key)
+		}
+		if fn(key, sdk.CopyBytes(itr.Value())) {
+			return nil
+		}
+		prev = key
+	}
+	return itr.Error()
+}
+
+// checkSSRetained checks the versions in [fromVersion, toVersion] are retained by the SS store.
+func (rs *Store) checkSSRetained(fromVersion, toVersion int64) error {
+	latest, err := rs.ssStore.GetLatestVersion()
+	if err != nil {
+		return err
+	}
+	if earliest := rs.ssEarliestVersion(); fromVersion < earliest || toVersion > latest {
+		return fmt.Errorf("version range [%d, %d] is not retained by the state store, retained versions are [%d, %d]", fromVersion, toVersion, earliest, latest)
+	}
+	return nil
+}
@@ -1,31 +1,32 @@
 package rootmulti
 
 import (
+	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"math"
 	"sort"
 	"strings"
 	"sync"
+	"time"
 
-	"cosmossdk.io/errors"
-	snapshottypes "github.com/cosmos/cosmos-sdk/snapshots/types"
+	metrics "github.com/armon/go-metrics"
 	"github.com/cosmos/cosmos-sdk/store/cachemulti"
 	"github.com/cosmos/cosmos-sdk/store/mem"
-	"github.com/cosmos/cosmos-sdk/store/rootmulti"
 	"github.com/cosmos/cosmos-sdk/store/transient"
 	"github.com/cosmos/cosmos-sdk/store/types"
 	"github.com/cosmos/cosmos-sdk/storev2/commitment"
+	"github.com/cosmos/cosmos-sdk/storev2/pruning"
 	"github.com/cosmos/cosmos-sdk/storev2/state"
-	sdkerrors "github.com/cosmos/cosmos-sdk/types/errors"
-	protoio "github.com/gogo/protobuf/io"
+	"github.com/cosmos/cosmos-sdk/telemetry"
 	commonerrors "github.com/sei-protocol/sei-db/common/errors"
+	"github.com/sei-protocol/sei-db/common/utils"
 	"github.com/sei-protocol/sei-db/config"
 	"github.com/sei-protocol/sei-db/proto"
 	"github.com/sei-protocol/sei-db/sc"
 	sctypes "github.com/sei-protocol/sei-db/sc/types"
 	"github.com/sei-protocol/sei-db/ss"
-	"github.com/sei-protocol/sei-db/ss/pruning"
 	sstypes "github.com/sei-protocol/sei-db/ss/types"
 	abci "github.com/tendermint/tendermint/abci/types"
 	"github.com/tendermint/tendermint/libs/log"
@@ -37,6 +38,31 @@ var (
 	_ types.Queryable        = (*Store)(nil)
 )
 
+// DefaultMaxStores is the default limit of the number of mounted stores, see WithMaxStores.
+const DefaultMaxStores = 1024
+
+// DefaultHistoricalSCCacheSize is the default number of historical sc versions cached if SS is disabled,
+// see WithHistoricalSCCacheSize.
+const DefaultHistoricalSCCacheSize = 4
+
+// DefaultPendingChangesBuffer is the default number of versions buffered before being applied to SS,
+// see WithPendingChangesBuffer.
+const DefaultPendingChangesBuffer = 1000
+
+// DefaultCloseTimeout is the default time Close waits for the pending changes to be applied to SS,
+// see WithCloseTimeout.
+const DefaultCloseTimeout = time.Minute
+
+// DefaultPendingChangesWarnRatio is the default fill ratio of the pending changes buffer above which a warning
+// is logged, see WithPendingChangesWarnRatio.
+const DefaultPendingChangesWarnRatio = 0.8
+
+// ErrStateStoreDisabled is returned by the APIs relying on the state store when it's not enabled.
+var ErrStateStoreDisabled = fmt.Errorf("state store is not enabled")
+
+// ErrSSSizeUnsupported is returned by SSSizeByVersionRange if the SS backend can't attribute its size to versions.
+var ErrSSSizeUnsupported = fmt.Errorf("state store backend doesn't support size estimation by version range")
+
 type Store struct {
 	logger         log.Logger
 	mtx            sync.RWMutex
@@ -48,11 +74,172 @@ type Store struct {
 	ckvStores      map[types.StoreKey]types.CommitKVStore
 	pendingChanges chan VersionedChangesets
 	pruningManager *pruning.Manager
+	pruningOptions []pruning.Option
+	// pruningOpts are the pruning options of the app, reconciled with ssKeepRecent, the SS keep-recent config.
+	pruningOpts    types.PruningOptions
+	ssKeepRecent   int64
+	initialVersion int64
+	// historicalStores caches the historical sc stores loaded by proof queries, nil if disabled.
+	historicalStores *historicalStores
+	// ssMtx is held by StateStoreCommit while applying changes, ssResumed is signaled when SS is resumed,
+	// ssApplied once the changes of a version are applied.
+	ssMtx     sync.Mutex
+	ssResumed *sync.Cond
+	ssApplied *sync.Cond
+	ssPaused  bool
+	// ssAppliedVersion is the last version applied to SS by this store, guarded by ssMtx.
+	ssAppliedVersion int64
+	// ssGeneration is incremented by Reset, the changes flushed before are discarded by the SS commit routine.
+	ssGeneration uint64
+	// pendingByStore counts the changesets per store enqueued in pendingChanges and not yet applied to SS.
+	pendingMtx     sync.Mutex
+	pendingByStore map[string]int
+	// lastFlushStats is the number of pairs flushed per store by the last commit.
+	flushStatsMtx  sync.Mutex
+	lastFlushStats map[string]int
+	// checkChangesetOrder enables the invariant check of the changesets ordering in flush.
+	checkChangesetOrder bool
+	// extraStoreInfos caches the empty store infos of the non-IAVL stores amended to the commit info,
+	// it only changes when a store is mounted.
+	extraStoreInfos []types.StoreInfo
+	// workingHash caches the result of GetWorkingHash if cacheWorkingHash is enabled,
+	// it's reset to nil when new changes are flushed or the version changes.
+	cacheWorkingHash bool
+	workingHash      []byte
+	// loaded is set once the stores are loaded, no store can be mounted afterwards.
+	loaded bool
+	// scDir is the directory of the sc store.
+	scDir string
+	// homeDir and the configs the stores were created with, to create them again on Reset.
+	homeDir  string
+	scConfig config.StateCommitConfig
+	ssConfig config.StateStoreConfig
+	// commitMtx is held by TryCommit for the whole commit, from the flush to the reload of the stores.
+	commitMtx sync.Mutex
+	// readOnly rejects the writes, see WithReadOnly.
+	readOnly bool
+	// maxStores limits the number of mounted stores.
+	maxStores int
+	// storeCommitHooks are notified of the committed changes per store through storeCommitEvents,
+	// stagedHookChanges holds the flushed changesets of the stores with hooks until they are committed.
+	hooksMtx          sync.RWMutex
+	storeCommitHooks  map[string][]StoreCommitHook
+	storeCommitEvents chan storeCommitEvent
+	stagedHookChanges []*proto.NamedChangeSet
+	// syncSSCommit applies the changes to SS in flush instead of the SS commit routine.
+	syncSSCommit bool
+	// ssErrorHandler is notified of the failures of StateStoreCommit, nil to panic.
+	ssErrorHandler SSErrorHandler
+	// ssCommitDone is closed once StateStoreCommit has applied all the pending changes after Close.
+	ssCommitDone chan struct{}
+	closeTimeout time.Duration
+	// pendingChangesBuffer is the capacity of pendingChanges.
+	pendingChangesBuffer int
+	// pendingChangesWarnRatio is the fill ratio of pendingChanges above which flush logs a warning, 0 if disabled.
+	pendingChangesWarnRatio float64
+	// loadSCVersion and loadSSVersion are the versions of the sc and SS stores when the stores were last loaded.
+	loadSCVersion int64
+	loadSSVersion int64
+	// keepRecentProofs is the minimum number of recent versions the sc store retains for the proof queries.
+	keepRecentProofs uint32
+	queries          queryConfig
+	snapshots        snapshotConfig
+	restores         restoreConfig
+}
+
+// Option configures optional behaviors of the Store which are not covered by the seidb configs.
+type Option func(*Store)
+
+// WithPruningOptions sets the options passed to the state store pruning manager.
+func WithPruningOptions(opts ...pruning.Option) Option {
+	return func(rs *Store) {
+		rs.pruningOptions = append(rs.pruningOptions, opts...)
+	}
+}
+
+// WithHistoricalSCCacheSize keeps up to size historical versions of the sc store open, 0 disables the cache.
+func WithHistoricalSCCacheSize(size int) Option {
+	return func(rs *Store) {
+		if size > 0 {
+			rs.historicalStores = newHistoricalStores(size)
+		}
+	}
+}
+
+// WithChangesetOrderCheck panics in flush if the changesets are not strictly sorted by store name.
+func WithChangesetOrderCheck() Option {
+	return func(rs *Store) {
+		rs.checkChangesetOrder = true
+	}
+}
+
+// WithWorkingHashCache caches the result of GetWorkingHash until the next write.
+func WithWorkingHashCache() Option {
+	return func(rs *Store) {
+		rs.cacheWorkingHash = true
+	}
+}
+
+// WithMaxStores sets the max number of stores which can be mounted, mounting more stores panics.
+func WithMaxStores(maxStores int) Option {
+	return func(rs *Store) {
+		rs.maxStores = maxStores
+	}
+}
+
+// WithPendingChangesBuffer sets the number of versions buffered before being applied to SS, it panics if negative.
+func WithPendingChangesBuffer(size int) Option {
+	return func(rs *Store) {
+		if size < 0 {
+			panic(fmt.Sprintf("invalid pending changes buffer size: %d", size))
+		}
+		rs.pendingChangesBuffer = size
+	}
+}
+
+// WithSyncSSCommit applies the changes to SS in the commits instead of the SS commit routine.
+func WithSyncSSCommit() Option {
+	return func(rs *Store) {
+		rs.syncSSCommit = true
+	}
+}
+
+// WithSSErrorHandler sets the handler of the failures to apply the changes to SS, they panic otherwise.
+func WithSSErrorHandler(handler SSErrorHandler) Option {
+	return func(rs *Store) {
+		rs.ssErrorHandler = handler
+	}
+}
+
+// WithCloseTimeout bounds the time Close waits for the pending changes to be applied to SS.
+func WithCloseTimeout(timeout time.Duration) Option {
+	return func(rs *Store) {
+		rs.closeTimeout = timeout
+	}
+}
+
+// WithPendingChangesWarnRatio logs a warning above the fill ratio of the pending changes buffer, 0 disables it.
+func WithPendingChangesWarnRatio(ratio float64) Option {
+	return func(rs *Store) {
+		rs.pendingChangesWarnRatio = ratio
+	}
+}
+
+// WithKeepRecentProofs retains at least the given number of recent versions in the sc store for the proof queries.
+func WithKeepRecentProofs(versions uint32) Option {
+	return func(rs *Store) {
+		rs.keepRecentProofs = versions
+	}
 }
 
+// VersionedChangesets are the changesets committed at a version, sorted by store name without duplicates.
+// The sc store and the SS store apply them in this order, and the SS commit routine applies the versions
+// one at a time in increasing order, so both stores are built from the same sequence of writes.
 type VersionedChangesets struct {
 	Version    int64
 	Changesets []*proto.NamedChangeSet
+	// generation is the SS generation the changes are flushed in, see Reset.
+	generation uint64
 }
 
 func NewStore(
@@ -60,41 +247,101 @@ func NewStore(
 	logger log.Logger,
 	scConfig config.StateCommitConfig,
 	ssConfig config.StateStoreConfig,
+	opts ...Option,
 ) *Store {
-	scStore := sc.NewCommitStore(homeDir, logger, scConfig)
+	scDir := homeDir
+	if scConfig.Directory != "" {
+		scDir = scConfig.Directory
+	}
 	store := &Store{
 		logger:         logger,
-		scStore:        scStore,
+		scDir:          utils.GetCommitStorePath(scDir),
+		homeDir:        homeDir,
+		ssConfig:       ssConfig,
 		storesParams:   make(map[types.StoreKey]storeParams),
 		storeKeys:      make(map[string]types.StoreKey),
 		ckvStores:      make(map[types.StoreKey]types.CommitKVStore),
-		pendingChanges: make(chan VersionedChangesets, 1000),
+		pendingByStore: make(map[string]int),
+		maxStores:      DefaultMaxStores,
+
+		closeTimeout:            DefaultCloseTimeout,
+		pendingChangesBuffer:    DefaultPendingChangesBuffer,
+		pendingChangesWarnRatio: DefaultPendingChangesWarnRatio,
+		pruningOpts:             types.PruneDefault,
+		queries: queryConfig{
+			rangeLimit:         DefaultRangeQueryLimit,
+			prefixLimit:        DefaultPrefixQueryLimit,
+			versionSearchLimit: DefaultVersionSearchLimit,
+		},
+	}
+	store.ssResumed = sync.NewCond(&store.ssMtx)
+	store.ssApplied = sync.NewCond(&store.ssMtx)
+	for _, opt := range opts {
+		opt(store)
+	}
+	scConfig.SnapshotKeepRecent = snapshotKeepRecentForProofs(store.keepRecentProofs, scConfig)
+	store.scConfig = scConfig
+	if store.readOnly {
+		store.scStore = newReadOnlyCommitter(store.scDir, logger, scConfig)
+	} else {
+		store.scStore = sc.NewCommitStore(homeDir, logger, scConfig)
+	}
+	store.pendingChanges = make(chan VersionedChangesets, store.pendingChangesBuffer)
+	if !ssConfig.Enable && store.historicalStores == nil {
+		// the historical queries fall back to the sc store, its versions are only served through the cache
+		store.historicalStores = newHistoricalStores(DefaultHistoricalSCCacheSize)
 	}
 	if ssConfig.Enable {
 		ssStore, err := ss.NewStateStore(homeDir, ssConfig)
 		if err != nil {
 			panic(err)
 		}
+		store.ssStore = ssStore
+		store.ssKeepRecent = int64(ssConfig.KeepRecent)
+		if err = store.checkSSFormat(); err != nil {
+			_ = ssStore.Close()
+			panic(err)
+		}
+		if store.readOnly {
+			return store
+		}
 		if err = ss.RecoverStateStore(homeDir, logger, ssStore); err != nil {
 			panic(err)
 		}
-		store.ssStore = ssStore
-		go store.StateStoreCommit()
+		store.ssCommitDone = make(chan struct{})
+		go func() {
+			defer close(store.ssCommitDone)
+			store.StateStoreCommit()
+		}()
 		store.pruningManager = pruning.NewPruningManager(
-			logger, ssStore, int64(ssConfig.KeepRecent), int64(ssConfig.PruneIntervalSeconds))
+			logger, ssStore, int64(ssConfig.KeepRecent), int64(ssConfig.PruneIntervalSeconds), store.pruningOptions...)
 		store.pruningManager.Start()
 	}
 	return store
 
 }
 
-// Commit implements interface Committer, called by ABCI Commit
+// Commit implements interface Committer, called by ABCI Commit, it panics on failure, see TryCommit.
 func (rs *Store) Commit(bumpVersion bool) types.CommitID {
+	commitID, err := rs.TryCommit(bumpVersion)
+	if err != nil {
+		panic(err)
+	}
+	return commitID
+}
+
+// TryCommit commits like Commit but returns the failures of the flush, the sc commit or the reload of the stores,
+// so the callers can log them with context and decide whether to retry or to halt.
+func (rs *Store) TryCommit(bumpVersion bool) (types.CommitID, error) {
 	if !bumpVersion {
-		return rs.lastCommitInfo.CommitID()
+		rs.mtx.RLock()
+		defer rs.mtx.RUnlock()
+		return rs.lastCommitInfo.CommitID(), nil
 	}
+	rs.commitMtx.Lock()
+	defer rs.commitMtx.Unlock()
 	if err := rs.flush(); err != nil {
-		panic(err)
+		return types.CommitID{}, err
 	}
 
 	rs.mtx.Lock()
@@ -105,47 +352,253 @@ func (rs *Store) Commit(bumpVersion bool) types.CommitID {
 		}
 	}
 	// Commit to SC Store
-	_, err := rs.scStore.Commit()
-	if err != nil {
-		panic(err)
+	if _, err := rs.scStore.Commit(); err != nil {
+		return types.CommitID{}, fmt.Errorf("failed to commit sc store: %w", err)
 	}
 
-	// The underlying sc store might be reloaded, reload the store as well.
+	// The underlying sc store might be reloaded, reload the stores whose tree has changed.
 	for key := range rs.ckvStores {
 		store := rs.ckvStores[key]
 		if store.GetStoreType() == types.StoreTypeIAVL {
-			rs.ckvStores[key], err = rs.loadCommitStoreFromParams(key, rs.storesParams[key])
-			if err != nil {
-				panic(fmt.Errorf("inconsistent store map, store %s not found", key.Name()))
+			if _, err := rs.reloadStore(key); err != nil {
+				return types.CommitID{}, fmt.Errorf("inconsistent store map, failed to reload store %s: %w", key.Name(), err)
 			}
 		}
 	}
 
 	rs.lastCommitInfo = convertCommitInfo(rs.scStore.LastCommitInfo())
-	rs.lastCommitInfo = amendCommitInfo(rs.lastCommitInfo, rs.storesParams)
-	return rs.lastCommitInfo.CommitID()
+	rs.lastCommitInfo = amendCommitInfo(rs.lastCommitInfo, rs.extraStoreInfos)
+	rs.workingHash = nil
+	rs.fireStoreCommitHooks(rs.lastCommitInfo.Version)
+	return rs.lastCommitInfo.CommitID(), nil
 }
 
 // StateStoreCommit is a background routine to apply changes to SS store
 func (rs *Store) StateStoreCommit() {
 	for pendingChangeSet := range rs.pendingChanges {
-		version := pendingChangeSet.Version
-		for _, cs := range pendingChangeSet.Changesets {
-			if err := rs.ssStore.ApplyChangeset(version, cs); err != nil {
-				panic(err)
-			}
+		failedStore, err := rs.applySSChanges(pendingChangeSet)
+		rs.trackPendingChanges(pendingChangeSet, -1)
+		if err != nil {
+			rs.handleSSError(pendingChangeSet.Version, failedStore, err)
+		}
+		rs.ssMtx.Lock()
+		rs.ssApplied.Broadcast()
+		rs.ssMtx.Unlock()
+	}
+}
+
+// WaitForSSApplied blocks until the changes of the versions up to version are applied to the SS store, or the timeout
+// elapses. The version must be committed already, the versions which failed to apply are considered applied,
+// see SSErrorHandler.
+func (rs *Store) WaitForSSApplied(version int64, timeout time.Duration) error {
+	if rs.ssStore == nil {
+		return ErrStateStoreDisabled
+	}
+	if committed := rs.committedVersion(); version > committed {
+		return fmt.Errorf("version %d is not committed yet, latest version is %d", version, committed)
+	}
+	timedOut := false
+	timer := time.AfterFunc(timeout, func() {
+		rs.ssMtx.Lock()
+		defer rs.ssMtx.Unlock()
+		timedOut = true
+		rs.ssApplied.Broadcast()
+	})
+	defer timer.Stop()
+	rs.ssMtx.Lock()
+	defer rs.ssMtx.Unlock()
+	// the versions without changes are not sent to SS, so they're applied once no earlier change is pending
+	for rs.ssAppliedVersion < version && len(rs.PendingChangesByStore()) > 0 {
+		if timedOut {
+			return fmt.Errorf("timeout waiting for version %d to be applied to the state store, applied till %d", version, rs.ssAppliedVersion)
+		}
+		rs.ssApplied.Wait()
+	}
+	return nil
+}
+
+// applySSChanges applies the changesets of a version to SS once it's not paused, it stops at the first failure
+// and returns the name of the store which failed. The whole version is applied while holding ssMtx, so it's not
+// interleaved with another version nor paused halfway. A version out of order or with unsorted changesets is
+// rejected before any of its changesets is applied.
+func (rs *Store) applySSChanges(pending VersionedChangesets) (string, error) {
+	rs.ssMtx.Lock()
+	defer rs.ssMtx.Unlock()
+	for rs.ssPaused {
+		rs.ssResumed.Wait()
+	}
+	if pending.generation != rs.ssGeneration {
+		// flushed before a reset of the stores
+		return "", nil
+	}
+	if pending.Version <= rs.ssAppliedVersion {
+		return "", fmt.Errorf("version %d is applied after version %d, the versions must be applied in increasing order", pending.Version, rs.ssAppliedVersion)
+	}
+	if err := validateChangesetOrder(pending.Changesets); err != nil {
+		return "", err
+	}
+	rs.ssAppliedVersion = pending.Version
+	for _, cs := range pending.Changesets {
+		if err := rs.ssStore.ApplyChangeset(pending.Version, cs); err != nil {
+			return cs.Name, err
+		}
+	}
+	return "", nil
+}
+
+// SSErrorHandler is notified of the failures to apply the changes of a version to SS, the remaining changesets
+// of the version are not applied, the next versions still are. It runs in the SS commit routine.
+type SSErrorHandler func(version int64, storeName string, err error)
+
+// handleSSError logs the failure with its context and notifies the handler, it panics if no handler is set,
+// since SS silently diverges from the sc store otherwise.
+func (rs *Store) handleSSError(version int64, storeName string, err error) {
+	rs.logger.Error("failed to apply changeset to state store", "version", version, "store", storeName, "err", err)
+	if rs.ssErrorHandler == nil {
+		panic(fmt.Errorf("failed to apply changeset of store %s at version %d to state store: %w", storeName, version, err))
+	}
+	rs.ssErrorHandler(version, storeName, err)
+}
+
+// PendingChangesByStore returns the number of changesets per store waiting to be applied to the SS store.
+func (rs *Store) PendingChangesByStore() map[string]int {
+	rs.pendingMtx.Lock()
+	defer rs.pendingMtx.Unlock()
+	result := make(map[string]int, len(rs.pendingByStore))
+	for name, count := range rs.pendingByStore {
+		result[name] = count
+	}
+	return result
+}
+
+func (rs *Store) trackPendingChanges(pending VersionedChangesets, delta int) {
+	rs.pendingMtx.Lock()
+	defer rs.pendingMtx.Unlock()
+	for _, cs := range pending.Changesets {
+		rs.pendingByStore[cs.Name] += delta
+		if rs.pendingByStore[cs.Name] <= 0 {
+			delete(rs.pendingByStore, cs.Name)
 		}
 	}
 }
 
+// PendingChangesLen returns the number of versions buffered in pendingChanges and not yet applied to SS.
+func (rs *Store) PendingChangesLen() int {
+	return len(rs.pendingChanges)
+}
+
+// PendingChangesCap returns the capacity of the pendingChanges buffer, the commits block once it's full.
+func (rs *Store) PendingChangesCap() int {
+	return cap(rs.pendingChanges)
+}
+
+// reportPendingChanges reports the fill of the pendingChanges buffer, and warns if it's close to be full.
+func (rs *Store) reportPendingChanges(version int64) {
+	pending, capacity := rs.PendingChangesLen(), rs.PendingChangesCap()
+	telemetry.SetGauge(float32(pending), "store", "ss", "pending_changes")
+	if capacity > 0 && rs.pendingChangesWarnRatio > 0 && float64(pending) >= rs.pendingChangesWarnRatio*float64(capacity) {
+		rs.logger.Info("state store is falling behind, the pending changes buffer is almost full",
+			"version", version, "pending", pending, "capacity", capacity)
+	}
+}
+
+// PauseSS stops applying the pending changes to the SS store, it returns once the changes being applied are done.
+// The new changes keep being buffered in pendingChanges until ResumeSS is called, commits block once the buffer is full.
+func (rs *Store) PauseSS() {
+	rs.ssMtx.Lock()
+	defer rs.ssMtx.Unlock()
+	rs.ssPaused = true
+}
+
+// ResumeSS resumes applying the pending changes to the SS store from where it was paused.
+func (rs *Store) ResumeSS() {
+	rs.ssMtx.Lock()
+	defer rs.ssMtx.Unlock()
+	rs.ssPaused = false
+	rs.ssResumed.Broadcast()
+}
+
+func (rs *Store) isSSPaused() bool {
+	rs.ssMtx.Lock()
+	defer rs.ssMtx.Unlock()
+	return rs.ssPaused
+}
+
 // Flush all the pending changesets to commit store.
 func (rs *Store) flush() error {
+	if rs.readOnly {
+		return ErrReadOnly
+	}
+	rs.mtx.RLock()
+	changeSets, err := rs.popChangeSets()
+	if err != nil {
+		rs.mtx.RUnlock()
+		return err
+	}
+	// the pending changes will be committed at the next version of sc store, and SS is written at the same version
+	// so both serve the same state at a height. SS data written before by this store is one version behind sc,
+	// it's rejected by checkSSFormat.
+	currentVersion := utils.NextVersion(rs.lastCommitInfo.Version, uint32(rs.initialVersion))
+	rs.mtx.RUnlock()
+	stats := make(map[string]int, len(changeSets))
+	for _, cs := range changeSets {
+		stats[cs.Name] = len(cs.Changeset.Pairs)
+	}
+	rs.flushStatsMtx.Lock()
+	rs.lastFlushStats = stats
+	rs.flushStatsMtx.Unlock()
+	if changeSets != nil && len(changeSets) > 0 {
+		rs.workingHash = nil
+		// the changesets are applied to SS and the sc store in this order, which must be deterministic
+		sort.SliceStable(changeSets, func(i, j int) bool {
+			return changeSets[i].Name < changeSets[j].Name
+		})
+		if rs.checkChangesetOrder {
+			if err := validateChangesetOrder(changeSets); err != nil {
+				panic(err)
+			}
+		}
+		if rs.ssStore != nil {
+			pending := VersionedChangesets{
+				Version:    currentVersion,
+				Changesets: changeSets,
+				generation: rs.ssGeneration,
+			}
+			if rs.syncSSCommit {
+				if failedStore, err := rs.applySSChanges(pending); err != nil {
+					return fmt.Errorf("failed to apply changeset of store %s at version %d to state store: %w", failedStore, currentVersion, err)
+				}
+			} else {
+				rs.trackPendingChanges(pending, 1)
+				select {
+				case rs.pendingChanges <- pending:
+				default:
+					if rs.isSSPaused() {
+						rs.logger.Error("SS is paused and the pending changes buffer is full, commit is blocked until ResumeSS is called", "version", currentVersion)
+					}
+					rs.pendingChanges <- pending
+				}
+				rs.reportPendingChanges(currentVersion)
+			}
+		}
+	}
+	if err := rs.scStore.ApplyChangeSets(changeSets); err != nil {
+		return err
+	}
+	rs.stageStoreCommitHooks(changeSets)
+	return nil
+}
+
+// popChangeSets pops the pending changeset of each store, the stores without changes are omitted,
+// it must be called with rs.mtx held.
+func (rs *Store) popChangeSets() ([]*proto.NamedChangeSet, error) {
+	if missing := rs.missingTrees(); len(missing) > 0 {
+		return nil, fmt.Errorf("mounted stores without a tree in the sc store: %s", strings.Join(missing, ", "))
+	}
 	var changeSets []*proto.NamedChangeSet
-	currentVersion := rs.lastCommitInfo.Version
-	for key := range rs.ckvStores {
-		// it'll unwrap the inter-block cache
-		store := rs.GetCommitKVStore(key)
+	for key, store := range rs.ckvStores {
 		if commitStore, ok := store.(*commitment.Store); ok {
+			start := time.Now()
 			cs := commitStore.PopChangeSet()
 			if len(cs.Pairs) > 0 {
 				changeSets = append(changeSets, &proto.NamedChangeSet{
@@ -153,31 +606,141 @@ func (rs *Store) flush() error {
 					Changeset: cs,
 				})
 			}
+			// tagged by store, so a single slow store is not hidden in the aggregated flush time
+			labels := []metrics.Label{telemetry.NewLabel("store", key.Name())}
+			telemetry.MeasureSinceWithLabels([]string{"store", "flush"}, start, labels)
+			if len(cs.Pairs) > 0 {
+				var size int
+				for _, pair := range cs.Pairs {
+					size += len(pair.Key) + len(pair.Value)
+				}
+				metrics.AddSampleWithLabels([]string{"store", "flush", "pairs"}, float32(len(cs.Pairs)), labels)
+				metrics.AddSampleWithLabels([]string{"store", "flush", "bytes"}, float32(size), labels)
+			}
 		}
 	}
-	if changeSets != nil && len(changeSets) > 0 {
-		sort.SliceStable(changeSets, func(i, j int) bool {
-			return changeSets[i].Name < changeSets[j].Name
-		})
-		if rs.ssStore != nil {
-			rs.pendingChanges <- VersionedChangesets{
-				Version:    currentVersion,
-				Changesets: changeSets,
-			}
+	return changeSets, nil
+}
+
+// LastFlushStats returns the number of pairs flushed per store by the last commit, the stores without changes
+// are omitted.
+func (rs *Store) LastFlushStats() map[string]int {
+	rs.flushStatsMtx.Lock()
+	defer rs.flushStatsMtx.Unlock()
+	stats := make(map[string]int, len(rs.lastFlushStats))
+	for name, pairs := range rs.lastFlushStats {
+		stats[name] = pairs
+	}
+	return stats
+}
+
+// missingTrees returns the sorted names of the mounted IAVL stores which don't resolve to a tree of the sc store,
+// e.g. if the stores are not loaded, their changes would be missing from the commit info.
+func (rs *Store) missingTrees() []string {
+	var missing []string
+	for key, params := range rs.storesParams {
+		if params.typ != types.StoreTypeIAVL {
+			continue
+		}
+		if _, ok := rs.ckvStores[key].(*commitment.Store); !ok || !rs.loaded || rs.scStore.GetTreeByName(key.Name()) == nil {
+			missing = append(missing, key.Name())
 		}
 	}
-	return rs.scStore.ApplyChangeSets(changeSets)
+	sort.Strings(missing)
+	return missing
+}
+
+// validateChangesetOrder checks the changesets are strictly sorted by store name without duplicates.
+func validateChangesetOrder(changeSets []*proto.NamedChangeSet) error {
+	for i := 1; i < len(changeSets); i++ {
+		if changeSets[i-1].Name >= changeSets[i].Name {
+			return fmt.Errorf("changesets ordering invariant violated: %q at index %d is not strictly after %q", changeSets[i].Name, i, changeSets[i-1].Name)
+		}
+	}
+	return nil
 }
 
 func (rs *Store) Close() error {
+	if rs.historicalStores != nil {
+		rs.historicalStores.purge()
+	}
 	err := rs.scStore.Close()
 	close(rs.pendingChanges)
-	if rs.ssStore != nil {
+	rs.hooksMtx.Lock()
+	if rs.storeCommitEvents != nil {
+		close(rs.storeCommitEvents)
+		rs.storeCommitEvents = nil
+	}
+	rs.hooksMtx.Unlock()
+	if rs.pruningManager != nil {
+		rs.pruningManager.Stop()
+	}
+	switch {
+	case rs.ssStore != nil && rs.ssCommitDone != nil:
+		// the changes enqueued before closing are applied before closing SS, so SS doesn't lag after restart
+		select {
+		case <-rs.ssCommitDone:
+			err = commonerrors.Join(err, rs.ssStore.Close())
+		case <-time.After(rs.closeTimeout):
+			err = commonerrors.Join(err, fmt.Errorf("timeout applying the pending changes to the state store, %d versions not applied", len(rs.pendingChanges)))
+		}
+	case rs.ssStore != nil:
+		// the SS commit routine is not started in read-only mode
 		err = commonerrors.Join(err, rs.ssStore.Close())
 	}
 	return err
 }
 
+// LatestVersion returns the latest committed version, it's cached by the last commit info once the stores
+// are loaded, and read from the sc store on disk before.
+func (rs *Store) LatestVersion() int64 {
+	rs.mtx.RLock()
+	defer rs.mtx.RUnlock()
+	if rs.lastCommitInfo != nil {
+		return rs.lastCommitInfo.Version
+	}
+	v, err := rs.scStore.GetLatestVersion()
+	if err != nil {
+		panic(fmt.Errorf("failed to get latest version: %w", err))
+	}
+	return v
+}
+
+// committedVersion returns the version of the last commit, 0 if the stores are not loaded or no version is committed.
+func (rs *Store) committedVersion() int64 {
+	rs.mtx.RLock()
+	defer rs.mtx.RUnlock()
+	if rs.lastCommitInfo == nil {
+		return 0
+	}
+	return rs.lastCommitInfo.Version
+}
+
+// loadedVersion returns the version of the last commit, 0 if no version is committed yet,
+// and false if the stores are not loaded.
+func (rs *Store) loadedVersion() (int64, bool) {
+	rs.mtx.RLock()
+	defer rs.mtx.RUnlock()
+	if rs.lastCommitInfo == nil {
+		return 0, false
+	}
+	return rs.lastCommitInfo.Version, true
+}
+
+// LatestStoreRoots returns the root hash of each store at the latest committed version, keyed by store name.
+func (rs *Store) LatestStoreRoots() map[string][]byte {
+	rs.mtx.RLock()
+	defer rs.mtx.RUnlock()
+	if rs.lastCommitInfo == nil {
+		return map[string][]byte{}
+	}
+	roots := make(map[string][]byte, len(rs.lastCommitInfo.StoreInfos))
+	for _, storeInfo := range rs.lastCommitInfo.StoreInfos {
+		roots[storeInfo.Name] = storeInfo.CommitId.Hash
+	}
+	return roots
+}
+
 // LastCommitID Implements interface Committer
 func (rs *Store) LastCommitID() types.CommitID {
 	if rs.lastCommitInfo == nil {
@@ -191,13 +754,41 @@ func (rs *Store) LastCommitID() types.CommitID {
 	return rs.lastCommitInfo.CommitID()
 }
 
-// Implements interface Committer
-func (rs *Store) SetPruning(types.PruningOptions) {
+// SetPruning implements interface Committer, the SS pruning honors the pruning options of the app on top of
+// the SS configs: pruning nothing disables it, the keep-recent of the custom options can only extend the
+// versions retained by the SS keep-recent config, and their interval in blocks is the min number of versions
+// committed between two SS prunes, on top of the SS prune interval in seconds. The default options, which are
+// also the ones of the store until SetPruning is called, keep the SS config.
+func (rs *Store) SetPruning(opts types.PruningOptions) {
+	rs.pruningOpts = opts
+	if rs.pruningManager == nil {
+		return
+	}
+	keepRecent := rs.ssKeepRecent
+	versionInterval := int64(opts.Interval)
+	switch {
+	case opts.KeepEvery == 1:
+		// prune nothing
+		keepRecent = 0
+	case opts == types.PruneDefault:
+		// not chosen by the operator, the SS configs apply
+		versionInterval = 0
+	case keepRecent > 0 && int64(opts.KeepRecent) > keepRecent:
+		keepRecent = int64(opts.KeepRecent)
+	}
+	if err := rs.pruningManager.SetVersionInterval(versionInterval); err != nil {
+		rs.logger.Error("failed to apply the pruning options to the state store", "err", err)
+		return
+	}
+	_, pruneInterval := rs.pruningManager.Config()
+	if err := rs.pruningManager.UpdateConfig(keepRecent, pruneInterval); err != nil {
+		rs.logger.Error("failed to apply the pruning options to the state store", "err", err)
+	}
 }
 
-// Implements interface Committer
+// GetPruning implements interface Committer, it returns the pruning options last set.
 func (rs *Store) GetPruning() types.PruningOptions {
-	return types.PruneDefault
+	return rs.pruningOpts
 }
 
 // Implements interface Store
@@ -234,7 +825,7 @@ func (rs *Store) CacheMultiStore() types.CacheMultiStore {
 // CacheMultiStoreWithVersion Implements interface MultiStore
 // used to createQueryContext, abci_query or grpc query service.
 func (rs *Store) CacheMultiStoreWithVersion(version int64) (types.CacheMultiStore, error) {
-	if version <= 0 || (rs.lastCommitInfo != nil && version == rs.lastCommitInfo.Version) {
+	if version <= 0 || version == rs.LatestVersion() {
 		return rs.CacheMultiStore(), nil
 	}
 	rs.mtx.RLock()
@@ -246,8 +837,6 @@ func (rs *Store) CacheMultiStoreWithVersion(version int64) (types.CacheMultiStor
 			stores[k] = store
 		}
 	}
-	// TODO: May need to add historical SC store as well for nodes that doesn't enable ss but still need historical queries
-
 	// add SS stores for historical queries
 	if rs.ssStore != nil {
 		for k, store := range rs.ckvStores {
@@ -255,6 +844,22 @@ func (rs *Store) CacheMultiStoreWithVersion(version int64) (types.CacheMultiStor
 				stores[k] = state.NewStore(rs.ssStore, k, version)
 			}
 		}
+	} else {
+		// fall back to the historical sc store for the nodes serving historical queries without SS
+		if rs.queries.noHistoryError {
+			return nil, fmt.Errorf("node does not retain history; enable SS or run an archive node")
+		}
+		// the version is loaded in the historical stores cache, the stores acquire it again for each read
+		_, release, err := rs.loadHistoricalSC(version)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load sc store at version %d: %w", version, err)
+		}
+		release()
+		for k, store := range rs.ckvStores {
+			if store.GetStoreType() == types.StoreTypeIAVL {
+				stores[k] = &historicalSCStore{rs: rs, storeKey: k, version: version}
+			}
+		}
 	}
 
 	return cachemulti.NewStore(nil, stores, rs.storeKeys, nil, nil, nil), nil
@@ -262,11 +867,15 @@ func (rs *Store) CacheMultiStoreWithVersion(version int64) (types.CacheMultiStor
 
 // GetStore Implements interface MultiStore
 func (rs *Store) GetStore(key types.StoreKey) types.Store {
+	rs.mtx.RLock()
+	defer rs.mtx.RUnlock()
 	return rs.ckvStores[key]
 }
 
 // GetKVStore Implements interface MultiStore
 func (rs *Store) GetKVStore(key types.StoreKey) types.KVStore {
+	rs.mtx.RLock()
+	defer rs.mtx.RUnlock()
 	return rs.ckvStores[key]
 }
 
@@ -300,14 +909,79 @@ func (rs *Store) MountStoreWithDB(key types.StoreKey, typ types.StoreType, _ dbm
 	if key == nil {
 		panic("MountIAVLStore() key cannot be nil")
 	}
+	if rs.loaded {
+		panic(fmt.Sprintf("cannot mount store %s after the stores are loaded", key.Name()))
+	}
 	if _, ok := rs.storesParams[key]; ok {
 		panic(fmt.Sprintf("store duplicate store key %v", key))
 	}
 	if _, ok := rs.storeKeys[key.Name()]; ok {
 		panic(fmt.Sprintf("store duplicate store key name %v", key))
 	}
+	if len(rs.storesParams) >= rs.maxStores {
+		panic(fmt.Sprintf("cannot mount store %s, the number of stores exceeds the limit %d", key.Name(), rs.maxStores))
+	}
+	rs.mtx.Lock()
+	defer rs.mtx.Unlock()
 	rs.storesParams[key] = newStoreParams(key, typ)
 	rs.storeKeys[key.Name()] = key
+	if isAmendedStoreType(typ) {
+		rs.extraStoreInfos = append(rs.extraStoreInfos, types.StoreInfo{
+			Name:     key.Name(),
+			CommitId: types.CommitID{},
+		})
+	}
+}
+
+// StoreMount describes a store mounted by MountStores.
+type StoreMount struct {
+	Key  types.StoreKey
+	Type types.StoreType
+}
+
+// MountStores mounts the stores in one call, the mounts are validated before mounting any store
+// and all the conflicts are reported in the panic message, not only the first one.
+func (rs *Store) MountStores(mounts []StoreMount) {
+	if err := rs.validateMounts(mounts); err != nil {
+		panic(err)
+	}
+	for _, mount := range mounts {
+		rs.MountStoreWithDB(mount.Key, mount.Type, nil)
+	}
+}
+
+// validateMounts checks the mounts don't conflict with each other nor with the mounted stores.
+func (rs *Store) validateMounts(mounts []StoreMount) error {
+	var conflicts []string
+	keys := make(map[types.StoreKey]struct{}, len(mounts))
+	names := make(map[string]struct{}, len(mounts))
+	for i, mount := range mounts {
+		if mount.Key == nil {
+			conflicts = append(conflicts, fmt.Sprintf("store key at index %d cannot be nil", i))
+			continue
+		}
+		_, mounted := rs.storesParams[mount.Key]
+		_, duplicated := keys[mount.Key]
+		if mounted || duplicated {
+			conflicts = append(conflicts, fmt.Sprintf("duplicate store key %s", mount.Key.Name()))
+			continue
+		}
+		_, mounted = rs.storeKeys[mount.Key.Name()]
+		_, duplicated = names[mount.Key.Name()]
+		if mounted || duplicated {
+			conflicts = append(conflicts, fmt.Sprintf("duplicate store key name %s", mount.Key.Name()))
+			continue
+		}
+		keys[mount.Key] = struct{}{}
+		names[mount.Key.Name()] = struct{}{}
+	}
+	if total := len(rs.storesParams) + len(keys); total > rs.maxStores {
+		conflicts = append(conflicts, fmt.Sprintf("the number of stores %d exceeds the limit %d", total, rs.maxStores))
+	}
+	if len(conflicts) > 0 {
+		return fmt.Errorf("failed to mount stores: %s", strings.Join(conflicts, "; "))
+	}
+	return nil
 }
 
 // Implements interface CommitMultiStore
@@ -317,6 +991,8 @@ func (rs *Store) GetCommitStore(key types.StoreKey) types.CommitStore {
 
 // GetCommitKVStore Implements interface CommitMultiStore
 func (rs *Store) GetCommitKVStore(key types.StoreKey) types.CommitKVStore {
+	rs.mtx.RLock()
+	defer rs.mtx.RUnlock()
 	return rs.ckvStores[key]
 }
 
@@ -334,9 +1010,19 @@ func (rs *Store) LoadLatestVersionAndUpgrade(upgrades *types.StoreUpgrades) erro
 // Implements interface CommitMultiStore
 // used by node startup with UpgradeStoreLoader
 func (rs *Store) LoadVersionAndUpgrade(version int64, upgrades *types.StoreUpgrades) error {
+	return rs.LoadVersionAndUpgradeContext(context.Background(), version, upgrades)
+}
+
+// LoadVersionAndUpgradeContext loads the stores like LoadVersionAndUpgrade, the load is aborted with the error
+// of the context once it's done, it's checked before initializing the sc store, before applying the upgrades
+// and between the loads of the stores, the stores are not loaded then.
+func (rs *Store) LoadVersionAndUpgradeContext(ctx context.Context, version int64, upgrades *types.StoreUpgrades) error {
 	if version > math.MaxUint32 {
 		return fmt.Errorf("version overflows uint32: %d", version)
 	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 
 	storesKeys := make([]types.StoreKey, 0, len(rs.storesParams))
 	for key := range rs.storesParams {
@@ -368,6 +1054,12 @@ func (rs *Store) LoadVersionAndUpgrade(version int64, upgrades *types.StoreUpgra
 	}
 
 	if len(treeUpgrades) > 0 {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := validateTreeUpgrades(rs.scStore.WorkingCommitInfo(), treeUpgrades); err != nil {
+			return err
+		}
 		if err := rs.scStore.ApplyUpgrades(treeUpgrades); err != nil {
 			return err
 		}
@@ -375,6 +1067,9 @@ func (rs *Store) LoadVersionAndUpgrade(version int64, upgrades *types.StoreUpgra
 	var err error
 	newStores := make(map[types.StoreKey]types.CommitKVStore, len(storesKeys))
 	for _, key := range storesKeys {
+		if err = ctx.Err(); err != nil {
+			return err
+		}
 		newStores[key], err = rs.loadCommitStoreFromParams(key, rs.storesParams[key])
 		if err != nil {
 			return err
@@ -384,16 +1079,132 @@ func (rs *Store) LoadVersionAndUpgrade(version int64, upgrades *types.StoreUpgra
 	rs.mtx.Lock()
 	defer rs.mtx.Unlock()
 	rs.ckvStores = newStores
+	rs.workingHash = nil
+	rs.loaded = true
 	// to keep the root hash compatible with cosmos-sdk 0.46
 	if rs.scStore.Version() != 0 {
 		rs.lastCommitInfo = convertCommitInfo(rs.scStore.LastCommitInfo())
-		rs.lastCommitInfo = amendCommitInfo(rs.lastCommitInfo, rs.storesParams)
+		rs.lastCommitInfo = amendCommitInfo(rs.lastCommitInfo, rs.extraStoreInfos)
 	} else {
 		rs.lastCommitInfo = &types.CommitInfo{}
 	}
+	return rs.checkSSDivergence()
+}
+
+// checkSSDivergence records the versions of the sc and SS stores once loaded, and warns if they differ, e.g. when the
+// async SS writes are lost on a crash and the changelog can't recover them, the historical queries are stale then.
+func (rs *Store) checkSSDivergence() error {
+	rs.loadSCVersion = rs.scStore.Version()
+	if rs.ssStore == nil {
+		return nil
+	}
+	ssVersion, err := rs.ssStore.GetLatestVersion()
+	if err != nil {
+		return err
+	}
+	rs.loadSSVersion = ssVersion
+	if ssVersion != rs.loadSCVersion {
+		rs.logger.Error(
+			"state store version diverges from the sc store, the queries served by the state store may be stale, "+
+				"re-apply the missing versions from the sc changelog or restore the state store from a snapshot",
+			"ss-version", ssVersion, "sc-version", rs.loadSCVersion,
+		)
+	}
 	return nil
 }
 
+// Health reports the versions of the sc and SS stores.
+type Health struct {
+	// SCVersion and SSVersion are the latest versions of the sc and SS stores, SSVersion is 0 if SS is disabled.
+	SCVersion int64
+	SSVersion int64
+	// LoadSCVersion and LoadSSVersion are the versions of the stores when they were loaded.
+	LoadSCVersion int64
+	LoadSSVersion int64
+	// Diverged is set if the versions of the stores differed when they were loaded.
+	Diverged bool
+}
+
+// Health returns the versions of the sc and SS stores, operators can tell from it whether SS is behind after a crash.
+func (rs *Store) Health() (Health, error) {
+	health := Health{
+		SCVersion:     rs.scStore.Version(),
+		LoadSCVersion: rs.loadSCVersion,
+	}
+	if rs.ssStore != nil {
+		ssVersion, err := rs.ssStore.GetLatestVersion()
+		if err != nil {
+			return Health{}, err
+		}
+		health.SSVersion = ssVersion
+		health.LoadSSVersion = rs.loadSSVersion
+		health.Diverged = rs.loadSSVersion != rs.loadSCVersion
+	}
+	return health, nil
+}
+
+// StateStoreLag returns the latest version of the sc store and the latest version applied to the SS store,
+// the SS store trails the sc store by the changes pending in the buffer. It returns ErrStateStoreDisabled along
+// with the sc version if SS is disabled.
+func (rs *Store) StateStoreLag() (scVersion int64, ssVersion int64, err error) {
+	scVersion = rs.scStore.Version()
+	if rs.ssStore == nil {
+		return scVersion, 0, ErrStateStoreDisabled
+	}
+	ssVersion, err = rs.ssStore.GetLatestVersion()
+	if err != nil {
+		return 0, 0, err
+	}
+	return scVersion, ssVersion, nil
+}
+
+// validateTreeUpgrades checks the upgrades against the trees of the sc store, in the order they're applied,
+// so an upgrade of a missing tree is reported upfront naming the upgrade rather than failing in the sc store.
+func validateTreeUpgrades(commitInfo *proto.CommitInfo, upgrades []*proto.TreeNameUpgrade) error {
+	trees := make(map[string]bool, len(commitInfo.StoreInfos))
+	for _, info := range commitInfo.StoreInfos {
+		trees[info.Name] = true
+	}
+	for _, upgrade := range upgrades {
+		switch {
+		case upgrade.Delete:
+			if !trees[upgrade.Name] {
+				return fmt.Errorf("invalid store upgrade, cannot delete store %s which doesn't exist", upgrade.Name)
+			}
+			delete(trees, upgrade.Name)
+		case upgrade.RenameFrom != "":
+			if !trees[upgrade.RenameFrom] {
+				return fmt.Errorf("invalid store upgrade, cannot rename store %s to %s, %s doesn't exist", upgrade.RenameFrom, upgrade.Name, upgrade.RenameFrom)
+			}
+			if trees[upgrade.Name] {
+				return fmt.Errorf("invalid store upgrade, cannot rename store %s to %s, %s already exists", upgrade.RenameFrom, upgrade.Name, upgrade.Name)
+			}
+			delete(trees, upgrade.RenameFrom)
+			trees[upgrade.Name] = true
+		default:
+			if trees[upgrade.Name] {
+				return fmt.Errorf("invalid store upgrade, cannot add store %s which already exists", upgrade.Name)
+			}
+			trees[upgrade.Name] = true
+		}
+	}
+	return nil
+}
+
+// reloadStore reloads the store of the key if its sc tree has been replaced, e.g. when the sc store is reloaded
+// from a new snapshot, the store keeps its handle otherwise. Returns whether the store is reloaded.
+func (rs *Store) reloadStore(key types.StoreKey) (bool, error) {
+	if store, ok := rs.ckvStores[key].(*commitment.Store); ok && store.Tree() == rs.scStore.GetTreeByName(key.Name()) {
+		return false, nil
+	}
+	store, err := rs.loadCommitStoreFromParams(key, rs.storesParams[key])
+	if err != nil {
+		return false, err
+	}
+	rs.ckvStores[key] = store
+	return true, nil
+}
+
 func (rs *Store) loadCommitStoreFromParams(key types.StoreKey, params storeParams) (types.CommitKVStore, error) {
 	switch params.typ {
 	case types.StoreTypeMulti:
@@ -435,7 +1246,11 @@ func (rs *Store) SetInterBlockCache(_ types.MultiStorePersistentCache) {}
 // SetInitialVersion Implements interface CommitMultiStore
 // used by InitChain when the initial height is bigger than 1
 func (rs *Store) SetInitialVersion(version int64) error {
-	return rs.scStore.SetInitialVersion(version)
+	if err := rs.scStore.SetInitialVersion(version); err != nil {
+		return err
+	}
+	rs.initialVersion = version
+	return nil
 }
 
 // Implements interface CommitMultiStore
@@ -460,84 +1275,190 @@ func (rs *Store) RollbackToVersion(target int64) error {
 	if target > math.MaxUint32 {
 		return fmt.Errorf("rollback height target %d exceeds max uint32", target)
 	}
+	earliest, latest, err := rs.provableRange()
+	if err != nil {
+		return err
+	}
+	if target < earliest {
+		return fmt.Errorf("rollback height target %d below earliest retained version %d", target, earliest)
+	}
+	if target > latest {
+		return fmt.Errorf("rollback height target %d above latest version %d", target, latest)
+	}
+	if rs.historicalStores != nil {
+		rs.historicalStores.purge()
+	}
+	rs.workingHash = nil
+	// the versions after the target are committed again
+	rs.ssMtx.Lock()
+	if rs.ssAppliedVersion > target {
+		rs.ssAppliedVersion = target
+	}
+	rs.ssMtx.Unlock()
 	return rs.scStore.Rollback(target)
 }
 
-// getStoreByName performs a lookup of a StoreKey given a store name typically
-// provided in a path. The StoreKey is then used to perform a lookup and return
-// a Store. If the Store is wrapped in an inter-block cache, it will be unwrapped
-// prior to being returned. If the StoreKey does not exist, nil is returned.
-func (rs *Store) GetStoreByName(name string) types.Store {
-	key := rs.storeKeys[name]
-	if key == nil {
-		return nil
+// earliestVersionGetter is implemented by the SS backends which track the earliest version retained after pruning.
+type earliestVersionGetter interface {
+	GetEarliestVersion() int64
+}
+
+// ssEarliestVersion returns the earliest version retained by the SS store.
+func (rs *Store) ssEarliestVersion() int64 {
+	earliest := int64(1)
+	if getter, ok := rs.ssStore.(earliestVersionGetter); ok && getter.GetEarliestVersion() > earliest {
+		earliest = getter.GetEarliestVersion()
 	}
+	return earliest
+}
 
-	return rs.GetCommitKVStore(key)
+// UpdatePruningConfig changes the keep-recent and prune-interval configs of the state store pruning
+// without restart, the versions served by the in-flight historical queries are not pruned until they complete.
+func (rs *Store) UpdatePruningConfig(keepRecent, intervalSeconds int64) error {
+	if rs.readOnly {
+		return ErrReadOnly
+	}
+	if rs.pruningManager == nil {
+		return ErrStateStoreDisabled
+	}
+	return rs.pruningManager.UpdateConfig(keepRecent, intervalSeconds)
 }
 
-// Implements interface Queryable
-func (rs *Store) Query(req abci.RequestQuery) abci.ResponseQuery {
-	version := req.Height
-	if version <= 0 {
-		version = rs.scStore.Version()
+// PruneStateStore prunes the SS store up to and including the target version synchronously, regardless of the
+// pruning configs.
+// The versions pinned by the in-flight readers are still protected.
+func (rs *Store) PruneStateStore(target int64) error {
+	if rs.readOnly {
+		return ErrReadOnly
+	}
+	if rs.pruningManager == nil {
+		return ErrStateStoreDisabled
+	}
+	if target <= 0 {
+		return fmt.Errorf("invalid prune target version: %d", target)
 	}
-	path := req.Path
-	storeName, subPath, err := parsePath(path)
+	latest, err := rs.ssStore.GetLatestVersion()
 	if err != nil {
-		return sdkerrors.QueryResult(err)
+		return err
 	}
-	var store types.Queryable
-
-	if !req.Prove && version < rs.lastCommitInfo.Version && rs.ssStore != nil {
-		// Serve abci query from ss store if no proofs needed
-		store = types.Queryable(state.NewStore(rs.ssStore, types.NewKVStoreKey(storeName), version))
-	} else if version < rs.lastCommitInfo.Version {
-		// Serve abci query from historical sc store if proofs needed
-		scStore, err := rs.scStore.LoadVersion(version, true)
-		defer scStore.Close()
-		if err != nil {
-			return sdkerrors.QueryResult(err)
-		}
-		store = types.Queryable(commitment.NewStore(scStore.GetTreeByName(storeName), rs.logger))
-	} else {
-		// Serve directly from latest sc store
-		store = types.Queryable(commitment.NewStore(rs.scStore.GetTreeByName(storeName), rs.logger))
+	if target >= latest {
+		return fmt.Errorf("prune target version %d must be below the latest version %d", target, latest)
 	}
+	start := time.Now()
+	prunedVersion, err := rs.pruningManager.PruneUpTo(target)
+	if err != nil {
+		return err
+	}
+	rs.logger.Info("pruned state store", "target", target, "pruned-version", prunedVersion, "took", time.Since(start))
+	return nil
+}
 
-	// trim the path and execute the query
-	req.Path = subPath
-	res := store.Query(req)
+// versionRangeSizer is implemented by the SS backends which can estimate the bytes used by a range of versions.
+type versionRangeSizer interface {
+	SizeByVersionRange(from, to int64) (int64, error)
+}
 
-	if !req.Prove || !rootmulti.RequireProof(subPath) {
-		return res
+// SSSizeByVersionRange estimates the bytes used by the SS store for the versions in [from, to],
+// it returns ErrSSSizeUnsupported if the backend can't attribute its size to versions.
+func (rs *Store) SSSizeByVersionRange(from, to int64) (int64, error) {
+	if rs.ssStore == nil {
+		return 0, ErrStateStoreDisabled
+	}
+	if from <= 0 || from > to {
+		return 0, fmt.Errorf("invalid version range [%d, %d]", from, to)
+	}
+	sizer, ok := rs.ssStore.(versionRangeSizer)
+	if !ok {
+		return 0, ErrSSSizeUnsupported
+	}
+	return sizer.SizeByVersionRange(from, to)
+}
+
+// StoreKeyCounts returns the number of live keys of each iavl store, counted at the latest version of the SS store,
+// or of the sc store if SS is disabled. The SS store is written asynchronously, so its counts may lag the latest
+// commit by a few versions. The counts are exact, they're computed by iterating all the keys so it's expensive.
+func (rs *Store) StoreKeyCounts() (map[string]int64, error) {
+	var latest int64
+	if rs.ssStore != nil {
+		var err error
+		if latest, err = rs.ssStore.GetLatestVersion(); err != nil {
+			return nil, err
+		}
 	}
-	if res.ProofOps == nil || len(res.ProofOps.Ops) == 0 {
-		return sdkerrors.QueryResult(errors.Wrap(sdkerrors.ErrInvalidRequest, "proof is unexpectedly empty; ensure height has not been pruned"))
+	counts := make(map[string]int64, len(rs.storeKeys))
+	for name, key := range rs.storeKeys {
+		if rs.storesParams[key].typ != types.StoreTypeIAVL {
+			continue
+		}
+		var iter types.Iterator
+		if rs.ssStore != nil {
+			var err error
+			if iter, err = rs.ssStore.Iterator(name, latest, nil, nil); err != nil {
+				return nil, err
+			}
+		} else {
+			iter = rs.scStore.GetTreeByName(name).Iterator(nil, nil, true)
+		}
+		var count int64
+		for ; iter.Valid(); iter.Next() {
+			count++
+		}
+		if err := iter.Close(); err != nil {
+			return nil, err
+		}
+		counts[name] = count
 	}
-	commitInfo := convertCommitInfo(rs.scStore.LastCommitInfo())
-	commitInfo = amendCommitInfo(commitInfo, rs.storesParams)
-	// Restore origin path and append proof op.
-	res.ProofOps.Ops = append(res.ProofOps.Ops, commitInfo.ProofOp(storeName))
-	return res
+	return counts, nil
 }
 
-// parsePath expects a format like /<storeName>[/<subpath>]
-// Must start with /, subpath may be empty
-// Returns error if it doesn't start with /
-func parsePath(path string) (storeName string, subpath string, err error) {
-	if !strings.HasPrefix(path, "/") {
-		return storeName, subpath, errors.Wrapf(sdkerrors.ErrUnknownRequest, "invalid path: %s", path)
+// IsPersistent returns whether the store is persisted with history, only the iavl stores are,
+// the transient and memory stores are ephemeral and have nothing to query historically or to snapshot.
+func (rs *Store) IsPersistent(storeName string) (bool, error) {
+	rs.mtx.RLock()
+	defer rs.mtx.RUnlock()
+	storeKey, ok := rs.storeKeys[storeName]
+	if !ok {
+		return false, fmt.Errorf("store not found: %s", storeName)
+	}
+	return rs.storesParams[storeKey].typ == types.StoreTypeIAVL, nil
+}
+
+// StoreType returns the type of the store mounted with the name, false if no store is mounted with it.
+func (rs *Store) StoreType(name string) (types.StoreType, bool) {
+	rs.mtx.RLock()
+	defer rs.mtx.RUnlock()
+	key, ok := rs.storeKeys[name]
+	if !ok {
+		return 0, false
 	}
+	return rs.storesParams[key].typ, true
+}
 
-	paths := strings.SplitN(path[1:], "/", 2)
-	storeName = paths[0]
+// MountedStoreNames returns the sorted names of the mounted stores, whatever their type.
+func (rs *Store) MountedStoreNames() []string {
+	rs.mtx.RLock()
+	defer rs.mtx.RUnlock()
+	names := make([]string, 0, len(rs.storeKeys))
+	for name := range rs.storeKeys {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
 
-	if len(paths) == 2 {
-		subpath = "/" + paths[1]
+// GetStoreByName performs a lookup of a StoreKey given a store name typically
+// provided in a path. The StoreKey is then used to perform a lookup and return
+// a Store. If the Store is wrapped in an inter-block cache, it will be unwrapped
+// prior to being returned. If the StoreKey does not exist, nil is returned.
+func (rs *Store) GetStoreByName(name string) types.Store {
+	rs.mtx.RLock()
+	defer rs.mtx.RUnlock()
+	key := rs.storeKeys[name]
+	if key == nil {
+		return nil
 	}
 
-	return storeName, subpath, nil
+	return rs.ckvStores[key]
 }
 
 type storeParams struct {
@@ -552,12 +1473,20 @@ func newStoreParams(key types.StoreKey, typ types.StoreType) storeParams {
 	}
 }
 
+// mergeStoreInfos merges the store infos and sort them by name, store infos sharing the same name
+// (e.g. during a rename upgrade) are ordered by version then hash, so the result never depends on the input order.
 func mergeStoreInfos(commitInfo *types.CommitInfo, storeInfos []types.StoreInfo) *types.CommitInfo {
 	infos := make([]types.StoreInfo, 0, len(commitInfo.StoreInfos)+len(storeInfos))
 	infos = append(infos, commitInfo.StoreInfos...)
 	infos = append(infos, storeInfos...)
 	sort.SliceStable(infos, func(i, j int) bool {
-		return infos[i].Name < infos[j].Name
+		if infos[i].Name != infos[j].Name {
+			return infos[i].Name < infos[j].Name
+		}
+		if infos[i].CommitId.Version != infos[j].CommitId.Version {
+			return infos[i].CommitId.Version < infos[j].CommitId.Version
+		}
+		return bytes.Compare(infos[i].CommitId.Hash, infos[j].CommitId.Hash) < 0
 	})
 	return &types.CommitInfo{
 		Version:    commitInfo.Version,
@@ -565,18 +1494,20 @@ func mergeStoreInfos(commitInfo *types.CommitInfo, storeInfos []types.StoreInfo)
 	}
 }
 
-// amendCommitInfo add mem stores commit infos to keep it compatible with cosmos-sdk 0.46
-func amendCommitInfo(commitInfo *types.CommitInfo, storeParams map[types.StoreKey]storeParams) *types.CommitInfo {
-	var extraStoreInfos []types.StoreInfo
-	for key := range storeParams {
-		typ := storeParams[key].typ
-		if typ != types.StoreTypeIAVL && typ != types.StoreTypeTransient {
-			extraStoreInfos = append(extraStoreInfos, types.StoreInfo{
-				Name:     key.Name(),
-				CommitId: types.CommitID{},
-			})
-		}
+// isAmendedStoreType returns whether the stores of the type are amended to the commit info with an empty commit id,
+// the IAVL stores are committed by the sc store and the transient stores are excluded like in cosmos-sdk 0.46,
+// the other types can't be loaded and must not affect the commit info.
+func isAmendedStoreType(typ types.StoreType) bool {
+	switch typ {
+	case types.StoreTypeMemory:
+		return true
+	default:
+		return false
 	}
+}
+
+// amendCommitInfo add mem stores commit infos to keep it compatible with cosmos-sdk 0.46
+func amendCommitInfo(commitInfo *types.CommitInfo, extraStoreInfos []types.StoreInfo) *types.CommitInfo {
 	return mergeStoreInfos(commitInfo, extraStoreInfos)
 }
 
@@ -597,15 +1528,22 @@ func convertCommitInfo(commitInfo *proto.CommitInfo) *types.CommitInfo {
 	}
 }
 
-// GetWorkingHash returns the working app hash
+// GetWorkingHash returns the working app hash, it fails if a mounted IAVL store has no tree in the sc store.
 func (rs *Store) GetWorkingHash() ([]byte, error) {
 	if err := rs.flush(); err != nil {
 		return nil, err
 	}
+	if rs.cacheWorkingHash && rs.workingHash != nil {
+		return rs.workingHash, nil
+	}
 	commitInfo := convertCommitInfo(rs.scStore.WorkingCommitInfo())
 	// for sdk 0.46 and backward compatibility
-	commitInfo = amendCommitInfo(commitInfo, rs.storesParams)
-	return commitInfo.Hash(), nil
+	commitInfo = amendCommitInfo(commitInfo, rs.extraStoreInfos)
+	hash := commitInfo.Hash()
+	if rs.cacheWorkingHash {
+		rs.workingHash = hash
+	}
+	return hash, nil
 }
 
 func (rs *Store) GetEvents() []abci.Event {
@@ -625,158 +1563,3 @@ func (rs *Store) ListeningEnabled(_ types.StoreKey) bool {
 func (rs *Store) AddListeners(_ types.StoreKey, _ []types.WriteListener) {
 	return
 }
-
-// Restore Implements interface Snapshotter
-func (rs *Store) Restore(
-	height uint64, format uint32, protoReader protoio.Reader,
-) (snapshottypes.SnapshotItem, error) {
-	if rs.scStore != nil {
-		if err := rs.scStore.Close(); err != nil {
-			return snapshottypes.SnapshotItem{}, fmt.Errorf("failed to close db: %w", err)
-		}
-	}
-	item, err := rs.restore(int64(height), protoReader)
-	if err != nil {
-		return snapshottypes.SnapshotItem{}, err
-	}
-
-	return item, rs.LoadLatestVersion()
-}
-
-func (rs *Store) restore(height int64, protoReader protoio.Reader) (snapshottypes.SnapshotItem, error) {
-	var (
-		ssImporter   chan sstypes.SnapshotNode
-		snapshotItem snapshottypes.SnapshotItem
-		storeKey     string
-		restoreErr   error
-	)
-	scImporter, err := rs.scStore.Importer(height)
-	if err != nil {
-		return snapshottypes.SnapshotItem{}, err
-	}
-	if rs.ssStore != nil {
-		ssImporter = make(chan sstypes.SnapshotNode, 10000)
-		go func() {
-			err := rs.ssStore.Import(height, ssImporter)
-			if err != nil {
-				panic(err)
-			}
-		}()
-	}
-loop:
-	for {
-		snapshotItem = snapshottypes.SnapshotItem{}
-		err = protoReader.ReadMsg(&snapshotItem)
-		if err == io.EOF {
-			break
-		} else if err != nil {
-			restoreErr = errors.Wrap(err, "invalid protobuf message")
-			break loop
-		}
-
-		switch item := snapshotItem.Item.(type) {
-		case *snapshottypes.SnapshotItem_Store:
-			storeKey = item.Store.Name
-			if err = scImporter.AddTree(storeKey); err != nil {
-				restoreErr = err
-				break loop
-			}
-		case *snapshottypes.SnapshotItem_IAVL:
-			if item.IAVL.Height > math.MaxInt8 {
-				restoreErr = errors.Wrapf(sdkerrors.ErrLogic, "node height %v cannot exceed %v",
-					item.IAVL.Height, math.MaxInt8)
-				break loop
-			}
-			node := &sctypes.SnapshotNode{
-				Key:     item.IAVL.Key,
-				Value:   item.IAVL.Value,
-				Height:  int8(item.IAVL.Height),
-				Version: item.IAVL.Version,
-			}
-			// Protobuf does not differentiate between []byte{} as nil, but fortunately IAVL does
-			// not allow nil keys nor nil values for leaf nodes, so we can always set them to empty.
-			if node.Key == nil {
-				node.Key = []byte{}
-			}
-			if node.Height == 0 && node.Value == nil {
-				node.Value = []byte{}
-			}
-			scImporter.AddNode(node)
-
-			// Check if we should also import to SS store
-			if rs.ssStore != nil && node.Height == 0 && ssImporter != nil {
-				ssImporter <- sstypes.SnapshotNode{
-					StoreKey: storeKey,
-					Key:      node.Key,
-					Value:    node.Value,
-				}
-			}
-		default:
-			// unknown element, could be an extension
-			break loop
-		}
-	}
-
-	if err = scImporter.Close(); err != nil {
-		if restoreErr == nil {
-			restoreErr = err
-		}
-	}
-	if ssImporter != nil {
-		close(ssImporter)
-	}
-
-	return snapshotItem, restoreErr
-}
-
-// Snapshot Implements the interface from Snapshotter
-func (rs *Store) Snapshot(height uint64, protoWriter protoio.Writer) error {
-	if height > math.MaxUint32 {
-		return fmt.Errorf("height overflows uint32: %d", height)
-	}
-
-	exporter, err := rs.scStore.Exporter(int64(height))
-	if err != nil {
-		return err
-	}
-	defer exporter.Close()
-	for {
-		item, err := exporter.Next()
-		if err != nil {
-			if err == commonerrors.ErrorExportDone {
-				break
-			}
-			return err
-		}
-
-		switch item := item.(type) {
-		case *sctypes.SnapshotNode:
-			if err := protoWriter.WriteMsg(&snapshottypes.SnapshotItem{
-				Item: &snapshottypes.SnapshotItem_IAVL{
-					IAVL: &snapshottypes.SnapshotIAVLItem{
-						Key:     item.Key,
-						Value:   item.Value,
-						Height:  int32(item.Height),
-						Version: item.Version,
-					},
-				},
-			}); err != nil {
-				return err
-			}
-		case string:
-			if err := protoWriter.WriteMsg(&snapshottypes.SnapshotItem{
-				Item: &snapshottypes.SnapshotItem_Store{
-					Store: &snapshottypes.SnapshotStoreItem{
-						Name: item,
-					},
-				},
-			}); err != nil {
-				return err
-			}
-		default:
-			return fmt.Errorf("unknown item type %T", item)
-		}
-	}
-
-	return nil
-}
@@ -1,15 +1,1635 @@
 package rootmulti
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
+	"github.com/cosmos/cosmos-sdk/store/rootmulti"
 	"github.com/cosmos/cosmos-sdk/store/types"
+	"github.com/cosmos/cosmos-sdk/storev2/commitment"
+	sdkerrors "github.com/cosmos/cosmos-sdk/types/errors"
+	"github.com/cosmos/iavl"
 	"github.com/sei-protocol/sei-db/config"
+	"github.com/sei-protocol/sei-db/proto"
+	sctypes "github.com/sei-protocol/sei-db/sc/types"
+	sstypes "github.com/sei-protocol/sei-db/ss/types"
 	"github.com/stretchr/testify/require"
+	abci "github.com/tendermint/tendermint/abci/types"
 	"github.com/tendermint/tendermint/libs/log"
+	dbm "github.com/tendermint/tm-db"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 )
 
 func TestLastCommitID(t *testing.T) {
 	store := NewStore(t.TempDir(), log.NewNopLogger(), config.StateCommitConfig{}, config.StateStoreConfig{})
 	require.Equal(t, types.CommitID{}, store.LastCommitID())
 }
+
+func TestLatestVersion(t *testing.T) {
+	key := types.NewKVStoreKey("bank")
+	store := NewStore(t.TempDir(), log.NewNopLogger(), config.StateCommitConfig{}, config.StateStoreConfig{})
+	// the version is read from disk before the stores are loaded
+	require.Zero(t, store.LatestVersion())
+	store.MountStoreWithDB(key, types.StoreTypeIAVL, nil)
+	require.NoError(t, store.LoadLatestVersion())
+	defer store.Close()
+
+	backwards := make(chan bool, 1)
+	go func() {
+		// the version is read concurrently with the commits, it never goes backwards
+		var last int64
+		for last < 5 {
+			version := store.LatestVersion()
+			if version < last {
+				backwards <- true
+				return
+			}
+			last = version
+		}
+		backwards <- false
+	}()
+	for i := 1; i <= 5; i++ {
+		store.GetKVStore(key).Set([]byte("a"), []byte{byte(i)})
+		store.Commit(true)
+		require.Equal(t, store.LastCommitID().Version, store.LatestVersion())
+	}
+	require.False(t, <-backwards)
+}
+
+// newTestStore creates a loaded store with the given IAVL stores mounted.
+func newTestStore(t *testing.T, ssEnabled bool, keys ...types.StoreKey) *Store {
+	ssConfig := config.DefaultStateStoreConfig()
+	ssConfig.Enable = ssEnabled
+	store := NewStore(t.TempDir(), log.NewNopLogger(), config.StateCommitConfig{}, ssConfig)
+	for _, key := range keys {
+		store.MountStoreWithDB(key, types.StoreTypeIAVL, nil)
+	}
+	require.NoError(t, store.LoadLatestVersion())
+	t.Cleanup(func() {
+		if store.ssStore != nil {
+			// SS must not be closed while async commits are still applied
+			waitForSS(t, store, store.lastCommitInfo.Version)
+		}
+		require.NoError(t, store.Close())
+	})
+	return store
+}
+
+// waitForSS waits until the async SS commits reach the version.
+func waitForSS(t *testing.T, store *Store, version int64) {
+	require.NoError(t, store.WaitForSSApplied(version, 5*time.Second))
+}
+
+func TestLastVersionWithKey(t *testing.T) {
+	key := types.NewKVStoreKey("bank")
+	store := newTestStore(t, true, key)
+
+	kvStore := store.GetKVStore(key)
+	kvStore.Set([]byte("a"), []byte("1"))
+	kvStore.Set([]byte("b"), []byte("1"))
+	store.Commit(true)
+	kvStore = store.GetKVStore(key)
+	kvStore.Set([]byte("a"), []byte("2"))
+	store.Commit(true)
+	kvStore = store.GetKVStore(key)
+	kvStore.Delete([]byte("a"))
+	store.Commit(true)
+	kvStore = store.GetKVStore(key)
+	kvStore.Set([]byte("c"), []byte("1"))
+	store.Commit(true)
+	waitForSS(t, store, 4)
+
+	version, found, err := store.LastVersionWithKey("bank", []byte("a"))
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, int64(2), version)
+
+	version, found, err = store.LastVersionWithKey("bank", []byte("b"))
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, int64(4), version)
+
+	_, found, err = store.LastVersionWithKey("bank", []byte("d"))
+	require.NoError(t, err)
+	require.False(t, found)
+
+	// the search stops at the limit while older versions are retained
+	store.queries.versionSearchLimit = 2
+	_, _, err = store.LastVersionWithKey("bank", []byte("a"))
+	require.ErrorIs(t, err, ErrVersionSearchLimit)
+	version, found, err = store.LastVersionWithKey("bank", []byte("c"))
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, int64(4), version)
+	require.Panics(t, func() { WithVersionSearchLimit(0)(store) })
+
+	_, _, err = newTestStore(t, false, key).LastVersionWithKey("bank", []byte("a"))
+	require.ErrorIs(t, err, ErrStateStoreDisabled)
+}
+
+func TestMergeStoreInfosDuplicateNames(t *testing.T) {
+	infos := []types.StoreInfo{
+		{Name: "bank", CommitId: types.CommitID{Version: 2, Hash: []byte{2}}},
+		{Name: "acc", CommitId: types.CommitID{Version: 2, Hash: []byte{1}}},
+		{Name: "bank", CommitId: types.CommitID{Version: 2, Hash: []byte{1}}},
+		{Name: "bank", CommitId: types.CommitID{Version: 1, Hash: []byte{3}}},
+		{Name: "mem", CommitId: types.CommitID{}},
+	}
+	expected := []types.StoreInfo{infos[1], infos[3], infos[2], infos[0], infos[4]}
+
+	// every input order produces the same commit info and hash
+	for i := range infos {
+		rotated := append(append([]types.StoreInfo{}, infos[i:]...), infos[:i]...)
+		commitInfo := mergeStoreInfos(&types.CommitInfo{Version: 2, StoreInfos: rotated[:2]}, rotated[2:])
+		require.Equal(t, expected, commitInfo.StoreInfos)
+		require.Equal(t, (&types.CommitInfo{Version: 2, StoreInfos: expected}).Hash(), commitInfo.Hash())
+	}
+}
+
+func TestQueryRouteFor(t *testing.T) {
+	key := types.NewKVStoreKey("bank")
+	for _, ssEnabled := range []bool{true, false} {
+		store := newTestStore(t, ssEnabled, key)
+		for i := 0; i < 3; i++ {
+			store.GetKVStore(key).Set([]byte("a"), []byte{byte(i)})
+			store.Commit(true)
+		}
+
+		require.Equal(t, QueryRouteLatestSC, store.QueryRouteFor(0, false))
+		require.Equal(t, QueryRouteLatestSC, store.QueryRouteFor(3, false))
+		require.Equal(t, QueryRouteLatestSC, store.QueryRouteFor(3, true))
+		require.Equal(t, QueryRouteHistoricalSC, store.QueryRouteFor(2, true))
+		if ssEnabled {
+			require.Equal(t, QueryRouteSS, store.QueryRouteFor(2, false))
+		} else {
+			require.Equal(t, QueryRouteHistoricalSC, store.QueryRouteFor(2, false))
+		}
+	}
+}
+
+func TestPauseResumeSS(t *testing.T) {
+	key := types.NewKVStoreKey("bank")
+	store := newTestStore(t, true, key)
+
+	store.PauseSS()
+	for i := 1; i <= 3; i++ {
+		store.GetKVStore(key).Set([]byte("a"), []byte{byte(i)})
+		store.Commit(true)
+	}
+	time.Sleep(50 * time.Millisecond)
+	latest, err := store.ssStore.GetLatestVersion()
+	require.NoError(t, err)
+	require.Zero(t, latest)
+
+	store.ResumeSS()
+	waitForSS(t, store, 3)
+	for i := int64(1); i <= 3; i++ {
+		value, err := store.ssStore.Get("bank", i, []byte("a"))
+		require.NoError(t, err)
+		require.Equal(t, []byte{byte(i)}, value)
+	}
+}
+
+func TestStateStoreLag(t *testing.T) {
+	key := types.NewKVStoreKey("bank")
+	store := newTestStore(t, true, key)
+	store.GetKVStore(key).Set([]byte("a"), []byte("1"))
+	store.Commit(true)
+	waitForSS(t, store, 1)
+
+	store.PauseSS()
+	for i := 2; i <= 4; i++ {
+		store.GetKVStore(key).Set([]byte("a"), []byte{byte(i)})
+		store.Commit(true)
+	}
+	// the paused SS commit routine may hold the first pending version without applying it
+	scVersion, ssVersion, err := store.StateStoreLag()
+	require.NoError(t, err)
+	require.Equal(t, int64(4), scVersion)
+	require.Equal(t, int64(1), ssVersion)
+
+	store.ResumeSS()
+	waitForSS(t, store, 4)
+	scVersion, ssVersion, err = store.StateStoreLag()
+	require.NoError(t, err)
+	require.Equal(t, scVersion, ssVersion)
+
+	store = newTestStore(t, false, key)
+	store.Commit(true)
+	scVersion, ssVersion, err = store.StateStoreLag()
+	require.ErrorIs(t, err, ErrStateStoreDisabled)
+	require.Equal(t, int64(1), scVersion)
+	require.Zero(t, ssVersion)
+}
+
+func TestLastFlushStats(t *testing.T) {
+	bank, acc, staking := types.NewKVStoreKey("bank"), types.NewKVStoreKey("acc"), types.NewKVStoreKey("staking")
+	store := newTestStore(t, false, bank, acc, staking)
+	require.Empty(t, store.LastFlushStats())
+
+	store.GetKVStore(bank).Set([]byte("a"), []byte("1"))
+	store.GetKVStore(bank).Set([]byte("b"), []byte("1"))
+	store.GetKVStore(acc).Set([]byte("a"), []byte("1"))
+	store.Commit(true)
+	require.Equal(t, map[string]int{"bank": 2, "acc": 1}, store.LastFlushStats())
+
+	// the deletes are flushed too, and each write of the commit store is flushed, including the overwrites
+	store.GetKVStore(bank).Delete([]byte("a"))
+	store.GetKVStore(staking).Set([]byte("a"), []byte("1"))
+	store.GetKVStore(staking).Set([]byte("a"), []byte("2"))
+	store.Commit(true)
+	require.Equal(t, map[string]int{"bank": 1, "staking": 2}, store.LastFlushStats())
+
+	store.Commit(true)
+	require.Empty(t, store.LastFlushStats())
+}
+
+func TestPendingChangesByStore(t *testing.T) {
+	bank, acc := types.NewKVStoreKey("bank"), types.NewKVStoreKey("acc")
+	store := newTestStore(t, true, bank, acc)
+	require.Empty(t, store.PendingChangesByStore())
+
+	store.PauseSS()
+	store.GetKVStore(bank).Set([]byte("a"), []byte("1"))
+	store.GetKVStore(acc).Set([]byte("a"), []byte("1"))
+	store.Commit(true)
+	store.GetKVStore(bank).Set([]byte("a"), []byte("2"))
+	store.Commit(true)
+	require.Equal(t, map[string]int{"bank": 2, "acc": 1}, store.PendingChangesByStore())
+
+	store.ResumeSS()
+	waitForSS(t, store, 2)
+	require.Eventually(t, func() bool {
+		return len(store.PendingChangesByStore()) == 0
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestWaitForSSApplied(t *testing.T) {
+	key := types.NewKVStoreKey("bank")
+	store := newTestStore(t, true, key)
+	require.NoError(t, store.WaitForSSApplied(0, time.Second))
+	require.Error(t, store.WaitForSSApplied(1, time.Second))
+
+	store.PauseSS()
+	store.GetKVStore(key).Set([]byte("a"), []byte("1"))
+	store.Commit(true)
+	require.Error(t, store.WaitForSSApplied(1, 50*time.Millisecond))
+
+	done := make(chan error)
+	go func() {
+		done <- store.WaitForSSApplied(1, 5*time.Second)
+	}()
+	store.ResumeSS()
+	require.NoError(t, <-done)
+	value, err := store.ssStore.Get("bank", 1, []byte("a"))
+	require.NoError(t, err)
+	require.Equal(t, []byte("1"), value)
+
+	// the versions without changes are applied once the earlier ones are
+	store.GetKVStore(key).Set([]byte("a"), []byte("2"))
+	store.Commit(true)
+	store.Commit(true)
+	require.NoError(t, store.WaitForSSApplied(3, 5*time.Second))
+	require.Empty(t, store.PendingChangesByStore())
+	value, err = store.ssStore.Get("bank", 3, []byte("a"))
+	require.NoError(t, err)
+	require.Equal(t, []byte("2"), value)
+
+	disabled := newTestStore(t, false, key)
+	require.ErrorIs(t, disabled.WaitForSSApplied(0, time.Second), ErrStateStoreDisabled)
+}
+
+func TestPendingChangesLen(t *testing.T) {
+	key := types.NewKVStoreKey("bank")
+	store := newTestStore(t, true, key)
+	var logs bytes.Buffer
+	store.logger = log.NewTMLogger(log.NewSyncWriter(&logs))
+	store.pendingChangesWarnRatio = 0.002
+	require.Equal(t, 1000, store.PendingChangesCap())
+	require.Zero(t, store.PendingChangesLen())
+
+	store.PauseSS()
+	for i := 1; i <= 3; i++ {
+		store.GetKVStore(key).Set([]byte("a"), []byte{byte(i)})
+		store.Commit(true)
+	}
+	// the first version may be received already by the paused SS commit routine
+	require.GreaterOrEqual(t, store.PendingChangesLen(), 2)
+	require.Contains(t, logs.String(), "pending changes buffer is almost full")
+
+	store.ResumeSS()
+	waitForSS(t, store, 3)
+	require.Zero(t, store.PendingChangesLen())
+}
+
+func TestPendingChangesBuffer(t *testing.T) {
+	require.Panics(t, func() {
+		NewStore(t.TempDir(), log.NewNopLogger(), config.StateCommitConfig{}, config.StateStoreConfig{}, WithPendingChangesBuffer(-1))
+	})
+
+	key := types.NewKVStoreKey("bank")
+	ssConfig := config.DefaultStateStoreConfig()
+	ssConfig.Enable = true
+	store := NewStore(t.TempDir(), log.NewNopLogger(), config.StateCommitConfig{}, ssConfig, WithPendingChangesBuffer(0))
+	store.MountStoreWithDB(key, types.StoreTypeIAVL, nil)
+	require.NoError(t, store.LoadLatestVersion())
+	require.Zero(t, store.PendingChangesCap())
+	for i := 1; i <= 3; i++ {
+		store.GetKVStore(key).Set([]byte("a"), []byte{byte(i)})
+		store.Commit(true)
+	}
+	waitForSS(t, store, 3)
+	for i := int64(1); i <= 3; i++ {
+		value, err := store.ssStore.Get("bank", i, []byte("a"))
+		require.NoError(t, err)
+		require.Equal(t, []byte{byte(i)}, value)
+	}
+	require.NoError(t, store.Close())
+}
+
+// slowStateStore delays applying the changesets.
+type slowStateStore struct {
+	sstypes.StateStore
+}
+
+func (s slowStateStore) ApplyChangeset(version int64, cs *proto.NamedChangeSet) error {
+	time.Sleep(10 * time.Millisecond)
+	return s.StateStore.ApplyChangeset(version, cs)
+}
+
+func TestCloseAppliesPendingChanges(t *testing.T) {
+	key := types.NewKVStoreKey("bank")
+	home := t.TempDir()
+	ssConfig := config.DefaultStateStoreConfig()
+	ssConfig.Enable = true
+	ssConfig.KeepRecent = 0
+	open := func(opts ...Option) *Store {
+		store := NewStore(home, log.NewNopLogger(), config.StateCommitConfig{}, ssConfig, opts...)
+		store.MountStoreWithDB(key, types.StoreTypeIAVL, nil)
+		require.NoError(t, store.LoadLatestVersion())
+		return store
+	}
+
+	store := open()
+	store.ssStore = slowStateStore{store.ssStore}
+	for i := 1; i <= 5; i++ {
+		store.GetKVStore(key).Set([]byte("a"), []byte{byte(i)})
+		store.Commit(true)
+	}
+	require.NoError(t, store.Close())
+
+	store = open()
+	defer store.Close()
+	latest, err := store.ssStore.GetLatestVersion()
+	require.NoError(t, err)
+	require.Equal(t, int64(5), latest)
+	for i := int64(1); i <= 5; i++ {
+		value, err := store.ssStore.Get("bank", i, []byte("a"))
+		require.NoError(t, err)
+		require.Equal(t, []byte{byte(i)}, value)
+	}
+}
+
+// TestSSVersionsMatchSCAcrossRestart checks SS serves at each height the state committed by sc at that height,
+// before and after the store is reopened.
+func TestSSVersionsMatchSCAcrossRestart(t *testing.T) {
+	key := types.NewKVStoreKey("bank")
+	home := t.TempDir()
+	open := func() *Store {
+		ssConfig := config.DefaultStateStoreConfig()
+		ssConfig.Enable = true
+		ssConfig.KeepRecent = 0
+		store := NewStore(home, log.NewNopLogger(), config.StateCommitConfig{}, ssConfig)
+		store.MountStoreWithDB(key, types.StoreTypeIAVL, nil)
+		require.NoError(t, store.LoadLatestVersion())
+		return store
+	}
+
+	expected := map[int64]map[string]string{}
+	commit := func(store *Store, height int64) {
+		kvStore := store.GetKVStore(key)
+		kvStore.Set([]byte(fmt.Sprintf("k%d", height)), []byte(fmt.Sprintf("v%d", height)))
+		kvStore.Set([]byte("a"), []byte(fmt.Sprintf("v%d", height)))
+		if height%3 == 0 {
+			kvStore.Delete([]byte(fmt.Sprintf("k%d", height-1)))
+		}
+		require.Equal(t, height, store.Commit(true).Version)
+		expected[height] = iterateAll(store.GetKVStore(key))
+	}
+
+	store := open()
+	for height := int64(1); height <= 3; height++ {
+		commit(store, height)
+	}
+	require.NoError(t, store.Close())
+	store = open()
+	defer store.Close()
+	for height := int64(4); height <= 6; height++ {
+		commit(store, height)
+	}
+	waitForSS(t, store, 6)
+
+	for height := int64(1); height <= 6; height++ {
+		for i := int64(1); i <= 6; i++ {
+			key := fmt.Sprintf("k%d", i)
+			value, err := store.ssStore.Get("bank", height, []byte(key))
+			require.NoError(t, err)
+			if expectedValue, ok := expected[height][key]; ok {
+				require.Equal(t, expectedValue, string(value), "height %d, key %s", height, key)
+			} else {
+				require.Nil(t, value, "height %d, key %s", height, key)
+			}
+		}
+		value, err := store.ssStore.Get("bank", height, []byte("a"))
+		require.NoError(t, err)
+		require.Equal(t, expected[height]["a"], string(value), "height %d", height)
+	}
+}
+
+func TestCloseTimeout(t *testing.T) {
+	key := types.NewKVStoreKey("bank")
+	ssConfig := config.DefaultStateStoreConfig()
+	ssConfig.Enable = true
+	ssConfig.KeepRecent = 0
+	store := NewStore(t.TempDir(), log.NewNopLogger(), config.StateCommitConfig{}, ssConfig, WithCloseTimeout(50*time.Millisecond))
+	store.MountStoreWithDB(key, types.StoreTypeIAVL, nil)
+	require.NoError(t, store.LoadLatestVersion())
+
+	store.PauseSS()
+	store.GetKVStore(key).Set([]byte("a"), []byte("1"))
+	store.Commit(true)
+	require.ErrorContains(t, store.Close(), "timeout applying the pending changes")
+	// SS is left open for the changes still being applied
+	store.ResumeSS()
+	waitForSS(t, store, 1)
+	require.NoError(t, store.ssStore.Close())
+}
+
+// failingApplyStateStore fails to apply the changesets of a store.
+type failingApplyStateStore struct {
+	sstypes.StateStore
+	store string
+}
+
+func (s failingApplyStateStore) ApplyChangeset(version int64, cs *proto.NamedChangeSet) error {
+	if cs.Name == s.store {
+		return errors.New("disk failure")
+	}
+	return s.StateStore.ApplyChangeset(version, cs)
+}
+
+func TestSSErrorHandler(t *testing.T) {
+	acc, bank := types.NewKVStoreKey("acc"), types.NewKVStoreKey("bank")
+	type ssError struct {
+		version int64
+		store   string
+		err     error
+	}
+	errs := make(chan ssError, 1)
+	ssConfig := config.DefaultStateStoreConfig()
+	ssConfig.Enable = true
+	ssConfig.KeepRecent = 0
+	store := NewStore(t.TempDir(), log.NewNopLogger(), config.StateCommitConfig{}, ssConfig, WithSSErrorHandler(func(version int64, storeName string, err error) {
+		errs <- ssError{version, storeName, err}
+	}))
+	store.MountStoreWithDB(acc, types.StoreTypeIAVL, nil)
+	store.MountStoreWithDB(bank, types.StoreTypeIAVL, nil)
+	require.NoError(t, store.LoadLatestVersion())
+	defer store.Close()
+	store.ssStore = failingApplyStateStore{StateStore: store.ssStore, store: "acc"}
+
+	store.GetKVStore(acc).Set([]byte("a"), []byte("1"))
+	store.GetKVStore(bank).Set([]byte("a"), []byte("1"))
+	store.Commit(true)
+	select {
+	case ssErr := <-errs:
+		require.Equal(t, int64(1), ssErr.version)
+		require.Equal(t, "acc", ssErr.store)
+		require.ErrorContains(t, ssErr.err, "disk failure")
+	case <-time.After(5 * time.Second):
+		t.Fatal("SS error not handled")
+	}
+
+	// the next versions are still applied
+	store.GetKVStore(bank).Set([]byte("a"), []byte("2"))
+	store.Commit(true)
+	waitForSS(t, store, 2)
+}
+
+func TestSyncSSCommit(t *testing.T) {
+	key := types.NewKVStoreKey("bank")
+	ssConfig := config.DefaultStateStoreConfig()
+	ssConfig.Enable = true
+	ssConfig.KeepRecent = 0
+	newStore := func(opts ...Option) *Store {
+		store := NewStore(t.TempDir(), log.NewNopLogger(), config.StateCommitConfig{}, ssConfig, opts...)
+		store.MountStoreWithDB(key, types.StoreTypeIAVL, nil)
+		require.NoError(t, store.LoadLatestVersion())
+		t.Cleanup(func() { require.NoError(t, store.Close()) })
+		return store
+	}
+	syncStore, asyncStore := newStore(WithSyncSSCommit()), newStore()
+	for i := 1; i <= 5; i++ {
+		for _, store := range []*Store{syncStore, asyncStore} {
+			kvStore := store.GetKVStore(key)
+			kvStore.Set([]byte(fmt.Sprintf("key-%d", i)), []byte{byte(i)})
+			kvStore.Delete([]byte(fmt.Sprintf("key-%d", i-1)))
+			store.Commit(true)
+		}
+		// SS is in sync as soon as the commit returns
+		latest, err := syncStore.ssStore.GetLatestVersion()
+		require.NoError(t, err)
+		require.Equal(t, int64(i), latest)
+	}
+	require.Zero(t, syncStore.PendingChangesLen())
+
+	waitForSS(t, asyncStore, 5)
+	for version := int64(1); version <= 5; version++ {
+		for i := 0; i <= 5; i++ {
+			key := []byte(fmt.Sprintf("key-%d", i))
+			syncValue, err := syncStore.ssStore.Get("bank", version, key)
+			require.NoError(t, err)
+			asyncValue, err := asyncStore.ssStore.Get("bank", version, key)
+			require.NoError(t, err)
+			require.Equal(t, asyncValue, syncValue)
+		}
+	}
+
+	syncStore.ssStore = failingApplyStateStore{StateStore: syncStore.ssStore, store: "bank"}
+	syncStore.GetKVStore(key).Set([]byte("a"), []byte("1"))
+	_, err := syncStore.TryCommit(true)
+	require.ErrorContains(t, err, "disk failure")
+}
+
+func TestValidateChangesetOrder(t *testing.T) {
+	require.NoError(t, validateChangesetOrder(nil))
+	require.NoError(t, validateChangesetOrder([]*proto.NamedChangeSet{{Name: "acc"}, {Name: "bank"}}))
+	require.Error(t, validateChangesetOrder([]*proto.NamedChangeSet{{Name: "bank"}, {Name: "acc"}}))
+	require.Error(t, validateChangesetOrder([]*proto.NamedChangeSet{{Name: "acc"}, {Name: "acc"}}))
+
+	bank, acc := types.NewKVStoreKey("bank"), types.NewKVStoreKey("acc")
+	store := NewStore(t.TempDir(), log.NewNopLogger(), config.StateCommitConfig{}, config.StateStoreConfig{}, WithChangesetOrderCheck())
+	store.MountStoreWithDB(bank, types.StoreTypeIAVL, nil)
+	store.MountStoreWithDB(acc, types.StoreTypeIAVL, nil)
+	require.NoError(t, store.LoadLatestVersion())
+	defer store.Close()
+	store.GetKVStore(bank).Set([]byte("a"), []byte("1"))
+	store.GetKVStore(acc).Set([]byte("a"), []byte("1"))
+	require.NotPanics(t, func() {
+		store.Commit(true)
+	})
+}
+
+func TestApplySSChangesOrder(t *testing.T) {
+	bank, acc := types.NewKVStoreKey("bank"), types.NewKVStoreKey("acc")
+	store := newTestStore(t, true, bank, acc)
+	store.GetKVStore(bank).Set([]byte("a"), []byte("1"))
+	store.Commit(true)
+	store.Commit(true)
+	waitForSS(t, store, 1)
+
+	changeset := func(name string) *proto.NamedChangeSet {
+		return &proto.NamedChangeSet{Name: name, Changeset: iavl.ChangeSet{Pairs: []*iavl.KVPair{{Key: []byte("b"), Value: []byte("2")}}}}
+	}
+	// the versions already applied are rejected
+	_, err := store.applySSChanges(VersionedChangesets{Version: 1, Changesets: []*proto.NamedChangeSet{changeset("bank")}})
+	require.ErrorContains(t, err, "increasing order")
+	// the unsorted changesets are rejected before any is applied
+	_, err = store.applySSChanges(VersionedChangesets{Version: 3, Changesets: []*proto.NamedChangeSet{changeset("bank"), changeset("acc")}})
+	require.ErrorContains(t, err, "ordering invariant violated")
+	value, err := store.ssStore.Get("bank", 3, []byte("b"))
+	require.NoError(t, err)
+	require.Nil(t, value)
+
+	_, err = store.applySSChanges(VersionedChangesets{Version: 3, Changesets: []*proto.NamedChangeSet{changeset("acc"), changeset("bank")}})
+	require.NoError(t, err)
+	for _, name := range []string{"acc", "bank"} {
+		value, err := store.ssStore.Get(name, 3, []byte("b"))
+		require.NoError(t, err)
+		require.Equal(t, []byte("2"), value)
+	}
+}
+
+func TestAmendCommitInfo(t *testing.T) {
+	bank, mem, transient := types.NewKVStoreKey("bank"), types.NewMemoryStoreKey("mem"), types.NewTransientStoreKey("transient")
+	store := NewStore(t.TempDir(), log.NewNopLogger(), config.StateCommitConfig{}, config.StateStoreConfig{})
+	store.MountStoreWithDB(bank, types.StoreTypeIAVL, nil)
+	store.MountStoreWithDB(mem, types.StoreTypeMemory, nil)
+	store.MountStoreWithDB(transient, types.StoreTypeTransient, nil)
+	require.NoError(t, store.LoadLatestVersion())
+	defer store.Close()
+
+	store.GetKVStore(bank).Set([]byte("a"), []byte("1"))
+	hash, err := store.GetWorkingHash()
+	require.NoError(t, err)
+	commitID := store.Commit(true)
+	require.Equal(t, hash, commitID.Hash)
+
+	var names []string
+	for _, info := range store.lastCommitInfo.StoreInfos {
+		names = append(names, info.Name)
+	}
+	require.Equal(t, []string{"bank", "mem"}, names)
+}
+
+func BenchmarkGetWorkingHash(b *testing.B) {
+	store := NewStore(b.TempDir(), log.NewNopLogger(), config.StateCommitConfig{}, config.StateStoreConfig{})
+	var keys []types.StoreKey
+	for i := 0; i < 20; i++ {
+		key := types.NewKVStoreKey(fmt.Sprintf("store%d", i))
+		store.MountStoreWithDB(key, types.StoreTypeIAVL, nil)
+		keys = append(keys, key)
+		store.MountStoreWithDB(types.NewMemoryStoreKey(fmt.Sprintf("mem%d", i)), types.StoreTypeMemory, nil)
+	}
+	require.NoError(b, store.LoadLatestVersion())
+	defer store.Close()
+
+	for _, key := range keys {
+		store.GetKVStore(key).Set([]byte("key"), []byte("value"))
+	}
+
+	// the working hash is computed repeatedly within the same block
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := store.GetWorkingHash(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestQueryInitialVersion(t *testing.T) {
+	key := types.NewKVStoreKey("bank")
+	for _, ssEnabled := range []bool{true, false} {
+		ssConfig := config.DefaultStateStoreConfig()
+		ssConfig.Enable = ssEnabled
+		store := NewStore(t.TempDir(), log.NewNopLogger(), config.StateCommitConfig{}, ssConfig)
+		store.MountStoreWithDB(key, types.StoreTypeIAVL, nil)
+		require.NoError(t, store.LoadLatestVersion())
+		require.NoError(t, store.SetInitialVersion(100))
+		for i := 0; i < 3; i++ {
+			store.GetKVStore(key).Set([]byte("a"), []byte{byte(i)})
+			store.Commit(true)
+		}
+		require.Equal(t, int64(102), store.LastCommitID().Version)
+		if ssEnabled {
+			waitForSS(t, store, 102)
+		}
+
+		query := func(height int64) abci.ResponseQuery {
+			return store.Query(abci.RequestQuery{Path: "/bank/key", Data: []byte("a"), Height: height})
+		}
+		// height 0 is the latest version
+		res := query(0)
+		require.True(t, res.IsOK(), res.Log)
+		require.Equal(t, []byte{2}, res.Value)
+		res = query(102)
+		require.True(t, res.IsOK(), res.Log)
+		require.Equal(t, []byte{2}, res.Value)
+		if ssEnabled {
+			// the initial version is served from SS rather than the latest version
+			res = query(100)
+			require.True(t, res.IsOK(), res.Log)
+			require.Equal(t, []byte{0}, res.Value)
+		}
+		// heights before the initial version don't exist
+		res = query(1)
+		require.False(t, res.IsOK())
+		require.Equal(t, sdkerrors.ErrInvalidHeight.ABCICode(), res.Code)
+
+		require.NoError(t, store.Close())
+	}
+}
+
+func TestQueryTracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tracer := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)).Tracer("test")
+	key := types.NewKVStoreKey("bank")
+	store := NewStore(t.TempDir(), log.NewNopLogger(), config.StateCommitConfig{}, config.StateStoreConfig{}, WithTracer(tracer))
+	store.MountStoreWithDB(key, types.StoreTypeIAVL, nil)
+	require.NoError(t, store.LoadLatestVersion())
+	defer store.Close()
+	store.GetKVStore(key).Set([]byte("a"), []byte("1"))
+	store.Commit(true)
+
+	res := store.Query(abci.RequestQuery{Path: "/bank/key", Data: []byte("a")})
+	require.True(t, res.IsOK(), res.Log)
+	spans := recorder.Ended()
+	require.Len(t, spans, 1)
+	require.Equal(t, "Query", spans[0].Name())
+	require.ElementsMatch(t, []attribute.KeyValue{
+		attribute.String("store", "bank"),
+		attribute.Int64("version", 1),
+		attribute.Bool("prove", false),
+		attribute.String("route", QueryRouteLatestSC),
+	}, spans[0].Attributes())
+}
+
+func TestReloadStore(t *testing.T) {
+	bank, acc := types.NewKVStoreKey("bank"), types.NewKVStoreKey("acc")
+	store := newTestStore(t, false, bank, acc)
+	store.GetKVStore(bank).Set([]byte("a"), []byte("1"))
+	store.Commit(true)
+
+	// unchanged trees retain their handles
+	bankStore, accStore := store.GetCommitKVStore(bank), store.GetCommitKVStore(acc)
+	store.GetKVStore(acc).Set([]byte("a"), []byte("1"))
+	store.Commit(true)
+	require.Same(t, bankStore, store.GetCommitKVStore(bank))
+	require.Same(t, accStore, store.GetCommitKVStore(acc))
+
+	// a store whose tree is replaced is reloaded
+	store.ckvStores[bank] = commitment.NewStore(nil, log.NewNopLogger())
+	reloaded, err := store.reloadStore(bank)
+	require.NoError(t, err)
+	require.True(t, reloaded)
+	require.Equal(t, []byte("1"), store.GetKVStore(bank).Get([]byte("a")))
+	reloaded, err = store.reloadStore(acc)
+	require.NoError(t, err)
+	require.False(t, reloaded)
+}
+
+func TestGetWithVersion(t *testing.T) {
+	key := types.NewKVStoreKey("bank")
+	store := newTestStore(t, true, key)
+	writes := []map[string]string{
+		{"a": "1"},
+		{"b": "1"},
+		{"a": "2"},
+		{"b": "2"},
+	}
+	for _, pairs := range writes {
+		for k, v := range pairs {
+			store.GetKVStore(key).Set([]byte(k), []byte(v))
+		}
+		store.Commit(true)
+	}
+	waitForSS(t, store, 4)
+
+	value, version, err := store.GetWithVersion("bank", []byte("a"))
+	require.NoError(t, err)
+	require.Equal(t, []byte("2"), value)
+	require.Equal(t, int64(3), version)
+
+	value, version, err = store.GetWithVersion("bank", []byte("b"))
+	require.NoError(t, err)
+	require.Equal(t, []byte("2"), value)
+	require.Equal(t, int64(4), version)
+
+	value, version, err = store.GetWithVersion("bank", []byte("c"))
+	require.NoError(t, err)
+	require.Nil(t, value)
+	require.Equal(t, int64(-1), version)
+
+	_, _, err = store.GetWithVersion("acc", []byte("a"))
+	require.Error(t, err)
+
+	// the version can't be determined beyond the search limit
+	store.queries.versionSearchLimit = 1
+	value, version, err = store.GetWithVersion("bank", []byte("a"))
+	require.NoError(t, err)
+	require.Equal(t, []byte("2"), value)
+	require.Equal(t, int64(-1), version)
+	store.queries.versionSearchLimit = 3
+	value, version, err = store.GetWithVersion("bank", []byte("a"))
+	require.NoError(t, err)
+	require.Equal(t, []byte("2"), value)
+	require.Equal(t, int64(3), version)
+	store.queries.versionSearchLimit = DefaultVersionSearchLimit
+
+	// the versions before the write are pruned
+	_, err = store.pruningManager.PruneUpTo(3)
+	require.NoError(t, err)
+	value, version, err = store.GetWithVersion("bank", []byte("a"))
+	require.NoError(t, err)
+	require.Equal(t, []byte("2"), value)
+	require.Equal(t, int64(-1), version)
+
+	_, _, err = newTestStore(t, false, key).GetWithVersion("bank", []byte("a"))
+	require.ErrorIs(t, err, ErrStateStoreDisabled)
+}
+
+func TestPruneStateStore(t *testing.T) {
+	key := types.NewKVStoreKey("bank")
+	store := newTestStore(t, true, key)
+	for i := 1; i <= 4; i++ {
+		store.GetKVStore(key).Set([]byte("a"), []byte{byte(i)})
+		store.Commit(true)
+	}
+	waitForSS(t, store, 4)
+
+	require.Error(t, store.PruneStateStore(0))
+	require.ErrorContains(t, store.PruneStateStore(4), "must be below the latest version 4")
+
+	require.NoError(t, store.PruneStateStore(2))
+	require.Equal(t, int64(3), store.ssEarliestVersion())
+	for version := int64(3); version <= 4; version++ {
+		value, err := store.ssStore.Get("bank", version, []byte("a"))
+		require.NoError(t, err)
+		require.Equal(t, []byte{byte(version)}, value)
+	}
+
+	require.ErrorIs(t, newTestStore(t, false, key).PruneStateStore(1), ErrStateStoreDisabled)
+}
+
+func TestWorkingHashCache(t *testing.T) {
+	key := types.NewKVStoreKey("bank")
+	store := NewStore(t.TempDir(), log.NewNopLogger(), config.StateCommitConfig{}, config.StateStoreConfig{}, WithWorkingHashCache())
+	store.MountStoreWithDB(key, types.StoreTypeIAVL, nil)
+	require.NoError(t, store.LoadLatestVersion())
+	defer store.Close()
+
+	store.GetKVStore(key).Set([]byte("a"), []byte("1"))
+	hash1, err := store.GetWorkingHash()
+	require.NoError(t, err)
+	require.Equal(t, hash1, store.workingHash)
+	hash, err := store.GetWorkingHash()
+	require.NoError(t, err)
+	require.Equal(t, hash1, hash)
+
+	require.Equal(t, hash1, store.Commit(true).Hash)
+
+	// commit invalidates the cache
+	require.Nil(t, store.workingHash)
+	hash, err = store.GetWorkingHash()
+	require.NoError(t, err)
+	require.Equal(t, hash1, hash)
+
+	// new writes invalidate the cache
+	store.GetKVStore(key).Set([]byte("a"), []byte("2"))
+	hash2, err := store.GetWorkingHash()
+	require.NoError(t, err)
+	require.NotEqual(t, hash1, hash2)
+	require.Equal(t, hash2, store.Commit(true).Hash)
+}
+
+func TestWorkingHashMissingStore(t *testing.T) {
+	bank, acc := types.NewKVStoreKey("bank"), types.NewKVStoreKey("acc")
+	store := NewStore(t.TempDir(), log.NewNopLogger(), config.StateCommitConfig{}, config.StateStoreConfig{})
+	store.MountStoreWithDB(bank, types.StoreTypeIAVL, nil)
+	store.MountStoreWithDB(acc, types.StoreTypeIAVL, nil)
+	// the stores are not loaded yet
+	_, err := store.GetWorkingHash()
+	require.ErrorContains(t, err, "acc, bank")
+
+	require.NoError(t, store.LoadLatestVersion())
+	defer store.Close()
+	_, err = store.GetWorkingHash()
+	require.NoError(t, err)
+	// the store of bank is mounted but not loaded
+	delete(store.ckvStores, bank)
+	_, err = store.GetWorkingHash()
+	require.ErrorContains(t, err, "mounted stores without a tree in the sc store: bank")
+	_, err = store.TryCommit(true)
+	require.Error(t, err)
+}
+
+func TestAmendCommitInfoDeterminism(t *testing.T) {
+	type mount struct {
+		key types.StoreKey
+		typ types.StoreType
+	}
+	mounts := []mount{
+		{types.NewKVStoreKey("acc"), types.StoreTypeIAVL},
+		{types.NewKVStoreKey("bank"), types.StoreTypeIAVL},
+		{types.NewKVStoreKey("staking"), types.StoreTypeIAVL},
+		{types.NewTransientStoreKey("transient_params"), types.StoreTypeTransient},
+		{types.NewTransientStoreKey("transient_bank"), types.StoreTypeTransient},
+		{types.NewMemoryStoreKey("mem_capability"), types.StoreTypeMemory},
+		{types.NewMemoryStoreKey("mem_oracle"), types.StoreTypeMemory},
+	}
+	commit := func(mounts []mount, mountStore func(types.StoreKey, types.StoreType), load func() error, commitMultiStore types.CommitMultiStore) types.CommitID {
+		for _, m := range mounts {
+			mountStore(m.key, m.typ)
+		}
+		require.NoError(t, load())
+		for _, m := range mounts {
+			commitMultiStore.GetKVStore(m.key).Set([]byte("key"), []byte(m.key.Name()))
+		}
+		return commitMultiStore.Commit(true)
+	}
+
+	// the legacy rootmulti store of cosmos-sdk 0.46 is the reference
+	legacy := rootmulti.NewStore(dbm.NewMemDB(), log.NewNopLogger())
+	expected := commit(mounts, func(key types.StoreKey, typ types.StoreType) {
+		legacy.MountStoreWithDB(key, typ, nil)
+	}, legacy.LoadLatestVersion, legacy)
+
+	for i := range mounts {
+		// mount the stores in a different order each run
+		rotated := append(append([]mount{}, mounts[i:]...), mounts[:i]...)
+		store := NewStore(t.TempDir(), log.NewNopLogger(), config.StateCommitConfig{}, config.StateStoreConfig{})
+		commitID := commit(rotated, func(key types.StoreKey, typ types.StoreType) {
+			store.MountStoreWithDB(key, typ, nil)
+		}, store.LoadLatestVersion, store)
+		require.Equal(t, expected, commitID)
+
+		var names []string
+		for _, info := range store.lastCommitInfo.StoreInfos {
+			names = append(names, info.Name)
+		}
+		require.Equal(t, []string{"acc", "bank", "mem_capability", "mem_oracle", "staking"}, names)
+		require.NoError(t, store.Close())
+	}
+}
+
+func TestIsAmendedStoreType(t *testing.T) {
+	require.True(t, isAmendedStoreType(types.StoreTypeMemory))
+	for _, typ := range []types.StoreType{types.StoreTypeMulti, types.StoreTypeDB, types.StoreTypeIAVL, types.StoreTypeTransient, types.StoreType(100)} {
+		require.False(t, isAmendedStoreType(typ), typ.String())
+	}
+}
+
+func TestMountStores(t *testing.T) {
+	bank, acc, mem := types.NewKVStoreKey("bank"), types.NewKVStoreKey("acc"), types.NewMemoryStoreKey("mem")
+	store := NewStore(t.TempDir(), log.NewNopLogger(), config.StateCommitConfig{}, config.StateStoreConfig{})
+	store.MountStores([]StoreMount{
+		{Key: bank, Type: types.StoreTypeIAVL},
+		{Key: mem, Type: types.StoreTypeMemory},
+	})
+	store.MountStoreWithDB(acc, types.StoreTypeIAVL, nil)
+	require.NoError(t, store.LoadLatestVersion())
+	defer store.Close()
+	require.NotNil(t, store.GetKVStore(bank))
+	require.NotNil(t, store.GetKVStore(mem))
+	require.NotNil(t, store.GetKVStore(acc))
+}
+
+func TestMountStoresConflicts(t *testing.T) {
+	bank, staking := types.NewKVStoreKey("bank"), types.NewKVStoreKey("staking")
+	store := NewStore(t.TempDir(), log.NewNopLogger(), config.StateCommitConfig{}, config.StateStoreConfig{})
+	store.MountStoreWithDB(bank, types.StoreTypeIAVL, nil)
+
+	require.PanicsWithError(t, "failed to mount stores: duplicate store key bank; "+
+		"store key at index 2 cannot be nil; duplicate store key staking; duplicate store key name bank", func() {
+		store.MountStores([]StoreMount{
+			{Key: bank, Type: types.StoreTypeIAVL},
+			{Key: staking, Type: types.StoreTypeIAVL},
+			{Key: nil, Type: types.StoreTypeIAVL},
+			{Key: staking, Type: types.StoreTypeIAVL},
+			{Key: types.NewKVStoreKey("bank"), Type: types.StoreTypeIAVL},
+		})
+	})
+	// nothing is mounted on conflicts
+	require.NotContains(t, store.storeKeys, "staking")
+}
+
+type sizerStateStore struct {
+	sstypes.StateStore
+}
+
+func (sizerStateStore) SizeByVersionRange(from, to int64) (int64, error) {
+	return (to - from + 1) * 100, nil
+}
+
+func TestSSSizeByVersionRange(t *testing.T) {
+	key := types.NewKVStoreKey("bank")
+	_, err := newTestStore(t, false, key).SSSizeByVersionRange(1, 10)
+	require.ErrorIs(t, err, ErrStateStoreDisabled)
+
+	store := newTestStore(t, true, key)
+	_, err = store.SSSizeByVersionRange(1, 10)
+	require.ErrorIs(t, err, ErrSSSizeUnsupported)
+	_, err = store.SSSizeByVersionRange(10, 1)
+	require.Error(t, err)
+
+	ssStore := store.ssStore
+	store.ssStore = sizerStateStore{ssStore}
+	defer func() {
+		store.ssStore = ssStore
+	}()
+	size, err := store.SSSizeByVersionRange(1, 10)
+	require.NoError(t, err)
+	require.Equal(t, int64(1000), size)
+}
+
+func TestMountAfterLoad(t *testing.T) {
+	store := newTestStore(t, false, types.NewKVStoreKey("bank"))
+	require.PanicsWithValue(t, "cannot mount store acc after the stores are loaded", func() {
+		store.MountStoreWithDB(types.NewKVStoreKey("acc"), types.StoreTypeIAVL, nil)
+	})
+	require.NotContains(t, store.storeKeys, "acc")
+}
+
+// countdownContext is canceled once its error has been checked a number of times.
+type countdownContext struct {
+	context.Context
+	remaining int
+}
+
+func (ctx *countdownContext) Err() error {
+	if ctx.remaining == 0 {
+		return context.Canceled
+	}
+	ctx.remaining--
+	return nil
+}
+
+func TestLoadVersionAndUpgradeContext(t *testing.T) {
+	home := t.TempDir()
+	bank, acc, staking := types.NewKVStoreKey("bank"), types.NewKVStoreKey("acc"), types.NewKVStoreKey("staking")
+	open := func(ctx context.Context, upgrades *types.StoreUpgrades, keys ...types.StoreKey) (*Store, error) {
+		store := NewStore(home, log.NewNopLogger(), config.StateCommitConfig{}, config.StateStoreConfig{})
+		for _, key := range keys {
+			store.MountStoreWithDB(key, types.StoreTypeIAVL, nil)
+		}
+		return store, store.LoadVersionAndUpgradeContext(ctx, 0, upgrades)
+	}
+	store, err := open(context.Background(), nil, bank, acc)
+	require.NoError(t, err)
+	store.GetKVStore(bank).Set([]byte("a"), []byte("1"))
+	store.Commit(true)
+	require.NoError(t, store.Close())
+
+	// the load is checked before initializing, before the upgrades and before loading each of the 3 stores
+	upgrades := &types.StoreUpgrades{Added: []string{"staking"}}
+	for checks := 0; checks < 5; checks++ {
+		store, err = open(&countdownContext{Context: context.Background(), remaining: checks}, upgrades, bank, acc, staking)
+		require.ErrorIs(t, err, context.Canceled)
+		require.False(t, store.loaded)
+		if checks > 0 {
+			// the sc store is opened once initialized
+			require.NoError(t, store.Close())
+		}
+	}
+	store, err = open(&countdownContext{Context: context.Background(), remaining: 5}, upgrades, bank, acc, staking)
+	require.NoError(t, err)
+	require.Equal(t, []byte("1"), store.GetKVStore(bank).Get([]byte("a")))
+	store.GetKVStore(staking).Set([]byte("a"), []byte("1"))
+	store.Commit(true)
+	require.NoError(t, store.Close())
+}
+
+func TestLoadVersionAndUpgradeValidation(t *testing.T) {
+	home := t.TempDir()
+	bank, acc, staking := types.NewKVStoreKey("bank"), types.NewKVStoreKey("acc"), types.NewKVStoreKey("staking")
+	open := func(upgrades *types.StoreUpgrades, keys ...types.StoreKey) (*Store, error) {
+		store := NewStore(home, log.NewNopLogger(), config.StateCommitConfig{}, config.StateStoreConfig{})
+		for _, key := range keys {
+			store.MountStoreWithDB(key, types.StoreTypeIAVL, nil)
+		}
+		return store, store.LoadLatestVersionAndUpgrade(upgrades)
+	}
+	store, err := open(nil, bank, acc)
+	require.NoError(t, err)
+	store.GetKVStore(bank).Set([]byte("a"), []byte("1"))
+	store.Commit(true)
+	require.NoError(t, store.Close())
+
+	testCases := []struct {
+		name     string
+		upgrades *types.StoreUpgrades
+		expErr   string
+	}{
+		{"rename from missing", &types.StoreUpgrades{Renamed: []types.StoreRename{{OldKey: "gov", NewKey: "staking"}}}, "cannot rename store gov to staking, gov doesn't exist"},
+		{"delete missing", &types.StoreUpgrades{Deleted: []string{"staking"}}, "cannot delete store staking which doesn't exist"},
+		{"add existing", &types.StoreUpgrades{Added: []string{"acc"}}, "cannot add store acc which already exists"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			store, err := open(tc.upgrades, bank, acc, staking)
+			require.Error(t, err)
+			require.Contains(t, err.Error(), tc.expErr)
+			require.NoError(t, store.Close())
+		})
+	}
+
+	store, err = open(&types.StoreUpgrades{Added: []string{"staking"}}, bank, acc, staking)
+	require.NoError(t, err)
+	defer store.Close()
+	store.GetKVStore(staking).Set([]byte("a"), []byte("1"))
+	store.Commit(true)
+	require.Equal(t, []byte("1"), store.GetKVStore(bank).Get([]byte("a")))
+}
+
+// failingCommitter fails the commits of the sc store.
+type failingCommitter struct {
+	sctypes.Committer
+}
+
+func (c failingCommitter) Commit() (int64, error) {
+	return 0, errors.New("disk failure")
+}
+
+func TestTryCommit(t *testing.T) {
+	key := types.NewKVStoreKey("bank")
+	store := newTestStore(t, false, key)
+	store.GetKVStore(key).Set([]byte("a"), []byte("1"))
+	commitID, err := store.TryCommit(true)
+	require.NoError(t, err)
+	require.Equal(t, store.LastCommitID(), commitID)
+
+	// the failure to reload a store is returned with its cause
+	scStore := store.scStore
+	store.scStore = &treeDroppingCommitter{Committer: scStore}
+	store.GetKVStore(key).Set([]byte("a"), []byte("2"))
+	_, err = store.TryCommit(true)
+	require.ErrorContains(t, err, "failed to reload store bank: new store is not added in upgrades: bank")
+	require.Equal(t, commitID, store.LastCommitID())
+	store.scStore = scStore
+	commitID, err = store.TryCommit(true)
+	require.NoError(t, err)
+
+	store.scStore = failingCommitter{scStore}
+	store.GetKVStore(key).Set([]byte("a"), []byte("3"))
+	_, err = store.TryCommit(true)
+	require.ErrorContains(t, err, "disk failure")
+	require.Equal(t, commitID, store.LastCommitID())
+	require.Panics(t, func() { store.Commit(true) })
+	store.scStore = scStore
+}
+
+// treeDroppingCommitter loses the trees of the sc store once committed.
+type treeDroppingCommitter struct {
+	sctypes.Committer
+	committed bool
+}
+
+func (c *treeDroppingCommitter) Commit() (int64, error) {
+	c.committed = true
+	return c.Committer.Commit()
+}
+
+func (c *treeDroppingCommitter) GetTreeByName(name string) sctypes.Tree {
+	if c.committed {
+		return nil
+	}
+	return c.Committer.GetTreeByName(name)
+}
+
+func TestMaxStores(t *testing.T) {
+	store := NewStore(t.TempDir(), log.NewNopLogger(), config.StateCommitConfig{}, config.StateStoreConfig{}, WithMaxStores(3))
+	store.MountStores([]StoreMount{
+		{Key: types.NewKVStoreKey("acc"), Type: types.StoreTypeIAVL},
+		{Key: types.NewKVStoreKey("bank"), Type: types.StoreTypeIAVL},
+	})
+	require.PanicsWithError(t, "failed to mount stores: the number of stores 4 exceeds the limit 3", func() {
+		store.MountStores([]StoreMount{
+			{Key: types.NewKVStoreKey("mem"), Type: types.StoreTypeMemory},
+			{Key: types.NewKVStoreKey("staking"), Type: types.StoreTypeIAVL},
+		})
+	})
+	// the limit is inclusive
+	store.MountStoreWithDB(types.NewKVStoreKey("staking"), types.StoreTypeIAVL, nil)
+	require.PanicsWithValue(t, "cannot mount store mem, the number of stores exceeds the limit 3", func() {
+		store.MountStoreWithDB(types.NewMemoryStoreKey("mem"), types.StoreTypeMemory, nil)
+	})
+	require.Len(t, store.storeKeys, 3)
+}
+
+func TestProofAvailable(t *testing.T) {
+	key := types.NewKVStoreKey("bank")
+	store := newTestStore(t, false, key)
+
+	_, err := store.ProofAvailable(0)
+	require.Error(t, err)
+
+	for i := 0; i < 10; i++ {
+		store.GetKVStore(key).Set([]byte("a"), []byte{byte(i)})
+		store.Commit(true)
+	}
+	// only the initial snapshot is taken, all the versions are replayed from the changelog
+	for version := int64(1); version <= 10; version++ {
+		ok, err := store.ProofAvailable(version)
+		require.NoError(t, err)
+		require.True(t, ok)
+	}
+	ok, err := store.ProofAvailable(11)
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	// simulate the snapshots before version 5 being pruned
+	scDir := t.TempDir()
+	require.NoError(t, os.Mkdir(filepath.Join(scDir, fmt.Sprintf("snapshot-%020d", 5)), 0o755))
+	require.NoError(t, os.Mkdir(filepath.Join(scDir, fmt.Sprintf("snapshot-%020d", 8)), 0o755))
+	store.scDir = scDir
+	ok, err = store.ProofAvailable(4)
+	require.NoError(t, err)
+	require.False(t, ok)
+	ok, err = store.ProofAvailable(5)
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	res := store.Query(abci.RequestQuery{Path: "/bank/key", Data: []byte("a"), Height: 3, Prove: true})
+	require.False(t, res.IsOK())
+	require.Contains(t, res.Log, "proofs unavailable at height 3, available from 5")
+}
+
+func TestEarliestProvableVersion(t *testing.T) {
+	key := types.NewKVStoreKey("bank")
+	scConfig := config.StateCommitConfig{SnapshotInterval: 2}
+	store := NewStore(t.TempDir(), log.NewNopLogger(), scConfig, config.StateStoreConfig{}, WithKeepRecentProofs(6))
+	store.MountStoreWithDB(key, types.StoreTypeIAVL, nil)
+	require.NoError(t, store.LoadLatestVersion())
+	t.Cleanup(func() { require.NoError(t, store.Close()) })
+
+	for i := 1; i <= 21; i++ {
+		store.GetKVStore(key).Set([]byte("a"), []byte{byte(i)})
+		store.Commit(true)
+		if i%2 == 0 {
+			// a snapshot is skipped if the previous one is still being taken in background
+			require.Eventually(t, func() bool {
+				_, err := os.Stat(filepath.Join(store.scDir, fmt.Sprintf("snapshot-%020d", i)))
+				return err == nil
+			}, 5*time.Second, 10*time.Millisecond)
+		}
+	}
+	// the snapshots are pruned in background once the sc store switches to the snapshot 20,
+	// 3 snapshots are kept besides it to cover the 6 versions retained for the proofs
+	earliest := int64(20 - 6)
+	require.Eventually(t, func() bool {
+		version, err := store.EarliestProvableVersion()
+		require.NoError(t, err)
+		return version == earliest
+	}, 5*time.Second, 10*time.Millisecond)
+
+	res := store.Query(abci.RequestQuery{Path: "/bank/key", Data: []byte("a"), Height: earliest - 1, Prove: true})
+	require.False(t, res.IsOK())
+	require.Contains(t, res.Log, fmt.Sprintf("proofs unavailable at height %d, available from %d", earliest-1, earliest))
+}
+
+func TestSnapshotKeepRecentForProofs(t *testing.T) {
+	for _, tc := range []struct {
+		keepRecentProofs uint32
+		scConfig         config.StateCommitConfig
+		expected         uint32
+	}{
+		{0, config.StateCommitConfig{SnapshotKeepRecent: 1}, 1},
+		{10, config.StateCommitConfig{SnapshotInterval: 5}, 2},
+		{11, config.StateCommitConfig{SnapshotInterval: 5}, 3},
+		{11, config.StateCommitConfig{SnapshotInterval: 5, SnapshotKeepRecent: 4}, 4},
+		{config.DefaultSnapshotInterval + 1, config.StateCommitConfig{}, 2},
+		{math.MaxUint32, config.StateCommitConfig{SnapshotInterval: 2}, math.MaxUint32/2 + 1},
+	} {
+		require.Equal(t, tc.expected, snapshotKeepRecentForProofs(tc.keepRecentProofs, tc.scConfig))
+	}
+}
+
+func TestRollbackOutOfRange(t *testing.T) {
+	key := types.NewKVStoreKey("bank")
+	store := newTestStore(t, false, key)
+	for i := 0; i < 10; i++ {
+		store.GetKVStore(key).Set([]byte("a"), []byte{byte(i)})
+		store.Commit(true)
+	}
+
+	err := store.RollbackToVersion(11)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "above latest version 10")
+
+	// simulate the snapshots before version 5 being pruned
+	scDir := store.scDir
+	store.scDir = t.TempDir()
+	require.NoError(t, os.Mkdir(filepath.Join(store.scDir, fmt.Sprintf("snapshot-%020d", 5)), 0o755))
+	err = store.RollbackToVersion(4)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "below earliest retained version 5")
+	require.Equal(t, int64(10), store.LastCommitID().Version)
+
+	store.scDir = scDir
+	require.NoError(t, store.RollbackToVersion(4))
+}
+
+func TestStoreKeyCounts(t *testing.T) {
+	bank, acc := types.NewKVStoreKey("bank"), types.NewKVStoreKey("acc")
+	for _, ssEnabled := range []bool{true, false} {
+		store := newTestStore(t, ssEnabled, bank, acc)
+		for i := 0; i < 10; i++ {
+			store.GetKVStore(bank).Set([]byte(fmt.Sprintf("key-%d", i)), []byte("1"))
+		}
+		store.GetKVStore(acc).Set([]byte("a"), []byte("1"))
+		store.Commit(true)
+		store.GetKVStore(bank).Delete([]byte("key-0"))
+		store.GetKVStore(bank).Set([]byte("key-1"), []byte("2"))
+		store.Commit(true)
+		if ssEnabled {
+			waitForSS(t, store, 2)
+		}
+
+		counts, err := store.StoreKeyCounts()
+		require.NoError(t, err)
+		require.Equal(t, map[string]int64{"bank": 9, "acc": 1}, counts)
+	}
+}
+
+func TestHealthSSDivergence(t *testing.T) {
+	key := types.NewKVStoreKey("bank")
+	home := t.TempDir()
+	open := func(ssEnabled bool) *Store {
+		ssConfig := config.DefaultStateStoreConfig()
+		ssConfig.Enable = ssEnabled
+		// the store is closed right away, the pruning must not run concurrently
+		ssConfig.KeepRecent = 0
+		store := NewStore(home, log.NewNopLogger(), config.StateCommitConfig{}, ssConfig)
+		store.MountStoreWithDB(key, types.StoreTypeIAVL, nil)
+		require.NoError(t, store.LoadLatestVersion())
+		return store
+	}
+	commit := func(store *Store, n int) {
+		for i := 0; i < n; i++ {
+			store.GetKVStore(key).Set([]byte("a"), []byte{byte(i)})
+			store.Commit(true)
+		}
+	}
+
+	store := open(true)
+	commit(store, 3)
+	waitForSS(t, store, 3)
+	health, err := store.Health()
+	require.NoError(t, err)
+	require.Equal(t, Health{SCVersion: 3, SSVersion: 3}, health)
+	require.NoError(t, store.Close())
+
+	// the SS writes of the versions 4 and 5 are lost
+	store = open(false)
+	commit(store, 2)
+	require.NoError(t, store.Close())
+
+	store = open(true)
+	defer store.Close()
+	health, err = store.Health()
+	require.NoError(t, err)
+	require.Equal(t, Health{SCVersion: 5, SSVersion: 3, LoadSCVersion: 5, LoadSSVersion: 3, Diverged: true}, health)
+}
+
+func TestNoHistoryQueryError(t *testing.T) {
+	key := types.NewKVStoreKey("bank")
+	store := NewStore(t.TempDir(), log.NewNopLogger(), config.StateCommitConfig{}, config.StateStoreConfig{}, WithNoHistoryQueryError())
+	store.MountStoreWithDB(key, types.StoreTypeIAVL, nil)
+	require.NoError(t, store.LoadLatestVersion())
+	defer store.Close()
+	for i := 1; i <= 3; i++ {
+		store.GetKVStore(key).Set([]byte("a"), []byte{byte(i)})
+		store.Commit(true)
+	}
+
+	res := store.Query(abci.RequestQuery{Path: "/bank/key", Data: []byte("a"), Height: 2})
+	require.Equal(t, sdkerrors.ErrInvalidRequest.ABCICode(), res.Code)
+	require.Contains(t, res.Log, "node does not retain history")
+	// the latest version and the proofs are still served
+	res = store.Query(abci.RequestQuery{Path: "/bank/key", Data: []byte("a"), Height: 3})
+	require.True(t, res.IsOK(), res.Log)
+	require.Equal(t, []byte{3}, res.Value)
+	res = store.Query(abci.RequestQuery{Path: "/bank/key", Data: []byte("a"), Height: 2, Prove: true})
+	require.NotContains(t, res.Log, "node does not retain history")
+}
+
+func TestUpdatePruningConfig(t *testing.T) {
+	key := types.NewKVStoreKey("bank")
+	store := newTestStore(t, false, key)
+	require.ErrorIs(t, store.UpdatePruningConfig(10, 60), ErrStateStoreDisabled)
+
+	store = newTestStore(t, true, key)
+	require.Error(t, store.UpdatePruningConfig(-1, 60))
+	require.NoError(t, store.UpdatePruningConfig(100, 3600))
+	keepRecent, pruneInterval := store.pruningManager.Config()
+	require.Equal(t, int64(100), keepRecent)
+	require.Equal(t, int64(3600), pruneInterval)
+}
+
+func TestSetPruning(t *testing.T) {
+	key := types.NewKVStoreKey("bank")
+	store := newTestStore(t, false, key)
+	require.Equal(t, types.PruneDefault, store.GetPruning())
+	store.SetPruning(types.PruneEverything)
+	require.Equal(t, types.PruneEverything, store.GetPruning())
+
+	store = newTestStore(t, true, key)
+	ssKeepRecent, pruneInterval := store.pruningManager.Config()
+	require.Less(t, ssKeepRecent, int64(types.PruneDefault.KeepRecent))
+	for _, tc := range []struct {
+		opts            types.PruningOptions
+		keepRecent      int64
+		versionInterval int64
+	}{
+		{types.PruneNothing, 0, 0},
+		// the options can't retain less versions than the SS config
+		{types.PruneEverything, ssKeepRecent, 10},
+		{types.NewPruningOptions(uint64(ssKeepRecent)+1, 0, 20), ssKeepRecent + 1, 20},
+		// the default options don't override the SS config
+		{types.PruneDefault, ssKeepRecent, 0},
+	} {
+		store.SetPruning(tc.opts)
+		require.Equal(t, tc.opts, store.GetPruning())
+		keepRecent, interval := store.pruningManager.Config()
+		require.Equal(t, tc.keepRecent, keepRecent)
+		require.Equal(t, pruneInterval, interval)
+		require.Equal(t, tc.versionInterval, store.pruningManager.VersionInterval())
+	}
+
+	// a SS keep-recent smaller than the default options is kept
+	ssConfig := config.DefaultStateStoreConfig()
+	ssConfig.Enable = true
+	ssConfig.KeepRecent = 10
+	store = NewStore(t.TempDir(), log.NewNopLogger(), config.StateCommitConfig{}, ssConfig)
+	store.SetPruning(types.PruneDefault)
+	keepRecent, _ := store.pruningManager.Config()
+	require.Equal(t, int64(10), keepRecent)
+	store.SetPruning(types.NewPruningOptions(100, 0, 10))
+	keepRecent, _ = store.pruningManager.Config()
+	require.Equal(t, int64(100), keepRecent)
+}
+
+func TestParsePath(t *testing.T) {
+	testCases := []struct {
+		path      string
+		strict    bool
+		storeName string
+		subpath   string
+		expErr    bool
+	}{
+		{path: "/store", storeName: "store"},
+		{path: "/store/key", storeName: "store", subpath: "/key"},
+		{path: "/store/", storeName: "store"},
+		{path: "/store//sub", storeName: "store", subpath: "//sub"},
+		{path: "//", expErr: true},
+		{path: "store", expErr: true},
+		{path: "/store", strict: true, storeName: "store"},
+		{path: "/store/key", strict: true, storeName: "store", subpath: "/key"},
+		{path: "/store/", strict: true, expErr: true},
+		{path: "/store//sub", strict: true, expErr: true},
+		{path: "//", strict: true, expErr: true},
+	}
+	for _, tc := range testCases {
+		storeName, subpath, err := parsePath(tc.path, tc.strict)
+		if tc.expErr {
+			require.Error(t, err, tc.path)
+			continue
+		}
+		require.NoError(t, err, tc.path)
+		require.Equal(t, tc.storeName, storeName, tc.path)
+		require.Equal(t, tc.subpath, subpath, tc.path)
+	}
+}
+
+func TestQueryWorking(t *testing.T) {
+	key := types.NewKVStoreKey("bank")
+	store := newTestStore(t, false, key)
+	store.GetKVStore(key).Set([]byte("a"), []byte("1"))
+	store.Commit(true)
+
+	kvStore := store.GetKVStore(key)
+	kvStore.Set([]byte("a"), []byte("2"))
+	kvStore.Set([]byte("b"), []byte("2"))
+	value, err := store.QueryWorking("bank", []byte("a"))
+	require.NoError(t, err)
+	require.Equal(t, []byte("2"), value)
+	value, err = store.QueryWorking("bank", []byte("b"))
+	require.NoError(t, err)
+	require.Equal(t, []byte("2"), value)
+	// the committed state is unchanged
+	res := store.Query(abci.RequestQuery{Path: "/bank/key", Data: []byte("a")})
+	require.Equal(t, []byte("1"), res.Value)
+
+	// the flushed changes are still visible before commit
+	store.GetWorkingHash()
+	kvStore.Delete([]byte("b"))
+	value, err = store.QueryWorking("bank", []byte("a"))
+	require.NoError(t, err)
+	require.Equal(t, []byte("2"), value)
+	value, err = store.QueryWorking("bank", []byte("b"))
+	require.NoError(t, err)
+	require.Nil(t, value)
+
+	_, err = store.QueryWorking("staking", []byte("a"))
+	require.Error(t, err)
+}
+
+func TestIsPersistent(t *testing.T) {
+	store := NewStore(t.TempDir(), log.NewNopLogger(), config.StateCommitConfig{}, config.StateStoreConfig{})
+	store.MountStoreWithDB(types.NewKVStoreKey("bank"), types.StoreTypeIAVL, nil)
+	store.MountStoreWithDB(types.NewMemoryStoreKey("mem"), types.StoreTypeMemory, nil)
+	store.MountStoreWithDB(types.NewTransientStoreKey("transient"), types.StoreTypeTransient, nil)
+	require.NoError(t, store.LoadLatestVersion())
+	defer store.Close()
+
+	for name, expPersistent := range map[string]bool{"bank": true, "mem": false, "transient": false} {
+		persistent, err := store.IsPersistent(name)
+		require.NoError(t, err)
+		require.Equal(t, expPersistent, persistent, name)
+	}
+	_, err := store.IsPersistent("staking")
+	require.Error(t, err)
+}
+
+func TestStoreType(t *testing.T) {
+	store := NewStore(t.TempDir(), log.NewNopLogger(), config.StateCommitConfig{}, config.StateStoreConfig{})
+	require.Empty(t, store.MountedStoreNames())
+	store.MountStoreWithDB(types.NewTransientStoreKey("transient"), types.StoreTypeTransient, nil)
+	store.MountStoreWithDB(types.NewKVStoreKey("bank"), types.StoreTypeIAVL, nil)
+	store.MountStoreWithDB(types.NewMemoryStoreKey("mem"), types.StoreTypeMemory, nil)
+	require.NoError(t, store.LoadLatestVersion())
+	defer store.Close()
+
+	require.Equal(t, []string{"bank", "mem", "transient"}, store.MountedStoreNames())
+	for name, expType := range map[string]types.StoreType{"bank": types.StoreTypeIAVL, "mem": types.StoreTypeMemory, "transient": types.StoreTypeTransient} {
+		typ, ok := store.StoreType(name)
+		require.True(t, ok, name)
+		require.Equal(t, expType, typ, name)
+	}
+	_, ok := store.StoreType("staking")
+	require.False(t, ok)
+}
+
+// TestConcurrentStoreAccess reads the stores while loading and committing them, it's meant to be run with
+// the race detector.
+func TestConcurrentStoreAccess(t *testing.T) {
+	bank, mem := types.NewKVStoreKey("bank"), types.NewMemoryStoreKey("mem")
+	store := NewStore(t.TempDir(), log.NewNopLogger(), config.StateCommitConfig{}, config.StateStoreConfig{})
+	store.MountStoreWithDB(bank, types.StoreTypeIAVL, nil)
+	store.MountStoreWithDB(mem, types.StoreTypeMemory, nil)
+
+	stop, done := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			// the stores are not loaded yet at first
+			for _, key := range []types.StoreKey{bank, mem} {
+				store.GetKVStore(key)
+				store.GetStore(key)
+				store.GetCommitKVStore(key)
+				store.GetStoreByName(key.Name())
+			}
+			store.CacheMultiStore()
+		}
+	}()
+	require.NoError(t, store.LoadLatestVersion())
+	defer store.Close()
+	for i := 0; i < 50; i++ {
+		store.GetKVStore(bank).Set([]byte("a"), []byte{byte(i)})
+		store.Commit(true)
+	}
+	close(stop)
+	<-done
+	require.Equal(t, int64(50), store.LatestVersion())
+}
+
+func TestLatestStoreRoots(t *testing.T) {
+	bankKey, stakingKey := types.NewKVStoreKey("bank"), types.NewKVStoreKey("staking")
+	store := newTestStore(t, false, bankKey, stakingKey)
+	store.GetKVStore(bankKey).Set([]byte("a"), []byte("1"))
+	store.Commit(true)
+
+	roots := store.LatestStoreRoots()
+	require.Len(t, roots, len(store.lastCommitInfo.StoreInfos))
+	for _, storeInfo := range store.lastCommitInfo.StoreInfos {
+		require.Equal(t, storeInfo.CommitId.Hash, roots[storeInfo.Name])
+	}
+	require.NotEmpty(t, roots["bank"])
+	require.NotEqual(t, roots["bank"], roots["staking"])
+}
+
+func TestQueryNotInitialized(t *testing.T) {
+	key := types.NewKVStoreKey("bank")
+	store := NewStore(t.TempDir(), log.NewNopLogger(), config.StateCommitConfig{}, config.StateStoreConfig{})
+	store.MountStoreWithDB(key, types.StoreTypeIAVL, nil)
+	defer store.Close()
+
+	req := abci.RequestQuery{Path: "/bank/key", Data: []byte("a")}
+	// not loaded yet
+	res := store.Query(req)
+	require.Equal(t, sdkerrors.ErrInvalidRequest.ABCICode(), res.Code)
+	require.Contains(t, res.Log, "store is not loaded")
+	for _, height := range []int64{1, 2} {
+		for _, prove := range []bool{false, true} {
+			res := store.Query(abci.RequestQuery{Path: "/bank/key", Data: []byte("a"), Height: height, Prove: prove})
+			require.Equal(t, sdkerrors.ErrInvalidRequest.ABCICode(), res.Code)
+		}
+	}
+	require.Equal(t, QueryRouteLatestSC, store.QueryRouteFor(0, true))
+	_, err := store.MultiStorePrefixQuery(0, []string{"bank"}, nil)
+	require.Error(t, err)
+
+	// loaded at genesis but never committed, the latest version is served
+	require.NoError(t, store.LoadLatestVersion())
+	res = store.Query(req)
+	require.True(t, res.IsOK(), res.Log)
+	require.Nil(t, res.Value)
+	res = store.Query(abci.RequestQuery{Path: "/bank/key", Data: []byte("a"), Height: 1})
+	require.Equal(t, sdkerrors.ErrInvalidHeight.ABCICode(), res.Code)
+	require.Contains(t, res.Log, "height 1 is above the latest version 0")
+	kvs, err := store.MultiStorePrefixQuery(0, []string{"bank"}, nil)
+	require.NoError(t, err)
+	require.Empty(t, kvs["bank"])
+
+	store.GetKVStore(key).Set([]byte("a"), []byte("1"))
+	store.Commit(true)
+	res = store.Query(req)
+	require.True(t, res.IsOK(), res.Log)
+	require.Equal(t, []byte("1"), res.Value)
+	res = store.Query(abci.RequestQuery{Path: "/bank/key", Data: []byte("a"), Height: 2})
+	require.Equal(t, sdkerrors.ErrInvalidHeight.ABCICode(), res.Code)
+}
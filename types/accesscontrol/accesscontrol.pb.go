@@ -27,6 +27,8 @@ type AccessOperation struct {
 	AccessType         AccessType   `protobuf:"varint,1,opt,name=access_type,json=accessType,proto3,enum=cosmos.accesscontrol.v1beta1.AccessType" json:"access_type,omitempty"`
 	ResourceType       ResourceType `protobuf:"varint,2,opt,name=resource_type,json=resourceType,proto3,enum=cosmos.accesscontrol.v1beta1.ResourceType" json:"resource_type,omitempty"`
 	IdentifierTemplate string       `protobuf:"bytes,3,opt,name=identifier_template,json=identifierTemplate,proto3" json:"identifier_template,omitempty"`
+	// selector derives the identifiers from the message fields, it's mutually exclusive with identifier_template.
+	Selector *FieldSelector `protobuf:"bytes,4,opt,name=selector,proto3" json:"selector,omitempty"`
 }
 
 func (m *AccessOperation) Reset()         { *m = AccessOperation{} }
@@ -83,6 +85,59 @@ func (m *AccessOperation) GetIdentifierTemplate() string {
 	return ""
 }
 
+func (m *AccessOperation) GetSelector() *FieldSelector {
+	if m != nil {
+		return m.Selector
+	}
+	return nil
+}
+
+// FieldSelector derives the identifiers of an access operation from a message using the named field extractors,
+// each extractor can produce multiple identifiers, e.g. the sender's account and all its delegations.
+type FieldSelector struct {
+	Extractors []string `protobuf:"bytes,1,rep,name=extractors,proto3" json:"extractors,omitempty"`
+}
+
+func (m *FieldSelector) Reset()         { *m = FieldSelector{} }
+func (m *FieldSelector) String() string { return proto.CompactTextString(m) }
+func (*FieldSelector) ProtoMessage()    {}
+func (*FieldSelector) Descriptor() ([]byte, []int) {
+	return fileDescriptor_d636a082612ba091, []int{1}
+}
+func (m *FieldSelector) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *FieldSelector) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_FieldSelector.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *FieldSelector) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_FieldSelector.Merge(m, src)
+}
+func (m *FieldSelector) XXX_Size() int {
+	return m.Size()
+}
+func (m *FieldSelector) XXX_DiscardUnknown() {
+	xxx_messageInfo_FieldSelector.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_FieldSelector proto.InternalMessageInfo
+
+func (m *FieldSelector) GetExtractors() []string {
+	if m != nil {
+		return m.Extractors
+	}
+	return nil
+}
+
 type WasmAccessOperation struct {
 	Operation    *AccessOperation            `protobuf:"bytes,1,opt,name=operation,proto3" json:"operation,omitempty"`
 	SelectorType AccessOperationSelectorType `protobuf:"varint,2,opt,name=selector_type,json=selectorType,proto3,enum=cosmos.accesscontrol.v1beta1.AccessOperationSelectorType" json:"selector_type,omitempty"`
@@ -93,7 +148,7 @@ func (m *WasmAccessOperation) Reset()         { *m = WasmAccessOperation{} }
 func (m *WasmAccessOperation) String() string { return proto.CompactTextString(m) }
 func (*WasmAccessOperation) ProtoMessage()    {}
 func (*WasmAccessOperation) Descriptor() ([]byte, []int) {
-	return fileDescriptor_d636a082612ba091, []int{1}
+	return fileDescriptor_d636a082612ba091, []int{2}
 }
 func (m *WasmAccessOperation) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -154,7 +209,7 @@ func (m *WasmContractReference) Reset()         { *m = WasmContractReference{} }
 func (m *WasmContractReference) String() string { return proto.CompactTextString(m) }
 func (*WasmContractReference) ProtoMessage()    {}
 func (*WasmContractReference) Descriptor() ([]byte, []int) {
-	return fileDescriptor_d636a082612ba091, []int{2}
+	return fileDescriptor_d636a082612ba091, []int{3}
 }
 func (m *WasmContractReference) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -220,7 +275,7 @@ func (m *WasmContractReferences) Reset()         { *m = WasmContractReferences{}
 func (m *WasmContractReferences) String() string { return proto.CompactTextString(m) }
 func (*WasmContractReferences) ProtoMessage()    {}
 func (*WasmContractReferences) Descriptor() ([]byte, []int) {
-	return fileDescriptor_d636a082612ba091, []int{3}
+	return fileDescriptor_d636a082612ba091, []int{4}
 }
 func (m *WasmContractReferences) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -272,7 +327,7 @@ func (m *WasmAccessOperations) Reset()         { *m = WasmAccessOperations{} }
 func (m *WasmAccessOperations) String() string { return proto.CompactTextString(m) }
 func (*WasmAccessOperations) ProtoMessage()    {}
 func (*WasmAccessOperations) Descriptor() ([]byte, []int) {
-	return fileDescriptor_d636a082612ba091, []int{4}
+	return fileDescriptor_d636a082612ba091, []int{5}
 }
 func (m *WasmAccessOperations) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -325,7 +380,7 @@ func (m *MessageDependencyMapping) Reset()         { *m = MessageDependencyMappi
 func (m *MessageDependencyMapping) String() string { return proto.CompactTextString(m) }
 func (*MessageDependencyMapping) ProtoMessage()    {}
 func (*MessageDependencyMapping) Descriptor() ([]byte, []int) {
-	return fileDescriptor_d636a082612ba091, []int{5}
+	return fileDescriptor_d636a082612ba091, []int{6}
 }
 func (m *MessageDependencyMapping) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -390,7 +445,7 @@ func (m *WasmDependencyMapping) Reset()         { *m = WasmDependencyMapping{} }
 func (m *WasmDependencyMapping) String() string { return proto.CompactTextString(m) }
 func (*WasmDependencyMapping) ProtoMessage()    {}
 func (*WasmDependencyMapping) Descriptor() ([]byte, []int) {
-	return fileDescriptor_d636a082612ba091, []int{6}
+	return fileDescriptor_d636a082612ba091, []int{7}
 }
 func (m *WasmDependencyMapping) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -477,6 +532,7 @@ func (m *WasmDependencyMapping) GetContractAddress() string {
 
 func init() {
 	proto.RegisterType((*AccessOperation)(nil), "cosmos.accesscontrol.v1beta1.AccessOperation")
+	proto.RegisterType((*FieldSelector)(nil), "cosmos.accesscontrol.v1beta1.FieldSelector")
 	proto.RegisterType((*WasmAccessOperation)(nil), "cosmos.accesscontrol.v1beta1.WasmAccessOperation")
 	proto.RegisterType((*WasmContractReference)(nil), "cosmos.accesscontrol.v1beta1.WasmContractReference")
 	proto.RegisterType((*WasmContractReferences)(nil), "cosmos.accesscontrol.v1beta1.WasmContractReferences")
@@ -490,53 +546,56 @@ func init() {
 }
 
 var fileDescriptor_d636a082612ba091 = []byte{
-	// 734 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xa4, 0x55, 0xdd, 0x4e, 0x13, 0x4f,
-	0x14, 0xef, 0xf2, 0xf5, 0xa7, 0x53, 0x68, 0xc9, 0xc0, 0x1f, 0x0a, 0x9a, 0x82, 0x8d, 0x89, 0xd5,
-	0x84, 0x16, 0x8a, 0x37, 0x7a, 0x07, 0xea, 0x85, 0x12, 0x24, 0x59, 0x48, 0x8c, 0xc4, 0xb8, 0x4e,
-	0x67, 0x0f, 0xb5, 0xd2, 0x9d, 0x59, 0x77, 0xa6, 0x6a, 0xdf, 0xc2, 0x1b, 0x1f, 0xc0, 0xb7, 0xf0,
-	0x11, 0xb8, 0xe4, 0x4e, 0xaf, 0x88, 0x81, 0x77, 0xf0, 0xda, 0xec, 0xcc, 0xec, 0x6e, 0x4b, 0x37,
-	0x7c, 0xd4, 0xab, 0x76, 0xce, 0x9c, 0xf3, 0xfb, 0x38, 0x73, 0x66, 0x16, 0x55, 0x28, 0x17, 0x1e,
-	0x17, 0x35, 0x42, 0x29, 0x08, 0x41, 0x39, 0x93, 0x01, 0x6f, 0xf7, 0xaf, 0xaa, 0x7e, 0xc0, 0x25,
-	0xc7, 0xb7, 0x75, 0x66, 0xb5, 0x7f, 0xef, 0xd3, 0x7a, 0x03, 0x24, 0x59, 0x5f, 0x9a, 0x6b, 0xf2,
-	0x26, 0x57, 0x89, 0xb5, 0xf0, 0x9f, 0xae, 0x59, 0xba, 0x9b, 0x8a, 0x4e, 0x39, 0x13, 0x92, 0x30,
-	0x29, 0x74, 0x56, 0xf9, 0xd4, 0x42, 0x85, 0x4d, 0x95, 0xb1, 0xeb, 0x43, 0x40, 0x64, 0x8b, 0x33,
-	0xfc, 0x1c, 0xe5, 0x74, 0x91, 0x23, 0xbb, 0x3e, 0x14, 0xad, 0x15, 0xab, 0x92, 0xaf, 0x57, 0xaa,
-	0x97, 0x69, 0xa8, 0x6a, 0x8c, 0xfd, 0xae, 0x0f, 0x36, 0x22, 0xf1, 0x7f, 0xbc, 0x8b, 0xa6, 0x03,
-	0x10, 0xbc, 0x13, 0x50, 0xd0, 0x60, 0x23, 0x0a, 0xec, 0xc1, 0xe5, 0x60, 0xb6, 0x29, 0x51, 0x70,
-	0x53, 0x41, 0xcf, 0x0a, 0xd7, 0xd0, 0x6c, 0xcb, 0x05, 0x26, 0x5b, 0x87, 0x2d, 0x08, 0x1c, 0x09,
-	0x9e, 0xdf, 0x26, 0x12, 0x8a, 0xa3, 0x2b, 0x56, 0x25, 0x6b, 0xe3, 0x64, 0x6b, 0xdf, 0xec, 0x84,
-	0x06, 0x67, 0x5f, 0x11, 0xe1, 0x5d, 0x34, 0xb9, 0x8d, 0xb2, 0x3c, 0x5a, 0x28, 0x8b, 0xb9, 0xfa,
-	0xea, 0x75, 0x2c, 0xc6, 0x08, 0x76, 0x52, 0x8f, 0xdf, 0xa2, 0x69, 0x01, 0x6d, 0xa0, 0x92, 0x07,
-	0xbd, 0x36, 0x1f, 0xdd, 0x08, 0x70, 0xcf, 0x20, 0x68, 0xd7, 0xa2, 0x67, 0x85, 0x97, 0xd0, 0x64,
-	0xb4, 0x36, 0x56, 0xe3, 0x75, 0xf9, 0x8f, 0x85, 0xfe, 0x0f, 0x0d, 0x3e, 0x09, 0xd1, 0x09, 0x95,
-	0x36, 0x1c, 0x42, 0x00, 0x8c, 0x02, 0xbe, 0x8f, 0x66, 0xa8, 0x09, 0x3a, 0xc4, 0x75, 0x03, 0x10,
-	0x42, 0x39, 0xcd, 0xda, 0x85, 0x28, 0xbe, 0xa9, 0xc3, 0x78, 0x0f, 0x4d, 0x79, 0x20, 0x04, 0x69,
-	0xf6, 0x1d, 0xd3, 0xda, 0xe5, 0xfa, 0x43, 0xd6, 0x1d, 0x5d, 0xb5, 0xd7, 0x69, 0x84, 0x75, 0x76,
-	0xce, 0xa0, 0x28, 0xd5, 0x77, 0x12, 0x50, 0x46, 0xbc, 0xe8, 0x90, 0xa2, 0x94, 0x97, 0xc4, 0x03,
-	0xfc, 0x18, 0x2d, 0x7e, 0x10, 0x9c, 0x39, 0x32, 0x20, 0x4c, 0xb4, 0x55, 0x1b, 0x92, 0x43, 0x1d,
-	0x53, 0xf9, 0x0b, 0x61, 0xc2, 0x7e, 0xb2, 0x1f, 0x9f, 0xec, 0x77, 0x0b, 0xcd, 0xa7, 0x1a, 0x17,
-	0x03, 0xcc, 0xd6, 0x20, 0xb3, 0x8b, 0x66, 0xe3, 0xe6, 0x04, 0x71, 0x65, 0x71, 0x64, 0x65, 0xb4,
-	0x92, 0xab, 0x6f, 0x5c, 0x6d, 0x7c, 0x80, 0xd5, 0xc6, 0x74, 0x40, 0x48, 0xf9, 0x9b, 0x85, 0xe6,
-	0x52, 0xa6, 0xef, 0x5a, 0x0a, 0x0f, 0x50, 0xe1, 0x33, 0x11, 0x9e, 0x13, 0x8f, 0x59, 0xa4, 0x6e,
-	0xfd, 0x6a, 0x75, 0x17, 0x67, 0x35, 0x1f, 0x22, 0x25, 0xf4, 0xe5, 0x1f, 0x16, 0x2a, 0x9a, 0xa3,
-	0x7b, 0x0a, 0x3e, 0x30, 0x17, 0x18, 0xed, 0xee, 0x10, 0xdf, 0x6f, 0xb1, 0x26, 0x5e, 0x46, 0x91,
-	0x0e, 0xe7, 0x08, 0xba, 0x46, 0x1a, 0x32, 0xa1, 0x6d, 0xe8, 0x62, 0x1b, 0x99, 0x3b, 0xee, 0x70,
-	0x3f, 0x12, 0x75, 0xb3, 0xcb, 0xb3, 0x35, 0x76, 0x7c, 0xba, 0x9c, 0xb1, 0xb3, 0xc4, 0x84, 0x05,
-	0xbe, 0x87, 0x0a, 0x6e, 0x97, 0x11, 0xaf, 0x45, 0x1d, 0x60, 0xa4, 0xd1, 0x06, 0x57, 0xcd, 0xcb,
-	0xa4, 0x9d, 0x37, 0xe1, 0x67, 0x3a, 0x5a, 0xfe, 0x39, 0xae, 0xe7, 0x7d, 0x50, 0xf7, 0x6b, 0x54,
-	0x68, 0x10, 0x01, 0x4e, 0x8f, 0x36, 0x6b, 0xd8, 0x86, 0x4d, 0x87, 0x48, 0x9b, 0xb1, 0xba, 0x37,
-	0x68, 0xe6, 0x63, 0x07, 0x82, 0xae, 0x33, 0xe0, 0xbb, 0x7e, 0x63, 0x6c, 0x61, 0xe7, 0x15, 0x56,
-	0x82, 0xfe, 0x0e, 0x61, 0xf8, 0x02, 0xb4, 0x23, 0xfb, 0xb4, 0x8f, 0x0e, 0x8d, 0x3f, 0x63, 0xd0,
-	0x12, 0x06, 0x0f, 0x15, 0x55, 0x6b, 0xd2, 0x46, 0x7e, 0x6c, 0xf8, 0x91, 0x9f, 0x0f, 0x41, 0x53,
-	0xee, 0x9f, 0x8f, 0x16, 0x75, 0xbb, 0xd2, 0xf8, 0xc6, 0x15, 0xdf, 0xc3, 0x21, 0xf8, 0x84, 0xbd,
-	0xa0, 0x60, 0x53, 0x18, 0x25, 0xba, 0x15, 0xb5, 0x30, 0x8d, 0x73, 0xe2, 0x1f, 0x38, 0x17, 0x0d,
-	0x70, 0xfa, 0x3b, 0x13, 0x80, 0x80, 0x90, 0x8a, 0x08, 0xce, 0x8a, 0xff, 0xe9, 0x5b, 0xac, 0x62,
-	0xb6, 0x0a, 0xa5, 0x3e, 0xc2, 0x93, 0xa9, 0x8f, 0xf0, 0xd6, 0x8b, 0xe3, 0xb3, 0x92, 0x75, 0x72,
-	0x56, 0xb2, 0x7e, 0x9f, 0x95, 0xac, 0xaf, 0xe7, 0xa5, 0xcc, 0xc9, 0x79, 0x29, 0xf3, 0xeb, 0xbc,
-	0x94, 0x39, 0x58, 0x6b, 0xb6, 0xe4, 0xfb, 0x4e, 0xa3, 0x4a, 0xb9, 0x57, 0x33, 0x9f, 0x75, 0xfd,
-	0xb3, 0x2a, 0xdc, 0xa3, 0x5a, 0xf8, 0xf2, 0x5e, 0xf8, 0xce, 0x37, 0x26, 0xd4, 0xe7, 0x7d, 0xe3,
-	0x6f, 0x00, 0x00, 0x00, 0xff, 0xff, 0x16, 0x13, 0xf6, 0x91, 0x64, 0x08, 0x00, 0x00,
+	// 783 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xa4, 0x56, 0xcd, 0x4e, 0xeb, 0x46,
+	0x14, 0x8e, 0x49, 0xa0, 0xe4, 0x84, 0x24, 0x68, 0xa0, 0x60, 0x68, 0x15, 0xd2, 0xa8, 0x52, 0xd3,
+	0x56, 0x24, 0x10, 0xba, 0x69, 0x77, 0xd0, 0x3f, 0xb5, 0x88, 0x22, 0x0d, 0x48, 0x55, 0x51, 0x55,
+	0x77, 0x62, 0x1f, 0x52, 0x97, 0xd8, 0xe3, 0x7a, 0x26, 0x2d, 0x79, 0x8b, 0x6e, 0xfa, 0x00, 0x5d,
+	0xf5, 0x15, 0xfa, 0x08, 0x2c, 0xd9, 0xdd, 0xbb, 0x42, 0x57, 0xf0, 0x0e, 0x77, 0x7d, 0xe5, 0xf1,
+	0x5f, 0x7e, 0xac, 0x00, 0xb9, 0xab, 0xf8, 0x9c, 0x39, 0xe7, 0xfb, 0xbe, 0xf3, 0xe5, 0x78, 0x64,
+	0x68, 0x9a, 0x5c, 0x38, 0x5c, 0xb4, 0x99, 0x69, 0xa2, 0x10, 0x26, 0x77, 0xa5, 0xcf, 0xfb, 0xe3,
+	0x51, 0xcb, 0xf3, 0xb9, 0xe4, 0xe4, 0xfd, 0xb0, 0xb2, 0x35, 0x7e, 0xf6, 0xe7, 0x7e, 0x17, 0x25,
+	0xdb, 0xdf, 0x5e, 0xef, 0xf1, 0x1e, 0x57, 0x85, 0xed, 0xe0, 0x29, 0xec, 0xd9, 0xfe, 0x30, 0x13,
+	0xdd, 0xe4, 0xae, 0x90, 0xcc, 0x95, 0x22, 0xac, 0x6a, 0xfc, 0xb7, 0x00, 0xd5, 0x43, 0x55, 0x71,
+	0xea, 0xa1, 0xcf, 0xa4, 0xcd, 0x5d, 0xf2, 0x1d, 0x94, 0xc2, 0x26, 0x43, 0x0e, 0x3d, 0xd4, 0xb5,
+	0xba, 0xd6, 0xac, 0x74, 0x9a, 0xad, 0x59, 0x1a, 0x5a, 0x21, 0xc6, 0xf9, 0xd0, 0x43, 0x0a, 0x2c,
+	0x79, 0x26, 0xa7, 0x50, 0xf6, 0x51, 0xf0, 0x81, 0x6f, 0x62, 0x08, 0xb6, 0xa0, 0xc0, 0x3e, 0x99,
+	0x0d, 0x46, 0xa3, 0x16, 0x05, 0xb7, 0xe2, 0x8f, 0x44, 0xa4, 0x0d, 0x6b, 0xb6, 0x85, 0xae, 0xb4,
+	0x2f, 0x6d, 0xf4, 0x0d, 0x89, 0x8e, 0xd7, 0x67, 0x12, 0xf5, 0x7c, 0x5d, 0x6b, 0x16, 0x29, 0x49,
+	0x8f, 0xce, 0xa3, 0x13, 0xf2, 0x2d, 0x2c, 0x0b, 0xec, 0xa3, 0x29, 0xb9, 0xaf, 0x17, 0xea, 0x5a,
+	0xb3, 0xd4, 0xf9, 0x74, 0x36, 0xf9, 0x37, 0x36, 0xf6, 0xad, 0xb3, 0xa8, 0x85, 0x26, 0xcd, 0x8d,
+	0x36, 0x94, 0xc7, 0x8e, 0x48, 0x0d, 0x00, 0xaf, 0xa5, 0xcf, 0x82, 0x40, 0xe8, 0x5a, 0x3d, 0xdf,
+	0x2c, 0xd2, 0x91, 0x4c, 0xe3, 0x4e, 0x83, 0xb5, 0x1f, 0x99, 0x70, 0x26, 0xed, 0x3d, 0x86, 0x22,
+	0x8f, 0x03, 0x65, 0x6e, 0xa9, 0xb3, 0xfb, 0x14, 0x73, 0x13, 0x04, 0x9a, 0xf6, 0x93, 0x5f, 0xa0,
+	0x1c, 0x2b, 0x1c, 0x35, 0xf8, 0xf3, 0x67, 0x01, 0xc6, 0x23, 0x85, 0x7e, 0x8b, 0x91, 0x88, 0x6c,
+	0x8f, 0xd8, 0x17, 0x9a, 0x9c, 0x3a, 0xf2, 0x5a, 0x83, 0x77, 0x83, 0x01, 0xbf, 0x0c, 0xd0, 0x99,
+	0x29, 0x29, 0x5e, 0xa2, 0x8f, 0xae, 0x89, 0xe4, 0x63, 0x58, 0x35, 0xa3, 0xa4, 0xc1, 0x2c, 0xcb,
+	0x47, 0x21, 0xd4, 0xa4, 0x45, 0x5a, 0x8d, 0xf3, 0x87, 0x61, 0x9a, 0x9c, 0xc1, 0x8a, 0x83, 0x42,
+	0xb0, 0xde, 0xd8, 0x82, 0xec, 0xcd, 0xd6, 0x1f, 0xb0, 0x9e, 0x84, 0x5d, 0x67, 0x83, 0x6e, 0xd0,
+	0x47, 0x4b, 0x11, 0x8a, 0x52, 0xfd, 0x41, 0x0a, 0xea, 0x32, 0x27, 0x5e, 0x8f, 0xb8, 0xe4, 0x07,
+	0xe6, 0x20, 0xf9, 0x02, 0xb6, 0x7e, 0x17, 0xdc, 0x35, 0xa4, 0xcf, 0x5c, 0xd1, 0x57, 0x36, 0xa4,
+	0xeb, 0x54, 0x50, 0xf5, 0x9b, 0x41, 0xc1, 0x79, 0x7a, 0x1e, 0xef, 0x54, 0xe3, 0x5f, 0x0d, 0x36,
+	0x32, 0x07, 0x17, 0x53, 0xcc, 0xda, 0x34, 0xb3, 0x05, 0x6b, 0x89, 0x39, 0x7e, 0xd2, 0xa9, 0x2f,
+	0xd4, 0xf3, 0xcd, 0x52, 0xe7, 0xe0, 0xf1, 0xc1, 0xa7, 0x58, 0x29, 0x31, 0x27, 0x53, 0xa2, 0xf1,
+	0x8f, 0x06, 0xeb, 0x19, 0xdb, 0xf7, 0x24, 0x85, 0x17, 0x50, 0xfd, 0x8b, 0x09, 0xc7, 0x48, 0xd6,
+	0x2c, 0x56, 0xb7, 0xff, 0xb8, 0xba, 0xc9, 0x5d, 0xad, 0x04, 0x48, 0x49, 0x28, 0x1a, 0xff, 0x6b,
+	0xa0, 0x47, 0x7f, 0xdd, 0x57, 0xe8, 0xa1, 0x6b, 0xa1, 0x6b, 0x0e, 0x4f, 0x98, 0xe7, 0xd9, 0x6e,
+	0x8f, 0xec, 0x40, 0xac, 0xc3, 0xb8, 0xc2, 0x61, 0x24, 0x0d, 0xa2, 0xd4, 0x31, 0x0e, 0x09, 0x85,
+	0xe8, 0x76, 0x31, 0xb8, 0x17, 0x8b, 0x7a, 0xde, 0xcb, 0x73, 0x54, 0xb8, 0xb9, 0xdb, 0xc9, 0xd1,
+	0x22, 0x8b, 0xd2, 0x82, 0x7c, 0x04, 0x55, 0x6b, 0xe8, 0x32, 0xc7, 0x36, 0x0d, 0x74, 0x59, 0xb7,
+	0x8f, 0x96, 0xda, 0x97, 0x65, 0x5a, 0x89, 0xd2, 0x5f, 0x87, 0xd9, 0xc6, 0x8b, 0xc5, 0x70, 0xdf,
+	0xa7, 0x75, 0xff, 0x04, 0xd5, 0x2e, 0x13, 0x68, 0x8c, 0x68, 0xd3, 0xe6, 0x35, 0xac, 0x1c, 0x20,
+	0x1d, 0x26, 0xea, 0x7e, 0x86, 0xd5, 0x3f, 0x06, 0xe8, 0x0f, 0x8d, 0xa9, 0xb9, 0x3b, 0xcf, 0xc6,
+	0x16, 0xb4, 0xa2, 0xb0, 0x52, 0xf4, 0x5f, 0x81, 0xe0, 0x35, 0x9a, 0x03, 0x39, 0xa6, 0x3d, 0x3f,
+	0x37, 0xfe, 0x6a, 0x84, 0x96, 0x32, 0x38, 0xa0, 0x2b, 0x6b, 0xb2, 0x56, 0xbe, 0x30, 0xff, 0xca,
+	0x6f, 0x04, 0xa0, 0x19, 0xef, 0x9f, 0x07, 0x5b, 0xa1, 0x5d, 0x59, 0x7c, 0x8b, 0x8a, 0xef, 0xb3,
+	0x39, 0xf8, 0x04, 0xdd, 0x54, 0xb0, 0x19, 0x8c, 0x12, 0xde, 0x8b, 0x2d, 0xcc, 0xe2, 0x5c, 0x7a,
+	0x0b, 0xce, 0xad, 0x08, 0x38, 0xfb, 0x9e, 0xf1, 0x51, 0x60, 0x40, 0xc5, 0x04, 0x77, 0xf5, 0x77,
+	0xc2, 0xb7, 0x58, 0xe5, 0xa8, 0x4a, 0x65, 0x5e, 0xc2, 0xcb, 0x99, 0x97, 0xf0, 0xd1, 0xf7, 0x37,
+	0xf7, 0x35, 0xed, 0xf6, 0xbe, 0xa6, 0xbd, 0xba, 0xaf, 0x69, 0x7f, 0x3f, 0xd4, 0x72, 0xb7, 0x0f,
+	0xb5, 0xdc, 0xcb, 0x87, 0x5a, 0xee, 0x62, 0xaf, 0x67, 0xcb, 0xdf, 0x06, 0xdd, 0x96, 0xc9, 0x9d,
+	0x76, 0xf4, 0x41, 0x11, 0xfe, 0xec, 0x0a, 0xeb, 0xaa, 0x1d, 0xdc, 0xbc, 0x13, 0x5f, 0x18, 0xdd,
+	0x25, 0xf5, 0x61, 0x71, 0xf0, 0x66, 0x00, 0x2e, 0xba, 0xcb, 0xee, 0xde, 0x08, 0x00, 0x00,
 }
 
 func (m *AccessOperation) Marshal() (dAtA []byte, err error) {
@@ -559,6 +618,18 @@ func (m *AccessOperation) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	_ = i
 	var l int
 	_ = l
+	if m.Selector != nil {
+		{
+			size, err := m.Selector.MarshalToSizedBuffer(dAtA[:i])
+			if err != nil {
+				return 0, err
+			}
+			i -= size
+			i = encodeVarintAccesscontrol(dAtA, i, uint64(size))
+		}
+		i--
+		dAtA[i] = 0x22
+	}
 	if len(m.IdentifierTemplate) > 0 {
 		i -= len(m.IdentifierTemplate)
 		copy(dAtA[i:], m.IdentifierTemplate)
@@ -579,6 +650,38 @@ func (m *AccessOperation) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	return len(dAtA) - i, nil
 }
 
+func (m *FieldSelector) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *FieldSelector) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *FieldSelector) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if len(m.Extractors) > 0 {
+		for iNdEx := len(m.Extractors) - 1; iNdEx >= 0; iNdEx-- {
+			i -= len(m.Extractors[iNdEx])
+			copy(dAtA[i:], m.Extractors[iNdEx])
+			i = encodeVarintAccesscontrol(dAtA, i, uint64(len(m.Extractors[iNdEx])))
+			i--
+			dAtA[i] = 0xa
+		}
+	}
+	return len(dAtA) - i, nil
+}
+
 func (m *WasmAccessOperation) Marshal() (dAtA []byte, err error) {
 	size := m.Size()
 	dAtA = make([]byte, size)
@@ -965,6 +1068,25 @@ func (m *AccessOperation) Size() (n int) {
 	if l > 0 {
 		n += 1 + l + sovAccesscontrol(uint64(l))
 	}
+	if m.Selector != nil {
+		l = m.Selector.Size()
+		n += 1 + l + sovAccesscontrol(uint64(l))
+	}
+	return n
+}
+
+func (m *FieldSelector) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if len(m.Extractors) > 0 {
+		for _, s := range m.Extractors {
+			l = len(s)
+			n += 1 + l + sovAccesscontrol(uint64(l))
+		}
+	}
 	return n
 }
 
@@ -1230,6 +1352,124 @@ func (m *AccessOperation) Unmarshal(dAtA []byte) error {
 			}
 			m.IdentifierTemplate = string(dAtA[iNdEx:postIndex])
 			iNdEx = postIndex
+		case 4:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Selector", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowAccesscontrol
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthAccesscontrol
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthAccesscontrol
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.Selector == nil {
+				m.Selector = &FieldSelector{}
+			}
+			if err := m.Selector.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipAccesscontrol(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if (skippy < 0) || (iNdEx+skippy) < 0 {
+				return ErrInvalidLengthAccesscontrol
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *FieldSelector) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowAccesscontrol
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: FieldSelector: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: FieldSelector: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Extractors", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowAccesscontrol
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthAccesscontrol
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthAccesscontrol
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Extractors = append(m.Extractors, string(dAtA[iNdEx:postIndex]))
+			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
 			skippy, err := skipAccesscontrol(dAtA[iNdEx:])
@@ -38,8 +38,10 @@ type (
 		storeKey                         sdk.StoreKey
 		paramSpace                       paramtypes.Subspace
 		MessageDependencyGeneratorMapper DependencyGeneratorMap
-		AccountKeeper                    authkeeper.AccountKeeper
-		StakingKeeper                    stakingkeeper.Keeper
+		// FieldExtractors resolve the selectors of the access operations of the message dependency mappings.
+		FieldExtractors types.FieldExtractors
+		AccountKeeper   authkeeper.AccountKeeper
+		StakingKeeper   stakingkeeper.Keeper
 	}
 )
 
@@ -94,6 +96,9 @@ func (k Keeper) SetResourceDependencyMapping(
 	if err != nil {
 		return err
 	}
+	if err := k.FieldExtractors.ValidateAccessOps(dependencyMapping.AccessOps); err != nil {
+		return err
+	}
 	store := ctx.KVStore(k.storeKey)
 	b := k.cdc.MustMarshal(&dependencyMapping)
 	resourceKey := types.GetResourceDependencyKey(types.MessageKey(dependencyMapping.GetMessageKey()))
@@ -572,13 +577,24 @@ func (k Keeper) GetMessageDependencies(ctx sdk.Context, msg sdk.Msg) []acltypes.
 			// validate the access ops before using them
 			validateErr := types.ValidateAccessOps(dependencies)
 			if validateErr == nil {
-				return dependencies
+				return k.resolveAccessOps(ctx, messageKey, dependencies, msg)
 			}
 			errorMessage := fmt.Sprintf("Invalid Access Ops for message=%s. %s", messageKey, validateErr.Error())
 			ctx.Logger().Error(errorMessage)
 		}
 	}
-	return dependencyMapping.AccessOps
+	return k.resolveAccessOps(ctx, messageKey, dependencyMapping.AccessOps, msg)
+}
+
+// resolveAccessOps derives the identifiers of the access ops using a selector, and falls back to
+// the synchronous access ops if they can't be derived from the message.
+func (k Keeper) resolveAccessOps(ctx sdk.Context, messageKey types.MessageKey, accessOps []acltypes.AccessOperation, msg sdk.Msg) []acltypes.AccessOperation {
+	resolved, err := k.FieldExtractors.ResolveAccessOps(accessOps, msg)
+	if err != nil {
+		ctx.Logger().Error(fmt.Sprintf("Failed to resolve Access Ops selectors for message=%s. %s", messageKey, err.Error()))
+		return types.SynchronousAccessOps()
+	}
+	return resolved
 }
 
 func DefaultMessageDependencyGenerator() DependencyGeneratorMap {
@@ -94,6 +94,33 @@ func TestResourceDependencyMapping(t *testing.T) {
 	require.Equal(t, 1, counter)
 }
 
+func TestResourceDependencyMappingFieldExtractors(t *testing.T) {
+	app := simapp.Setup(false)
+	ctx := app.BaseApp.NewContext(false, tmproto.Header{})
+
+	mapping := acltypes.MessageDependencyMapping{
+		MessageKey: "selectorKey",
+		AccessOps: []acltypes.AccessOperation{
+			{
+				ResourceType: acltypes.ResourceType_KV_BANK_BALANCES,
+				AccessType:   acltypes.AccessType_WRITE,
+				Selector:     &acltypes.FieldSelector{Extractors: []string{"send_sender"}},
+			},
+			*types.CommitAccessOp(),
+		},
+	}
+	// the selectors can only reference the field extractors of the keeper
+	err := app.AccessControlKeeper.SetResourceDependencyMapping(ctx, mapping)
+	require.ErrorIs(t, err, types.ErrUnknownFieldExtractor)
+
+	app.AccessControlKeeper.FieldExtractors = types.FieldExtractors{
+		"send_sender": func(msg sdk.Msg) ([]string, error) {
+			return []string{msg.(*banktypes.MsgSend).FromAddress}, nil
+		},
+	}
+	require.NoError(t, app.AccessControlKeeper.SetResourceDependencyMapping(ctx, mapping))
+}
+
 func TestInvalidGetMessageDependencies(t *testing.T) {
 	app := simapp.Setup(false)
 	ctx := app.BaseApp.NewContext(false, tmproto.Header{})
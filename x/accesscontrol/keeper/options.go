@@ -1,5 +1,7 @@
 package keeper
 
+import "github.com/cosmos/cosmos-sdk/x/accesscontrol/types"
+
 type optsFn func(*Keeper)
 
 func (f optsFn) Apply(keeper *Keeper) {
@@ -18,6 +20,13 @@ func WithDependencyGeneratorMappings(generator DependencyGeneratorMap) optsFn {
 	})
 }
 
+// WithFieldExtractors sets the field extractors resolving the selectors of the access operations.
+func WithFieldExtractors(extractors types.FieldExtractors) optsFn {
+	return optsFn(func(k *Keeper) {
+		k.FieldExtractors = extractors
+	})
+}
+
 func (oldGenerator DependencyGeneratorMap) Merge(newGenerator DependencyGeneratorMap) DependencyGeneratorMap {
 	for messageKey, dependencyGenerator := range newGenerator {
 		// overwrite default generator mappings with the new ones
@@ -5,6 +5,7 @@ import (
 
 	"github.com/cosmos/cosmos-sdk/types"
 	"github.com/cosmos/cosmos-sdk/x/accesscontrol/keeper"
+	accesscontroltypes "github.com/cosmos/cosmos-sdk/x/accesscontrol/types"
 
 	acltypes "github.com/cosmos/cosmos-sdk/types/accesscontrol"
 	"github.com/stretchr/testify/require"
@@ -43,6 +44,18 @@ func TestWithDependencyGeneratorMappings(t *testing.T) {
 
 }
 
+func TestWithFieldExtractors(t *testing.T) {
+	var testKeeper keeper.Keeper
+	extractors := accesscontroltypes.FieldExtractors{
+		"test": func(msg types.Msg) ([]string, error) {
+			return nil, nil
+		},
+	}
+	apply := keeper.WithFieldExtractors(extractors)
+	apply.Apply(&testKeeper)
+	require.Contains(t, testKeeper.FieldExtractors, "test")
+}
+
 func TestDependencyGeneratorMap_Merge(t *testing.T) {
 	oldGenerator := make(keeper.DependencyGeneratorMap)
 	oldGenerator["oldTest"] = func(keeper keeper.Keeper, ctx types.Context, msg types.Msg) ([]acltypes.AccessOperation, error) {
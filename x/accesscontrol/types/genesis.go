@@ -2,12 +2,16 @@ package types
 
 import (
 	"encoding/json"
+	"fmt"
+	"strings"
 
 	"github.com/cosmos/cosmos-sdk/codec"
 	acltypes "github.com/cosmos/cosmos-sdk/types/accesscontrol"
 )
 
-// NewGenesisState creates a new GenesisState object
+// NewGenesisState creates a new GenesisState object without validating it, an invalid mapping
+// (e.g. one not terminated by a COMMIT access op) is only caught later by ValidateGenesis or at runtime
+// by the scheduler, prefer NewValidatedGenesisState.
 func NewGenesisState(params Params, messageDependencyMapping []acltypes.MessageDependencyMapping, wasmDependencyMappings []acltypes.WasmDependencyMapping) *GenesisState {
 	return &GenesisState{
 		Params:                   params,
@@ -16,6 +20,15 @@ func NewGenesisState(params Params, messageDependencyMapping []acltypes.MessageD
 	}
 }
 
+// NewValidatedGenesisState creates a new GenesisState object and validates it
+func NewValidatedGenesisState(params Params, messageDependencyMapping []acltypes.MessageDependencyMapping, wasmDependencyMappings []acltypes.WasmDependencyMapping) (*GenesisState, error) {
+	genState := NewGenesisState(params, messageDependencyMapping, wasmDependencyMappings)
+	if err := ValidateGenesis(*genState); err != nil {
+		return nil, err
+	}
+	return genState, nil
+}
+
 // DefaultGenesisState - default GenesisState used by columbus-2
 func DefaultGenesisState() *GenesisState {
 	return &GenesisState{
@@ -27,11 +40,25 @@ func DefaultGenesisState() *GenesisState {
 
 // ValidateGenesis validates the oracle genesis state
 func ValidateGenesis(data GenesisState) error {
+	seenMessageKeys := map[string]bool{}
+	var duplicateMessageKeys []string
 	for _, mapping := range data.MessageDependencyMapping {
 		err := ValidateMessageDependencyMapping(mapping)
 		if err != nil {
 			return err
 		}
+		// a key mapped multiple times would be silently overwritten by the last mapping
+		if reported, ok := seenMessageKeys[mapping.MessageKey]; ok {
+			if !reported {
+				duplicateMessageKeys = append(duplicateMessageKeys, mapping.MessageKey)
+				seenMessageKeys[mapping.MessageKey] = true
+			}
+			continue
+		}
+		seenMessageKeys[mapping.MessageKey] = false
+	}
+	if len(duplicateMessageKeys) > 0 {
+		return fmt.Errorf("%w: %s", ErrDuplicateMessageKey, strings.Join(duplicateMessageKeys, ", "))
 	}
 	for _, mapping := range data.WasmDependencyMappings {
 		err := ValidateWasmDependencyMapping(mapping)
@@ -3,6 +3,7 @@ package types
 import (
 	"testing"
 
+	acltypes "github.com/cosmos/cosmos-sdk/types/accesscontrol"
 	"github.com/stretchr/testify/require"
 )
 
@@ -10,3 +11,70 @@ func TestGenesisValidation(t *testing.T) {
 	genState := DefaultGenesisState()
 	require.NoError(t, ValidateGenesis(*genState))
 }
+
+func TestNewValidatedGenesisState(t *testing.T) {
+	genState, err := NewValidatedGenesisState(DefaultParams(), []acltypes.MessageDependencyMapping{
+		SynchronousMessageDependencyMapping("test"),
+	}, DefaultWasmDependencyMappings())
+	require.NoError(t, err)
+	require.Len(t, genState.MessageDependencyMapping, 1)
+
+	// missing COMMIT op
+	_, err = NewValidatedGenesisState(DefaultParams(), []acltypes.MessageDependencyMapping{
+		{
+			MessageKey: "test",
+			AccessOps: []acltypes.AccessOperation{
+				{AccessType: acltypes.AccessType_UNKNOWN, ResourceType: acltypes.ResourceType_ANY, IdentifierTemplate: "*"},
+			},
+		},
+	}, DefaultWasmDependencyMappings())
+	require.ErrorIs(t, err, ErrNoCommitAccessOp)
+
+	// no access ops at all
+	_, err = NewValidatedGenesisState(DefaultParams(), []acltypes.MessageDependencyMapping{
+		{MessageKey: "test"},
+	}, DefaultWasmDependencyMappings())
+	require.ErrorIs(t, err, ErrNoCommitAccessOp)
+}
+
+func TestValidateGenesisWasmDependencyMappings(t *testing.T) {
+	genState := DefaultGenesisState()
+	genState.WasmDependencyMappings = []acltypes.WasmDependencyMapping{
+		SynchronousWasmDependencyMapping("contract1"),
+		SynchronousWasmDependencyMapping("contract2"),
+	}
+	require.NoError(t, ValidateGenesis(*genState))
+
+	// missing COMMIT op
+	noCommit := SynchronousWasmDependencyMapping("contract3")
+	noCommit.BaseAccessOps = noCommit.BaseAccessOps[:len(noCommit.BaseAccessOps)-1]
+	genState.WasmDependencyMappings = append(genState.WasmDependencyMappings, noCommit)
+	require.ErrorIs(t, ValidateGenesis(*genState), ErrNoCommitAccessOp)
+
+	// duplicate execute method name
+	duplicate := SynchronousWasmDependencyMapping("contract3")
+	duplicate.ExecuteAccessOps = []*acltypes.WasmAccessOperations{
+		{MessageName: "send", WasmOperations: duplicate.BaseAccessOps},
+		{MessageName: "send", WasmOperations: duplicate.BaseAccessOps},
+	}
+	genState.WasmDependencyMappings[2] = duplicate
+	require.ErrorIs(t, ValidateGenesis(*genState), ErrDuplicateWasmMethodName)
+}
+
+func TestValidateGenesisDuplicateMessageKey(t *testing.T) {
+	genState := DefaultGenesisState()
+	genState.MessageDependencyMapping = []acltypes.MessageDependencyMapping{
+		SynchronousMessageDependencyMapping("test1"),
+		SynchronousMessageDependencyMapping("test2"),
+	}
+	require.NoError(t, ValidateGenesis(*genState))
+
+	genState.MessageDependencyMapping = append(genState.MessageDependencyMapping,
+		SynchronousMessageDependencyMapping("test2"),
+		SynchronousMessageDependencyMapping("test1"),
+		SynchronousMessageDependencyMapping("test2"),
+	)
+	err := ValidateGenesis(*genState)
+	require.ErrorIs(t, err, ErrDuplicateMessageKey)
+	require.EqualError(t, err, "a message key is mapped multiple times: test2, test1")
+}
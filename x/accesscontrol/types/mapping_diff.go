@@ -0,0 +1,92 @@
+package types
+
+import (
+	"sort"
+
+	acltypes "github.com/cosmos/cosmos-sdk/types/accesscontrol"
+)
+
+// MappingDiff describes how a set of message dependency mappings changed, the message keys are sorted.
+type MappingDiff struct {
+	AddedMessageKeys   []string             `json:"added_message_keys"`
+	RemovedMessageKeys []string             `json:"removed_message_keys"`
+	ChangedMessages    []MessageMappingDiff `json:"changed_messages"`
+}
+
+// MessageMappingDiff describes the access ops added to and removed from the mapping of a message,
+// the access ops are listed in the order they appear in the new and old mappings respectively.
+type MessageMappingDiff struct {
+	MessageKey string                     `json:"message_key"`
+	AddedOps   []acltypes.AccessOperation `json:"added_ops"`
+	RemovedOps []acltypes.AccessOperation `json:"removed_ops"`
+}
+
+// IsEmpty returns whether the mappings are unchanged.
+func (d MappingDiff) IsEmpty() bool {
+	return len(d.AddedMessageKeys) == 0 && len(d.RemovedMessageKeys) == 0 && len(d.ChangedMessages) == 0
+}
+
+// DiffMessageDependencyMappings reports the message keys added and removed between the old and new mappings,
+// and the access ops added and removed for the message keys present in both.
+func DiffMessageDependencyMappings(oldMappings, newMappings []acltypes.MessageDependencyMapping) MappingDiff {
+	oldByKey := make(map[string]acltypes.MessageDependencyMapping, len(oldMappings))
+	for _, mapping := range oldMappings {
+		oldByKey[mapping.MessageKey] = mapping
+	}
+	newByKey := make(map[string]acltypes.MessageDependencyMapping, len(newMappings))
+	for _, mapping := range newMappings {
+		newByKey[mapping.MessageKey] = mapping
+	}
+
+	diff := MappingDiff{
+		AddedMessageKeys:   []string{},
+		RemovedMessageKeys: []string{},
+		ChangedMessages:    []MessageMappingDiff{},
+	}
+	for messageKey, newMapping := range newByKey {
+		oldMapping, ok := oldByKey[messageKey]
+		if !ok {
+			diff.AddedMessageKeys = append(diff.AddedMessageKeys, messageKey)
+			continue
+		}
+		addedOps := subtractAccessOps(newMapping.AccessOps, oldMapping.AccessOps)
+		removedOps := subtractAccessOps(oldMapping.AccessOps, newMapping.AccessOps)
+		if len(addedOps) > 0 || len(removedOps) > 0 {
+			diff.ChangedMessages = append(diff.ChangedMessages, MessageMappingDiff{
+				MessageKey: messageKey,
+				AddedOps:   addedOps,
+				RemovedOps: removedOps,
+			})
+		}
+	}
+	for messageKey := range oldByKey {
+		if _, ok := newByKey[messageKey]; !ok {
+			diff.RemovedMessageKeys = append(diff.RemovedMessageKeys, messageKey)
+		}
+	}
+
+	sort.Strings(diff.AddedMessageKeys)
+	sort.Strings(diff.RemovedMessageKeys)
+	sort.Slice(diff.ChangedMessages, func(i, j int) bool {
+		return diff.ChangedMessages[i].MessageKey < diff.ChangedMessages[j].MessageKey
+	})
+	return diff
+}
+
+// subtractAccessOps returns the access ops in ops which are not in others, duplicated access ops are counted.
+func subtractAccessOps(ops, others []acltypes.AccessOperation) []acltypes.AccessOperation {
+	counts := make(map[string]int, len(others))
+	for _, op := range others {
+		counts[op.String()]++
+	}
+	result := []acltypes.AccessOperation{}
+	for _, op := range ops {
+		key := op.String()
+		if counts[key] > 0 {
+			counts[key]--
+			continue
+		}
+		result = append(result, op)
+	}
+	return result
+}
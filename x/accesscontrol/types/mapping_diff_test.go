@@ -0,0 +1,67 @@
+package types_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	acltypes "github.com/cosmos/cosmos-sdk/types/accesscontrol"
+	"github.com/cosmos/cosmos-sdk/x/accesscontrol/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffMessageDependencyMappings(t *testing.T) {
+	readBalances := acltypes.AccessOperation{AccessType: acltypes.AccessType_READ, ResourceType: acltypes.ResourceType_KV_BANK_BALANCES, IdentifierTemplate: "*"}
+	writeBalances := acltypes.AccessOperation{AccessType: acltypes.AccessType_WRITE, ResourceType: acltypes.ResourceType_KV_BANK_BALANCES, IdentifierTemplate: "*"}
+	readAccount := acltypes.AccessOperation{AccessType: acltypes.AccessType_READ, ResourceType: acltypes.ResourceType_KV_AUTH_ADDRESS_STORE, IdentifierTemplate: "*"}
+	mapping := func(messageKey string, ops ...acltypes.AccessOperation) acltypes.MessageDependencyMapping {
+		return acltypes.MessageDependencyMapping{MessageKey: messageKey, AccessOps: append(ops, *types.CommitAccessOp())}
+	}
+
+	oldMappings := []acltypes.MessageDependencyMapping{
+		mapping("send", readBalances, writeBalances),
+		mapping("delegate", readAccount),
+		mapping("vote", readAccount),
+		mapping("removed", readAccount),
+		mapping("duplicated", readBalances, readBalances),
+	}
+	newMappings := []acltypes.MessageDependencyMapping{
+		mapping("vote", readAccount),
+		mapping("send", readAccount, readBalances),
+		mapping("added", readBalances),
+		mapping("delegate", readAccount),
+		mapping("duplicated", readBalances),
+		mapping("another", readBalances),
+	}
+
+	diff := types.DiffMessageDependencyMappings(oldMappings, newMappings)
+	require.Equal(t, types.MappingDiff{
+		AddedMessageKeys:   []string{"added", "another"},
+		RemovedMessageKeys: []string{"removed"},
+		ChangedMessages: []types.MessageMappingDiff{
+			{
+				MessageKey: "duplicated",
+				AddedOps:   []acltypes.AccessOperation{},
+				RemovedOps: []acltypes.AccessOperation{readBalances},
+			},
+			{
+				MessageKey: "send",
+				AddedOps:   []acltypes.AccessOperation{readAccount},
+				RemovedOps: []acltypes.AccessOperation{writeBalances},
+			},
+		},
+	}, diff)
+	require.False(t, diff.IsEmpty())
+
+	// the result is stable regardless of the input order
+	reversed := make([]acltypes.MessageDependencyMapping, len(newMappings))
+	for i, m := range newMappings {
+		reversed[len(newMappings)-1-i] = m
+	}
+	bz, err := json.Marshal(diff)
+	require.NoError(t, err)
+	reversedBz, err := json.Marshal(types.DiffMessageDependencyMappings(oldMappings, reversed))
+	require.NoError(t, err)
+	require.Equal(t, string(bz), string(reversedBz))
+
+	require.True(t, types.DiffMessageDependencyMappings(oldMappings, oldMappings).IsEmpty())
+}
@@ -16,6 +16,7 @@ var (
 	ErrQueryRefNonQueryMessageType       = fmt.Errorf("query contract references can only have query message types")
 	ErrSelectorDeprecated                = fmt.Errorf("this selector type is deprecated")
 	ErrInvalidMsgInfo                    = fmt.Errorf("msg info cannot be nil")
+	ErrDuplicateMessageKey               = fmt.Errorf("a message key is mapped multiple times")
 )
 
 type MessageKey string
@@ -30,6 +31,9 @@ func CommitAccessOp() *acltypes.AccessOperation {
 
 // Validates access operation sequence for a message, requires the last access operation to be a COMMIT
 func ValidateAccessOps(accessOps []acltypes.AccessOperation) error {
+	if len(accessOps) == 0 {
+		return ErrNoCommitAccessOp
+	}
 	lastAccessOp := accessOps[len(accessOps)-1]
 	if lastAccessOp != *CommitAccessOp() {
 		return ErrNoCommitAccessOp
@@ -45,6 +49,9 @@ func ValidateAccessOps(accessOps []acltypes.AccessOperation) error {
 }
 
 func ValidateAccessOp(accessOp acltypes.AccessOperation) error {
+	if accessOp.Selector != nil {
+		return validateSelector(accessOp)
+	}
 	if accessOp.IdentifierTemplate == "" {
 		return ErrEmptyIdentifierString
 	}
@@ -158,24 +165,36 @@ func ValidateWasmDependencyMapping(mapping acltypes.WasmDependencyMapping) error
 
 	// ensure deprecation for CONTRACT_REFERENCE access operation selector due to new contract references
 	for _, accessOp := range mapping.BaseAccessOps {
-		if accessOp.SelectorType == acltypes.AccessOperationSelectorType_CONTRACT_REFERENCE {
-			return ErrSelectorDeprecated
+		if err := validateWasmAccessOp(accessOp); err != nil {
+			return err
 		}
 	}
 	for _, accessOps := range mapping.ExecuteAccessOps {
 		for _, accessOp := range accessOps.WasmOperations {
-			if accessOp.SelectorType == acltypes.AccessOperationSelectorType_CONTRACT_REFERENCE {
-				return ErrSelectorDeprecated
+			if err := validateWasmAccessOp(accessOp); err != nil {
+				return err
 			}
 		}
 	}
 	for _, accessOps := range mapping.QueryAccessOps {
 		for _, accessOp := range accessOps.WasmOperations {
-			if accessOp.SelectorType == acltypes.AccessOperationSelectorType_CONTRACT_REFERENCE {
-				return ErrSelectorDeprecated
+			if err := validateWasmAccessOp(accessOp); err != nil {
+				return err
 			}
 		}
 	}
 
 	return nil
 }
+
+// validateWasmAccessOp rejects the deprecated CONTRACT_REFERENCE selector type, and the field selectors which are
+// only resolved for the message dependency mappings.
+func validateWasmAccessOp(accessOp *acltypes.WasmAccessOperation) error {
+	if accessOp.SelectorType == acltypes.AccessOperationSelectorType_CONTRACT_REFERENCE {
+		return ErrSelectorDeprecated
+	}
+	if accessOp.Operation != nil && accessOp.Operation.Selector != nil {
+		return ErrSelectorInWasmMapping
+	}
+	return nil
+}
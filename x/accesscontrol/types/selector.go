@@ -0,0 +1,109 @@
+package types
+
+import (
+	fmt "fmt"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	acltypes "github.com/cosmos/cosmos-sdk/types/accesscontrol"
+)
+
+var (
+	ErrSelectorWithIdentifierTemplate  = fmt.Errorf("Selector and IdentifierTemplate are mutually exclusive")
+	ErrEmptySelector                   = fmt.Errorf("Selector must have at least one field extractor")
+	ErrInvalidFieldExtractor           = fmt.Errorf("field extractor names must be non empty and unique in a Selector")
+	ErrUnknownFieldExtractor           = fmt.Errorf("field extractor is not configured")
+	ErrNonLeafResourceTypeWithSelector = fmt.Errorf("Selector cannot be used for non leaf resource types")
+	ErrSelectorInWasmMapping           = fmt.Errorf("Selector cannot be used in wasm dependency mappings")
+)
+
+// FieldExtractor derives identifiers of an access operation from the fields of a message.
+type FieldExtractor func(msg sdk.Msg) ([]string, error)
+
+// FieldExtractors are the field extractors referenced by name by the access operation selectors, they're part
+// of the configuration of the keeper so every node of the chain resolves the selectors the same way.
+type FieldExtractors map[string]FieldExtractor
+
+// validateSelector validates the selector of an access operation, the field extractors it references are checked
+// against the ones of the keeper by FieldExtractors.ValidateAccessOps.
+func validateSelector(accessOp acltypes.AccessOperation) error {
+	if accessOp.IdentifierTemplate != "" {
+		return ErrSelectorWithIdentifierTemplate
+	}
+	if accessOp.ResourceType.HasChildren() {
+		return ErrNonLeafResourceTypeWithSelector
+	}
+	if len(accessOp.Selector.Extractors) == 0 {
+		return ErrEmptySelector
+	}
+	seen := map[string]struct{}{}
+	for _, name := range accessOp.Selector.Extractors {
+		if _, ok := seen[name]; ok || name == "" {
+			return ErrInvalidFieldExtractor
+		}
+		seen[name] = struct{}{}
+	}
+	return nil
+}
+
+// ValidateAccessOps checks the selectors of the access operations only reference known field extractors.
+func (extractors FieldExtractors) ValidateAccessOps(accessOps []acltypes.AccessOperation) error {
+	for _, accessOp := range accessOps {
+		if accessOp.Selector == nil {
+			continue
+		}
+		for _, name := range accessOp.Selector.Extractors {
+			if _, ok := extractors[name]; !ok {
+				return fmt.Errorf("%w: %s", ErrUnknownFieldExtractor, name)
+			}
+		}
+	}
+	return nil
+}
+
+// ResolveAccessOps expands the access operations using a selector into one access operation per identifier derived
+// from the message, the access operations using an identifier template are returned as is. An access operation whose
+// selector derives no identifier from the message covers all the identifiers of its resource, like the wasm selectors.
+func (extractors FieldExtractors) ResolveAccessOps(accessOps []acltypes.AccessOperation, msg sdk.Msg) ([]acltypes.AccessOperation, error) {
+	if !hasSelector(accessOps) {
+		return accessOps, nil
+	}
+	resolved := make([]acltypes.AccessOperation, 0, len(accessOps))
+	for _, accessOp := range accessOps {
+		if accessOp.Selector == nil {
+			resolved = append(resolved, accessOp)
+			continue
+		}
+		var identifiers []string
+		for _, name := range accessOp.Selector.Extractors {
+			extractor, ok := extractors[name]
+			if !ok {
+				return nil, fmt.Errorf("%w: %s", ErrUnknownFieldExtractor, name)
+			}
+			extracted, err := extractor(msg)
+			if err != nil {
+				return nil, err
+			}
+			identifiers = append(identifiers, extracted...)
+		}
+		if len(identifiers) == 0 {
+			identifiers = []string{"*"}
+		}
+		for _, identifier := range identifiers {
+			resolved = append(resolved, acltypes.AccessOperation{
+				AccessType:         accessOp.AccessType,
+				ResourceType:       accessOp.ResourceType,
+				IdentifierTemplate: identifier,
+			})
+		}
+	}
+	return resolved, nil
+}
+
+func hasSelector(accessOps []acltypes.AccessOperation) bool {
+	for _, accessOp := range accessOps {
+		if accessOp.Selector != nil {
+			return true
+		}
+	}
+	return false
+}
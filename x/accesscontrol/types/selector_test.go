@@ -0,0 +1,140 @@
+package types_test
+
+import (
+	"fmt"
+	"testing"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	acltypes "github.com/cosmos/cosmos-sdk/types/accesscontrol"
+	"github.com/cosmos/cosmos-sdk/x/accesscontrol/types"
+	banktypes "github.com/cosmos/cosmos-sdk/x/bank/types"
+	"github.com/stretchr/testify/require"
+)
+
+var testExtractors = types.FieldExtractors{
+	"test_send_parties": func(msg sdk.Msg) ([]string, error) {
+		send, ok := msg.(*banktypes.MsgSend)
+		if !ok {
+			return nil, fmt.Errorf("unexpected message %T", msg)
+		}
+		return []string{send.FromAddress, send.ToAddress}, nil
+	},
+	"test_send_sender": func(msg sdk.Msg) ([]string, error) {
+		return []string{msg.(*banktypes.MsgSend).FromAddress}, nil
+	},
+	"test_none": func(msg sdk.Msg) ([]string, error) {
+		return nil, nil
+	},
+}
+
+func TestValidateSelector(t *testing.T) {
+	selectorOp := func(template string, resourceType acltypes.ResourceType, extractors ...string) acltypes.AccessOperation {
+		return acltypes.AccessOperation{
+			AccessType:         acltypes.AccessType_WRITE,
+			ResourceType:       resourceType,
+			IdentifierTemplate: template,
+			Selector:           &acltypes.FieldSelector{Extractors: extractors},
+		}
+	}
+	testCases := []struct {
+		name     string
+		accessOp acltypes.AccessOperation
+		expErr   error
+	}{
+		{"valid", selectorOp("", acltypes.ResourceType_KV_BANK_BALANCES, "test_send_parties", "test_send_sender"), nil},
+		{"with template", selectorOp("*", acltypes.ResourceType_KV_BANK_BALANCES, "test_send_parties"), types.ErrSelectorWithIdentifierTemplate},
+		{"non leaf resource", selectorOp("", acltypes.ResourceType_KV, "test_send_parties"), types.ErrNonLeafResourceTypeWithSelector},
+		{"empty", selectorOp("", acltypes.ResourceType_KV_BANK_BALANCES), types.ErrEmptySelector},
+		{"duplicate", selectorOp("", acltypes.ResourceType_KV_BANK_BALANCES, "test_send_parties", "test_send_parties"), types.ErrInvalidFieldExtractor},
+		{"empty name", selectorOp("", acltypes.ResourceType_KV_BANK_BALANCES, ""), types.ErrInvalidFieldExtractor},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mapping := acltypes.MessageDependencyMapping{
+				MessageKey: "key",
+				AccessOps:  []acltypes.AccessOperation{tc.accessOp, *types.CommitAccessOp()},
+			}
+			err := types.ValidateMessageDependencyMapping(mapping)
+			if tc.expErr == nil {
+				require.NoError(t, err)
+				require.NoError(t, testExtractors.ValidateAccessOps(mapping.AccessOps))
+			} else {
+				require.ErrorIs(t, err, tc.expErr)
+			}
+		})
+	}
+
+	// the extractors are checked against the ones of the keeper
+	unknown := []acltypes.AccessOperation{selectorOp("", acltypes.ResourceType_KV_BANK_BALANCES, "unknown"), *types.CommitAccessOp()}
+	require.NoError(t, types.ValidateAccessOps(unknown))
+	require.ErrorIs(t, testExtractors.ValidateAccessOps(unknown), types.ErrUnknownFieldExtractor)
+	require.ErrorIs(t, types.FieldExtractors(nil).ValidateAccessOps(unknown), types.ErrUnknownFieldExtractor)
+}
+
+func TestResolveAccessOps(t *testing.T) {
+	msg := &banktypes.MsgSend{FromAddress: "from", ToAddress: "to"}
+	accessOps := []acltypes.AccessOperation{
+		{
+			AccessType:   acltypes.AccessType_WRITE,
+			ResourceType: acltypes.ResourceType_KV_BANK_BALANCES,
+			Selector:     &acltypes.FieldSelector{Extractors: []string{"test_send_parties"}},
+		},
+		{
+			AccessType:         acltypes.AccessType_READ,
+			ResourceType:       acltypes.ResourceType_KV_AUTH_ADDRESS_STORE,
+			IdentifierTemplate: "account",
+		},
+		*types.CommitAccessOp(),
+	}
+	resolved, err := testExtractors.ResolveAccessOps(accessOps, msg)
+	require.NoError(t, err)
+	require.Equal(t, []acltypes.AccessOperation{
+		{AccessType: acltypes.AccessType_WRITE, ResourceType: acltypes.ResourceType_KV_BANK_BALANCES, IdentifierTemplate: "from"},
+		{AccessType: acltypes.AccessType_WRITE, ResourceType: acltypes.ResourceType_KV_BANK_BALANCES, IdentifierTemplate: "to"},
+		accessOps[1],
+		*types.CommitAccessOp(),
+	}, resolved)
+
+	_, err = testExtractors.ResolveAccessOps(accessOps, &banktypes.MsgMultiSend{})
+	require.Error(t, err)
+	_, err = types.FieldExtractors{}.ResolveAccessOps(accessOps, msg)
+	require.ErrorIs(t, err, types.ErrUnknownFieldExtractor)
+
+	// a selector deriving no identifier covers the whole resource
+	accessOps[0].Selector.Extractors = []string{"test_none"}
+	resolved, err = testExtractors.ResolveAccessOps(accessOps, msg)
+	require.NoError(t, err)
+	require.Equal(t, []acltypes.AccessOperation{
+		{AccessType: acltypes.AccessType_WRITE, ResourceType: acltypes.ResourceType_KV_BANK_BALANCES, IdentifierTemplate: "*"},
+		accessOps[1],
+		*types.CommitAccessOp(),
+	}, resolved)
+}
+
+func TestWasmDependencyMappingSelector(t *testing.T) {
+	selectorOp := &acltypes.WasmAccessOperation{
+		Operation: &acltypes.AccessOperation{
+			AccessType:   acltypes.AccessType_WRITE,
+			ResourceType: acltypes.ResourceType_KV_BANK_BALANCES,
+			Selector:     &acltypes.FieldSelector{Extractors: []string{"test_send_parties"}},
+		},
+		SelectorType: acltypes.AccessOperationSelectorType_NONE,
+	}
+	commitOp := &acltypes.WasmAccessOperation{
+		Operation:    types.CommitAccessOp(),
+		SelectorType: acltypes.AccessOperationSelectorType_NONE,
+	}
+	for _, mapping := range []acltypes.WasmDependencyMapping{
+		{BaseAccessOps: []*acltypes.WasmAccessOperation{selectorOp, commitOp}},
+		{
+			BaseAccessOps:    []*acltypes.WasmAccessOperation{commitOp},
+			ExecuteAccessOps: []*acltypes.WasmAccessOperations{{MessageName: "send", WasmOperations: []*acltypes.WasmAccessOperation{selectorOp}}},
+		},
+		{
+			BaseAccessOps:  []*acltypes.WasmAccessOperation{commitOp},
+			QueryAccessOps: []*acltypes.WasmAccessOperations{{MessageName: "balance", WasmOperations: []*acltypes.WasmAccessOperation{selectorOp}}},
+		},
+	} {
+		require.ErrorIs(t, types.ValidateWasmDependencyMapping(mapping), types.ErrSelectorInWasmMapping)
+	}
+}